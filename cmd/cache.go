@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+)
+
+// cacheCmd groups commands managing the download cache in ~/.cache/setup-machine
+// ($XDG_CACHE_HOME/setup-machine when set).
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the download cache",
+}
+
+// cacheClearCmd deletes all cached downloads; the next sync downloads everything again.
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all cached downloads",
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := installer.ClearCache()
+		if err != nil {
+			logger.Error("[ERROR] Failed to clear cache %s: %v\n", installer.CacheDir(), err)
+			os.Exit(1)
+		}
+		logger.Info("[INFO] Removed %d cached download(s) from %s\n", removed, installer.CacheDir())
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// cleanCmd removes what failed or interrupted syncs leave behind: setup-machine-* downloads
+// and work directories in the temp directory, and state entries for tools or files that no
+// longer exist on disk.
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove leftover downloads and prune state entries for missing files",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.DryRun = dryRun
+		// Holding the state lock keeps a running sync's downloads from being removed under it
+		defer lockState()()
+
+		removed := installer.CleanTempArtifacts()
+
+		st := state.LoadState(statePath)
+		before := st.Clone()
+		pruned := installer.PruneState(st)
+
+		if dryRun {
+			logger.Info("[DRY-RUN] Would remove %d temp artifact(s) and prune %d state entry(ies)\n", removed, pruned)
+		} else {
+			logger.Info("[INFO] Removed %d temp artifact(s) and pruned %d state entry(ies)\n", removed, pruned)
+		}
+		finishRun("clean", before, st, nil)
+	},
+}
+
+func init() {
+	cleanCmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be removed without changing anything")
+	rootCmd.AddCommand(cleanCmd)
+}
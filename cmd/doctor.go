@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/installer"
+)
+
+// doctorCmd reports which external commands and bin directories the config relies on are
+// available, without changing anything. It exits non-zero if a required one is missing.
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that external commands and bin directories needed by the config are available",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+
+		missing := 0
+		for _, c := range installer.Doctor(cfg) {
+			mark := "ok  "
+			switch {
+			case !c.OK && c.Required:
+				mark = "FAIL"
+				missing++
+			case !c.OK:
+				mark = "warn"
+			}
+			fmt.Printf("[%s] %-28s %s\n", mark, c.Name, c.Detail)
+		}
+
+		if missing > 0 {
+			fmt.Printf("\n%d required check(s) failed.\n", missing)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	rootCmd.AddCommand(doctorCmd)
+}
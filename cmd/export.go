@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// exportDir is the directory the exported config is written to.
+// It's set via the `--output`/`-o` flag of the export command.
+var exportDir string
+
+// exportedTool and exportedSetting are the YAML shapes of exported entries; unlike the config
+// structs they omit empty fields, so the output only contains what the state knows.
+type exportedTool struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version,omitempty"`
+	Source  string `yaml:"source"`
+	Cask    bool   `yaml:"cask,omitempty"`
+}
+
+type exportedSetting struct {
+	Domain string `yaml:"domain"`
+	Key    string `yaml:"key"`
+	Value  any    `yaml:"value"` // A string, or a list/map for array and dict settings
+	Type   string `yaml:"type"`
+
+	CurrentHost bool `yaml:"current_host,omitempty"`
+	Sudo        bool `yaml:"sudo,omitempty"`
+}
+
+type exportedAlias struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// exportCmd writes a config.yaml plus tools/settings/aliases files describing everything the
+// state file tracks, with versions pinned, so the machine can be reproduced elsewhere.
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write a config reproducing everything tracked in the state file",
+	Run: func(cmd *cobra.Command, args []string) {
+		tools, settings, aliases := installer.ExportConfig(state.LoadState(statePath))
+
+		var toolsDoc struct {
+			Tools []exportedTool `yaml:"tools"`
+		}
+		for _, t := range tools {
+			toolsDoc.Tools = append(toolsDoc.Tools, exportedTool{Name: t.Name, Version: t.Version, Source: t.Source, Cask: t.Cask})
+		}
+
+		var settingsDoc struct {
+			Settings struct {
+				MacOS []exportedSetting `yaml:"macos"`
+			} `yaml:"settings"`
+		}
+		for _, s := range settings {
+			var value any = s.Value
+			if s.Items != nil {
+				value = s.Items
+			} else if s.Dict != nil {
+				value = s.Dict
+			}
+			settingsDoc.Settings.MacOS = append(settingsDoc.Settings.MacOS, exportedSetting{Domain: s.Domain, Key: s.Key, Value: value, Type: s.Type, CurrentHost: s.CurrentHost, Sudo: s.Sudo})
+		}
+
+		var aliasesDoc struct {
+			Aliases struct {
+				Entries []exportedAlias `yaml:"entries"`
+			} `yaml:"aliases"`
+		}
+		for _, a := range aliases.Entries {
+			aliasesDoc.Aliases.Entries = append(aliasesDoc.Aliases.Entries, exportedAlias{Name: a.Name, Value: a.Value})
+		}
+
+		mainDoc := map[string]map[string]string{"config": {
+			"tools_file":    "tools.yaml",
+			"settings_file": "settings.yaml",
+			"aliases_file":  "aliases.yaml",
+		}}
+
+		if err := os.MkdirAll(exportDir, 0755); err != nil {
+			logger.Error("[ERROR] Cannot create %s: %v\n", exportDir, err)
+			os.Exit(1)
+		}
+		files := []struct {
+			name   string
+			header string
+			doc    any
+		}{
+			{"config.yaml", "", mainDoc},
+			{"tools.yaml", "# Sources of tools installed before sources were recorded are guessed from\n# their install path. github tools whose name is not owner/repo need a repo:.\n", toolsDoc},
+			{"settings.yaml", "# Types are inferred from the recorded values; review them before applying.\n", settingsDoc},
+			{"aliases.yaml", "# raw_configs are not tracked in the state file and must be copied by hand.\n", aliasesDoc},
+		}
+		for _, f := range files {
+			var buf bytes.Buffer
+			buf.WriteString(f.header)
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2) // Match the hand-written configs
+			if err := enc.Encode(f.doc); err != nil {
+				logger.Error("[ERROR] Failed to marshal %s: %v\n", f.name, err)
+				os.Exit(1)
+			}
+			path := filepath.Join(exportDir, f.name)
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				logger.Error("[ERROR] Failed to write %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Exported %d tools, %d settings, and %d aliases to %s\n", len(tools), len(settings), len(aliases.Entries), exportDir)
+		fmt.Printf("Use it with: setup-machine sync -c %s\n", filepath.Join(exportDir, "config.yaml"))
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportDir, "output", "o", "exported", "Directory to write the exported config to")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// historyCmd displays the log of past runs recorded next to the state file.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past runs and their outcomes",
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := state.LoadHistory(state.HistoryPath(statePath))
+		if err != nil {
+			logger.Error("[ERROR] Failed to read history: %v\n", err)
+			return
+		}
+
+		if jsonOutput {
+			out, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				logger.Error("[ERROR] Failed to marshal history: %v\n", err)
+				return
+			}
+			fmt.Println(string(out))
+			return
+		}
+
+		if len(entries) == 0 {
+			logger.Info("[INFO] No runs recorded yet.\n")
+			return
+		}
+		fmt.Printf("%-20s  %-14s  %9s  %8s  %7s  %6s  %8s  %s\n",
+			"TIME", "COMMAND", "INSTALLED", "UPGRADED", "REMOVED", "FAILED", "SETTINGS", "CONFIG")
+		for _, e := range entries {
+			hash := e.ConfigHash
+			if len(hash) > 12 {
+				hash = hash[:12]
+			}
+			fmt.Printf("%-20s  %-14s  %9d  %8d  %7d  %6d  %8d  %s\n",
+				e.Time.Local().Format("2006-01-02 15:04:05"), e.Command,
+				e.Installed, e.Upgraded, e.Removed, e.Failed, e.SettingsApplied, hash)
+		}
+	},
+}
+
+// recordHistory appends a compact entry describing a finished run to the history log.
+// Counts are derived from the state diff; a configured tool that status would still report
+// as missing or needing an upgrade after the run counts as failed. "latest" and version
+// ranges are compared the way status compares them, not as the literal config string.
+func recordHistory(command string, before, after *state.State, tools []config.Tool) {
+	diff := state.Compare(before, after)
+
+	failed := 0
+	for _, t := range tools {
+		switch toolStatus(t, after) {
+		case statusMissing, statusNeedsUpgrade:
+			failed++
+		}
+	}
+
+	hash, err := config.Hash(configPath)
+	if err != nil {
+		logger.Debug("[DEBUG] Unable to hash config for history: %v\n", err)
+	}
+
+	state.AppendHistory(state.HistoryPath(statePath), state.HistoryEntry{
+		Time:            time.Now().UTC(),
+		Command:         command,
+		ConfigHash:      hash,
+		Installed:       len(diff.ToolsAdded),
+		Upgraded:        len(diff.ToolsUpdated),
+		Removed:         len(diff.ToolsRemoved),
+		Failed:          failed,
+		SettingsApplied: len(diff.SettingsAdded) + len(diff.SettingsChanged),
+	})
+}
+
+func init() {
+	historyCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print history as JSON")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// installName overrides the tool name derived from the spec; url tools need it.
+// It's set via the `--name` flag of `install`.
+var installName string
+
+// installCmd installs a single tool given on the command line instead of in the config, and
+// records it in the state file so later syncs keep it up to date rather than removing it.
+var installCmd = &cobra.Command{
+	Use:   "install <source:name[@version]>",
+	Short: "Install a single tool without adding it to the config",
+	Long: `Install a single tool without adding it to the config, e.g.
+
+  setup-machine install github:sharkdp/bat@v0.24.0
+  setup-machine install brew:jq
+  setup-machine install npm:@scope/pkg@1.2.0
+  setup-machine install url:https://example.com/tool.tar.gz --name tool
+
+The tool is recorded in the state file and kept by later syncs; remove it with uninstall.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tool, err := config.ParseToolSpec(args[0])
+		if err != nil {
+			logger.Error("[ERROR] %v\n", err)
+			os.Exit(1)
+		}
+		if installName != "" {
+			tool.Name = installName
+		}
+		if tool.Name == "" {
+			logger.Error("[ERROR] %s tools have no name in the spec; pass --name\n", tool.Source)
+			os.Exit(1)
+		}
+
+		// The config's global options (bin dirs, GitHub API and token) still apply, if there is one
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Debug("[DEBUG] Not using %s: %v\n", configPath, err)
+			cfg = config.Config{}
+		}
+		for _, t := range cfg.Tools {
+			if t.Name == tool.Name {
+				logger.Error("[ERROR] %s is already in %s; install it with sync tools --only %s\n", tool.Name, configPath, tool.Name)
+				os.Exit(1)
+			}
+		}
+		if problems := config.Validate(config.Config{Tools: []config.Tool{tool}}); len(problems) > 0 {
+			logger.Error("[ERROR] Invalid tool:\n%v\n", errors.Join(problems...))
+			os.Exit(1)
+		}
+		if err := applyGlobalOptions(cfg); err != nil {
+			logger.Error("[ERROR] %v\n", err)
+			os.Exit(1)
+		}
+		defer lockState()()
+
+		st := state.LoadState(statePath)
+		before := st.Clone()
+
+		if err := installer.InstallAdHoc(tool, st); err != nil {
+			logger.Error("[ERROR] %v\n", err)
+			finishRun("install", before, st, nil)
+			os.Exit(1)
+		}
+		finishRun("install", before, st, []config.Tool{tool})
+	},
+}
+
+func init() {
+	installCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	installCmd.Flags().StringVar(&installName, "name", "", "Tool name to record, instead of the one in the spec (required for url)")
+	installCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Log how the tool would be installed without changing anything")
+	rootCmd.AddCommand(installCmd)
+}
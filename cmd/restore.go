@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/state"
+)
+
+// restoreCmd groups commands that undo changes recorded in the state file.
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Undo changes made by setup-machine",
+}
+
+// restoreSettingsCmd puts every applied macOS setting back to its value from before the
+// first sync that changed it.
+var restoreSettingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "Revert applied macOS settings to their previous values",
+	Run: func(cmd *cobra.Command, args []string) {
+		installer.DryRun = dryRun
+		installer.NoRestart = noRestart
+		defer lockState()()
+
+		st := state.LoadState(statePath)
+		before := st.Clone()
+
+		installer.RestoreSettings(st)
+		finishRun("restore settings", before, st, nil)
+	},
+}
+
+func init() {
+	restoreCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Log what would be restored without changing anything")
+	restoreCmd.PersistentFlags().BoolVar(&noRestart, "no-restart", false, "Don't restart apps like Finder and Dock after restoring their settings")
+	restoreCmd.AddCommand(restoreSettingsCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -1,37 +1,149 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
 	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"setup-machine/internal/version"
 )
 
 // debug flag indicates whether debug logging should be enabled.
-// It can be toggled via the `--debug` command-line flag.
+// It can be toggled via the `--debug` command-line flag and is shorthand for `--log-level debug`.
 var debug bool
 
+// quiet and verbose are shorthands for `--log-level warn` and `--log-level debug`.
+// They're set via the `--quiet`/`-q` and `--verbose`/`-v` flags.
+var quiet, verbose bool
+
+// logLevel selects how much is logged: error, warn, info (the default), debug, or trace.
+// It's set via the `--log-level` flag.
+var logLevel string
+
+// colorMode selects whether text logs are colored: auto (only on a terminal), always, or never.
+// It's set via the `--color` flag.
+var colorMode string
+
+// logFormat selects how messages are written: colored text (the default) or one JSON object
+// per line for CI log aggregators. It's set via the `--log-format` flag.
+var logFormat string
+
+// configUsage is the help text of the `--config` flag shared by the commands that read the config.
+const configUsage = "Path to configuration file (default: $XDG_CONFIG_HOME/setup-machine/config.yaml, ~/.config/setup-machine/config.yaml, or ./config.yaml, whichever exists first)"
+
 // rootCmd is the base command for the CLI tool `setup-machine`.
 // It sets up the root-level CLI structure and provides global flags.
 var rootCmd = &cobra.Command{
-	Use:   "setup-machine",     // The name of the CLI tool
-	Short: "System setup tool", // Short description shown in help output
+	Use:     "setup-machine",     // The name of the CLI tool
+	Short:   "System setup tool", // Short description shown in help output
+	Version: version.Version,     // Enables the --version flag
 
-	// PersistentPreRun is a hook that runs before any subcommand.
-	// Here, we initialize the logger based on the debug flag.
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		logger.Init(debug) // Set up logging (verbose if --debug is true)
+	// PersistentPreRunE is a hook that runs before any subcommand.
+	// Here, we initialize the logger based on the --log-level and --debug flags.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		level, err := logger.ParseLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		if quiet && (debug || verbose) {
+			return fmt.Errorf("--quiet cannot be combined with --verbose or --debug")
+		}
+		// --verbose (and --debug, kept for compatibility) never lower an explicitly higher level
+		if (debug || verbose) && level < logger.LevelDebug {
+			level = logger.LevelDebug
+		}
+		// --quiet keeps CI output to warnings and errors
+		if quiet && level > logger.LevelWarn {
+			level = logger.LevelWarn
+		}
+		format, err := logger.ParseFormat(logFormat)
+		if err != nil {
+			return err
+		}
+		colors, err := logger.ParseColorMode(colorMode)
+		if err != nil {
+			return err
+		}
+		logger.Init(level, format, colors)
+		// Commands whose stdout is their result keep it clean, so it can be redirected or parsed
+		if stdoutIsResult(cmd) {
+			logger.UseStderr()
+		}
+
+		// Resolve the state file once for every command; without --state it lives in a fixed
+		// location. sync and status pick up a state.json left in the current directory by
+		// older versions; other commands never copy files around as a side effect.
+		if statePath == "" {
+			statePath = state.DefaultPath()
+			if migratesLegacyState(cmd) {
+				if err := state.MigrateLegacy(statePath); err != nil {
+					return fmt.Errorf("cannot copy %s to %s: %w", state.LegacyPath, statePath, err)
+				}
+			}
+		} else if strings.HasPrefix(statePath, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			statePath = filepath.Join(home, statePath[2:])
+		}
+		logger.Debug("[DEBUG] Using state file %s\n", statePath)
+
+		// Commands reading the config look for it in the standard locations without --config
+		if cmd.Flag("config") != nil && configPath == "" {
+			configPath = config.Discover()
+			logger.Info("[INFO] Using config file %s\n", configPath)
+		}
+		return nil
 	},
 }
 
+// stdoutIsResult reports whether cmd prints its result on stdout, in which case logs go to
+// stderr: generate-script without --output prints the script itself, and --json (sync,
+// history) prints a single JSON document.
+func stdoutIsResult(cmd *cobra.Command) bool {
+	if cmd == generateScriptCmd && scriptOutput == "" {
+		return true
+	}
+	return jsonOutput && cmd.Flag("json") != nil
+}
+
+// migratesLegacyState reports whether cmd adopts a state.json left in the current directory by
+// older versions: only sync (and its subcommands) and status, the commands users upgrading
+// run first, and not when a remote host is synced instead of this machine.
+func migratesLegacyState(cmd *cobra.Command) bool {
+	if cmd == statusCmd {
+		return true
+	}
+	return (cmd == syncCmd || cmd.Parent() == syncCmd) && remoteHost == ""
+}
+
 // Execute initializes flags, registers subcommands, and starts the command execution.
 // It's the entry point for the CLI when invoked by the user.
 func Execute() {
 	// Register the global --debug flag before any command is executed.
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging (same as --log-level debug)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print warnings and errors (same as --log-level warn)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable debug logging (same as --log-level debug)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: error, warn, info, debug, or trace")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text, or json for one JSON object per line")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "Color log output: auto (only on a terminal), always, or never")
+	rootCmd.PersistentFlags().StringVar(&statePath, "state", "", "Path to the state file (default ~/.local/state/setup-machine/state.json)")
 
 	// Add the `sync` command and its subcommands (defined in sync.go)
 	rootCmd.AddCommand(syncCmd)
 
 	// Execute runs the appropriate subcommand or displays help if none is provided.
-	// Errors are ignored here with `_ =` since Cobra handles them internally by default.
-	_ = rootCmd.Execute()
+	// Errors (bad flags, an invalid --log-level, a failed run, ...) are logged like any other
+	// error, and the exit status must say the run failed so scripts and CI notice.
+	rootCmd.SilenceErrors = true
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
 }
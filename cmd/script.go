@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// scriptOutput is where generate-script writes the script; empty means stdout.
+// It's set via the `--output`/`-o` flag.
+var scriptOutput string
+
+// generateScriptCmd writes the changes a sync would make as a standalone bash script,
+// for auditing or for machines where the binary can't run. It changes nothing.
+var generateScriptCmd = &cobra.Command{
+	Use:          "generate-script",
+	Short:        "Write the commands a sync would run as a bash script",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		// Steps that couldn't be planned are still written out as comments, but fail the command
+		script, planErr := installer.GenerateScript(cfg, state.LoadState(statePath))
+
+		if scriptOutput == "" {
+			if _, err := fmt.Print(script); err != nil {
+				return fmt.Errorf("failed to write script: %w", err)
+			}
+			return planErr
+		}
+		if err := os.WriteFile(scriptOutput, []byte(script), 0755); err != nil {
+			return fmt.Errorf("failed to write script: %w", err)
+		}
+		logger.Info("[INFO] Wrote %s\n", scriptOutput)
+		return planErr
+	},
+}
+
+func init() {
+	generateScriptCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	generateScriptCmd.Flags().StringVarP(&scriptOutput, "output", "o", "", "Write the script to this file instead of stdout")
+	rootCmd.AddCommand(generateScriptCmd)
+}
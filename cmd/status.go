@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+	"setup-machine/internal/version"
+)
+
+// Drift statuses shown by the status command.
+const (
+	statusInSync       = "in-sync"
+	statusNeedsUpgrade = "needs-upgrade"
+	statusMissing      = "missing"
+	statusOrphaned     = "orphaned"
+	statusDisabled     = "disabled"
+)
+
+// statusCmd shows how the recorded state differs from the config, without changing anything.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show drift between the config and what is installed",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		st := state.LoadState(statePath)
+
+		printToolStatus(cfg.Tools, st)
+		fmt.Println()
+		printSettingStatus(cfg.Settings, st)
+	},
+}
+
+// printToolStatus prints one row per configured tool plus one per orphaned tool in state.
+func printToolStatus(tools []config.Tool, st *state.State) {
+	fmt.Printf("%-20s  %-14s  %-14s  %s\n", "TOOL", "CONFIGURED", "INSTALLED", "STATUS")
+	configured := map[string]bool{}
+	for _, tool := range tools {
+		configured[tool.Name] = true
+		cur := st.Tools[tool.Name]
+		fmt.Printf("%-20s  %-14s  %-14s  %s\n", tool.Name, tool.Version, orDash(cur.Version), toolStatus(tool, st))
+	}
+
+	for _, name := range sortedKeys(st.Tools) {
+		if !configured[name] {
+			fmt.Printf("%-20s  %-14s  %-14s  %s\n", name, "-", st.Tools[name].Version, statusOrphaned)
+		}
+	}
+}
+
+// toolStatus returns the drift status of a configured tool against the state file. History
+// counts failures with it too, so both agree on when a tool is installed as configured.
+func toolStatus(tool config.Tool, st *state.State) string {
+	cur, ok := st.Tools[tool.Name]
+	switch {
+	case !tool.IsEnabled():
+		return statusDisabled
+	case !ok:
+		return statusMissing
+	case tool.Source == "github" && tool.WantsLatest():
+		// Whether "latest" is outdated would take a network lookup; status stays offline
+	case tool.Source == "github" && version.IsConstraint(tool.Version):
+		if c, err := version.ParseConstraint(tool.Version); err != nil || !c.Check(cur.Version) {
+			return statusNeedsUpgrade
+		}
+	case cur.Version != tool.Version:
+		return statusNeedsUpgrade
+	}
+	return statusInSync
+}
+
+// printSettingStatus prints one row per configured setting plus one per setting recorded in
+// state that is no longer configured.
+func printSettingStatus(settings []config.Setting, st *state.State) {
+	fmt.Printf("%-45s  %-14s  %-14s  %s\n", "SETTING", "CONFIGURED", "APPLIED", "STATUS")
+	configured := map[string]bool{}
+	for _, s := range settings {
+		key := s.ID()
+		configured[key] = true
+		cur, ok := st.Settings[key]
+		status := statusInSync
+		switch {
+		case !s.IsEnabled():
+			status = statusDisabled
+		case !ok:
+			status = statusMissing
+		case cur.Value != s.Value && !s.ApplyOnce:
+			status = statusNeedsUpgrade
+		}
+		fmt.Printf("%-45s  %-14s  %-14s  %s\n", key, s.Value, orDash(cur.Value), status)
+	}
+
+	for _, key := range sortedKeys(st.Settings) {
+		if !configured[key] {
+			fmt.Printf("%-45s  %-14s  %-14s  %s\n", key, "-", st.Settings[key].Value, statusOrphaned)
+		}
+	}
+}
+
+// orDash returns s, or "-" when it is empty, so table columns never collapse.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// sortedKeys returns the keys of a state map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	rootCmd.AddCommand(statusCmd)
+}
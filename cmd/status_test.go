@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+func TestToolStatus(t *testing.T) {
+	disabled := false
+	st := &state.State{Tools: map[string]state.ToolState{
+		"bat": {Version: "0.24.0"},
+		"fd":  {Version: "9.0.0"},
+		"jq":  {Version: "1.7"},
+	}}
+
+	tests := []struct {
+		tool config.Tool
+		want string
+	}{
+		{config.Tool{Name: "bat", Source: "github", Version: "latest"}, statusInSync},
+		{config.Tool{Name: "bat", Source: "github", Version: ""}, statusInSync},
+		{config.Tool{Name: "bat", Source: "github", Version: ">=0.20.0 <1.0.0"}, statusInSync},
+		{config.Tool{Name: "bat", Source: "github", Version: "~0.25"}, statusNeedsUpgrade},
+		{config.Tool{Name: "fd", Source: "github", Version: "9.0.0"}, statusInSync},
+		{config.Tool{Name: "fd", Source: "github", Version: "10.1.0"}, statusNeedsUpgrade},
+		{config.Tool{Name: "jq", Source: "brew", Version: "1.7", Enabled: &disabled}, statusDisabled},
+		{config.Tool{Name: "rg", Source: "github", Version: "latest"}, statusMissing},
+	}
+	for _, tt := range tests {
+		if got := toolStatus(tt.tool, st); got != tt.want {
+			t.Errorf("toolStatus(%s %q) = %s, want %s", tt.tool.Name, tt.tool.Version, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,21 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
 	"setup-machine/internal/config"
 	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/remote"
 	"setup-machine/internal/state"
 )
 
@@ -12,26 +24,136 @@ import (
 var configPath string
 
 // statePath is the path to the persistent state file.
-// This file tracks applied settings and installed tools.
-var statePath = "state.json" // You can make this configurable too
+// This file tracks applied settings and installed tools. It's set via the global `--state`
+// flag and defaults to state.DefaultPath(), resolved in the root command's PersistentPreRunE.
+var statePath string
+
+// strictSettings refuses to apply settings whose domain does not exist on the system.
+// It's set via the `--strict-settings` flag; by default unknown domains only produce a warning.
+var strictSettings bool
+
+// remoteHost, when set via `--host user@host`, runs the sync on a remote machine over SSH
+// instead of locally. The binary and config are copied there and state lives on the remote.
+var remoteHost string
+
+// remoteBinary is the setup-machine executable copied to remoteHost, for remotes whose OS or
+// architecture differs from this machine's. It's set via `--remote-binary`.
+var remoteBinary string
+
+// githubAPI overrides the GitHub API base URL (e.g. for GitHub Enterprise) for all github tools
+// that don't set their own. It's set via the `--github-api` flag and takes precedence over config.
+var githubAPI string
+
+// maxAge is how long a verified tool is trusted before being re-verified on sync.
+// It's set via the `--max-age` flag (e.g. 168h); zero disables periodic re-verification.
+var maxAge time.Duration
+
+// force re-applies apply-once settings that were already applied. It's set via `--force`.
+var force bool
+
+// overrides holds `--set path=value` config overrides applied after loading the config.
+var overrides []string
+
+// binDir overrides the primary install directory for binaries (e.g. ~/.local/bin).
+// It's set via the `--bin-dir` flag and takes precedence over `bin_dir` in the config.
+var binDir string
+
+// jobs is the maximum number of tools synced at the same time. It's set via `--jobs`/`-j`.
+var jobs int
+
+// concurrencyLimits and sourceTimeouts hold `--concurrency source=N` and `--timeout source=duration`
+// overrides of the per-source install defaults (e.g. --concurrency github=16 --timeout brew=30m).
+var concurrencyLimits []string
+var sourceTimeouts []string
+
+// showRemovals previews which tools a sync would uninstall, and how, without changing anything.
+// It's set via the `--show-removals` flag.
+var showRemovals bool
+
+// dryRun logs what a sync would do without installing, writing, or saving state.
+// It's set via the `--dry-run` flag.
+var dryRun bool
+
+// assumeYes, yesSettings, and yesUninstall auto-approve confirmation prompts: all of them,
+// only settings changes, or only tool uninstalls. They're set via `--yes`, `--yes-settings`,
+// and `--yes-uninstall`; a category flag approves its category regardless of `--yes`.
+var assumeYes, yesSettings, yesUninstall bool
+
+// httpTimeout bounds each HTTP request, including the download of its body; 0 disables it.
+// It's set via the `--http-timeout` flag.
+var httpTimeout time.Duration
+
+// noCache downloads every asset afresh instead of reusing cached copies from earlier runs.
+// It's set via the `--no-cache` flag.
+var noCache bool
+
+// noRestart leaves apps such as Finder and Dock running after their settings change.
+// It's set via the `--no-restart` flag.
+var noRestart bool
+
+// sudoRemoval lets uninstalls fall back to deleting /usr/local/bin/<tool> with sudo rm -f.
+// It's set via the `--sudo-removal` flag.
+var sudoRemoval bool
+
+// onlyTools and skipTools narrow `sync tools` to the named tools, or to all but them. They're
+// set via `--only` and `--skip` (comma-separated or repeated).
+var onlyTools, skipTools []string
+
+// checkpointer saves the state incrementally while tools are being installed.
+var checkpointer *state.Checkpointer
+
+// jsonOutput switches the end-of-run state diff from human-readable lines to JSON.
+// It's set via the `--json` flag.
+var jsonOutput bool
 
 // syncCmd is the top-level command for syncing all configuration aspects:
 // tools, macOS settings, and shell aliases.
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync system state with config (tools, settings, aliases)",
-	Run: func(cmd *cobra.Command, args []string) {
+	// Failures are returned so the process exits non-zero; they are not usage errors
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if remoteHost != "" {
+			syncRemote()
+			return nil
+		}
+
 		// Load configuration and state
-		cfg := config.LoadConfig(configPath)
+		cfg := loadConfig()
+		if showRemovals {
+			printRemovals(cfg.Tools)
+			return nil
+		}
+		defer lockState()()
+
+		// Report all permission problems up front, before anything is changed
+		problems := append(installer.CheckSettingsWritable(cfg.Settings), installer.CheckAliasesWritable(cfg.Aliases)...)
+		if err := reportPermissionProblems(problems); err != nil {
+			return err
+		}
+
+		// A failing pre_sync hook aborts before anything is changed
+		if err := installer.RunHooks("pre_sync", cfg.PreSync); err != nil {
+			return fmt.Errorf("%w; aborting sync", err)
+		}
+
 		st := state.LoadState(statePath)
+		before := st.Clone()
 
 		// Sync tools, settings, and aliases based on the loaded config
 		installer.SyncTools(cfg.Tools, st)
-		installer.SyncSettings(cfg.Settings, st)
-		installer.SyncAliases(cfg.Aliases)
+		installer.SyncSettings(installer.CheckSettingsDomains(cfg.Settings, strictSettings), st)
+		installer.SyncAliases(cfg.Aliases, st)
+
+		// Report the net effect of this run, then save updated state
+		finishRun("sync", before, st, cfg.Tools)
 
-		// Save updated state after syncing
-		state.SaveState(statePath, st)
+		// post_sync hooks are finalization steps; a failure is only a warning
+		if err := installer.RunHooks("post_sync", cfg.PostSync); err != nil {
+			logger.Warn("[WARN] %v\n", err)
+		}
+		return nil
 	},
 }
 
@@ -41,43 +163,110 @@ var syncToolsCmd = &cobra.Command{
 	Use:   "tools",
 	Short: "Sync only tools with config",
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.LoadConfig(configPath)
+		if remoteHost != "" {
+			syncRemote()
+			return
+		}
+		cfg := loadConfig()
+		tools := filterTools(cfg.Tools)
+		if showRemovals {
+			if installer.KeepUnlisted {
+				fmt.Println("No tools would be uninstalled (--only/--skip disable removals).")
+				return
+			}
+			printRemovals(tools)
+			return
+		}
+		defer lockState()()
+
 		st := state.LoadState(statePath)
+		before := st.Clone()
 
-		installer.SyncTools(cfg.Tools, st)
-		state.SaveState(statePath, st)
+		installer.SyncTools(tools, st)
+		finishRun("sync tools", before, st, tools)
 	},
 }
 
 // syncSettingsCmd syncs only macOS settings.
 // It updates the state after applying changes.
 var syncSettingsCmd = &cobra.Command{
-	Use:   "settings",
-	Short: "Sync only macOS settings with config",
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.LoadConfig(configPath)
+	Use:          "settings",
+	Short:        "Sync only macOS settings with config",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if remoteHost != "" {
+			syncRemote()
+			return nil
+		}
+		cfg := loadConfig()
+		defer lockState()()
+		if err := reportPermissionProblems(installer.CheckSettingsWritable(cfg.Settings)); err != nil {
+			return err
+		}
+
 		st := state.LoadState(statePath)
+		before := st.Clone()
 
-		installer.SyncSettings(cfg.Settings, st)
-		state.SaveState(statePath, st)
+		installer.SyncSettings(installer.CheckSettingsDomains(cfg.Settings, strictSettings), st)
+		finishRun("sync settings", before, st, nil)
+		return nil
 	},
 }
 
 // syncAliasesCmd syncs only shell aliases (e.g., for zsh or bash).
-// Aliases are applied directly and do not persist state (yet).
+// Applied aliases are recorded in the state file.
 var syncAliasesCmd = &cobra.Command{
-	Use:   "aliases",
-	Short: "Sync only shell aliases with config",
-	Run: func(cmd *cobra.Command, args []string) {
-		cfg := config.LoadConfig(configPath)
-		installer.SyncAliases(cfg.Aliases)
+	Use:          "aliases",
+	Short:        "Sync only shell aliases with config",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if remoteHost != "" {
+			syncRemote()
+			return nil
+		}
+		cfg := loadConfig()
+		defer lockState()()
+		if err := reportPermissionProblems(installer.CheckAliasesWritable(cfg.Aliases)); err != nil {
+			return err
+		}
+
+		st := state.LoadState(statePath)
+		before := st.Clone()
+
+		installer.SyncAliases(cfg.Aliases, st)
+		finishRun("sync aliases", before, st, nil)
+		return nil
 	},
 }
 
 // init sets up CLI flags and adds subcommands to the root command.
 func init() {
 	// Global flag for specifying config file path
-	syncCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "config.yaml", "Path to configuration file")
+	syncCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", configUsage)
+	syncCmd.PersistentFlags().BoolVar(&strictSettings, "strict-settings", false, "Refuse to apply settings for domains that do not exist")
+	syncCmd.PersistentFlags().StringVar(&remoteHost, "host", "", "Run the sync on a remote machine over SSH (user@host)")
+	syncCmd.PersistentFlags().StringVar(&remoteBinary, "remote-binary", "", "setup-machine build to copy to --host when its OS or architecture differs from this machine")
+	syncCmd.PersistentFlags().StringVar(&githubAPI, "github-api", "", "GitHub API base URL, e.g. https://ghe.example.com/api/v3")
+	syncCmd.PersistentFlags().DurationVar(&maxAge, "max-age", 0, "Re-verify tools last verified longer ago than this (e.g. 168h)")
+	syncCmd.PersistentFlags().BoolVar(&force, "force", false, "Re-apply settings marked apply_once")
+	syncCmd.PersistentFlags().StringArrayVar(&overrides, "set", nil, "Override a config value for this run, e.g. tools.jq.version=1.7.1 (repeatable)")
+	syncCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print the state diff summary as JSON")
+	syncCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Log what would be done without changing anything or saving state")
+	syncCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Approve all confirmation prompts")
+	syncCmd.PersistentFlags().BoolVar(&yesSettings, "yes-settings", false, "Approve settings changes without prompting")
+	syncCmd.PersistentFlags().BoolVar(&yesUninstall, "yes-uninstall", false, "Approve tool uninstalls without prompting")
+	syncCmd.PersistentFlags().BoolVar(&sudoRemoval, "sudo-removal", false, "Let uninstalls fall back to deleting /usr/local/bin/<tool> with sudo rm -f (confirmed)")
+	syncCmd.PersistentFlags().BoolVar(&showRemovals, "show-removals", false, "Only list the tools a sync would uninstall and how, without applying anything")
+	syncCmd.PersistentFlags().StringVar(&binDir, "bin-dir", "", "Primary directory to install binaries into; the defaults remain as fallbacks")
+	syncCmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Maximum number of tools installed at the same time")
+	syncCmd.PersistentFlags().StringArrayVar(&concurrencyLimits, "concurrency", nil, "Parallel installs allowed per source, e.g. github=16 (repeatable)")
+	syncCmd.PersistentFlags().DurationVar(&httpTimeout, "http-timeout", installer.DefaultHTTPTimeout, "Timeout for each HTTP request, including the download body; 0 disables it")
+	syncCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Download every asset afresh instead of reusing cached downloads")
+	syncCmd.PersistentFlags().BoolVar(&noRestart, "no-restart", false, "Don't restart apps like Finder and Dock after changing their settings")
+	syncCmd.PersistentFlags().StringArrayVar(&sourceTimeouts, "timeout", nil, "Install timeout per source, e.g. brew=30m; 0 disables it (repeatable)")
+
+	syncToolsCmd.Flags().StringSliceVar(&onlyTools, "only", nil, "Sync only these tools (comma-separated); nothing is uninstalled")
+	syncToolsCmd.Flags().StringSliceVar(&skipTools, "skip", nil, "Skip these tools (comma-separated); nothing is uninstalled")
 
 	// Add subcommands for more granular control
 	syncCmd.AddCommand(syncToolsCmd)
@@ -87,3 +276,290 @@ func init() {
 	// Register the `sync` command with the root command
 	rootCmd.AddCommand(syncCmd)
 }
+
+// loadConfig loads the config, applies --set overrides, validates the result, and pushes
+// the global options into the installer. Any problem is reported on a single line and
+// exits with a non-zero status, so scripts get a clean failure instead of a stack trace.
+func loadConfig() config.Config {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.ApplyOverrides(&cfg, overrides); err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	if problems := config.Validate(cfg); len(problems) > 0 {
+		logger.Error("[ERROR] Invalid config:\n%v\n", errors.Join(problems...))
+		os.Exit(1)
+	}
+	if err := applyGlobalOptions(cfg); err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// applyGlobalOptions pushes config-level and flag-level options into the installer.
+// Flags take precedence over the main config, which takes precedence over built-in defaults.
+func applyGlobalOptions(cfg config.Config) error {
+	installer.MaxAge = maxAge
+	installer.Force = force
+	installer.DryRun = dryRun
+	if !dryRun {
+		checkpointer = state.NewCheckpointer(statePath, time.Second)
+		installer.Checkpoint = checkpointer.Save
+	}
+	installer.Jobs = jobs
+	if httpTimeout < 0 {
+		return fmt.Errorf("invalid --http-timeout %s: must not be negative", httpTimeout)
+	}
+	installer.HTTPClient.Timeout = httpTimeout
+	installer.NoCache = noCache
+	installer.NoRestart = noRestart
+	installer.SudoRemoval = sudoRemoval
+	installer.AssumeYes = assumeYes
+	installer.AssumeYesFor[installer.ConfirmSettings] = yesSettings
+	installer.AssumeYesFor[installer.ConfirmUninstall] = yesUninstall
+
+	switch {
+	case githubAPI != "":
+		installer.GitHubAPIBase = githubAPI
+	case cfg.GitHubAPIBase != "":
+		installer.GitHubAPIBase = cfg.GitHubAPIBase
+	}
+
+	installer.BinDirs = binDirs(cfg, binDir, installer.BinDirs)
+
+	// The environment wins over the config so CI can inject a token without editing files
+	installer.GitHubToken = cfg.GitHubToken
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		installer.GitHubToken = token
+	}
+
+	for _, entry := range concurrencyLimits {
+		source, value, err := splitSourceOption("--concurrency", entry)
+		if err != nil {
+			return err
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return fmt.Errorf("invalid --concurrency %q: limit must be a positive integer", entry)
+		}
+		installer.SourceConcurrency[source] = limit
+	}
+
+	for _, entry := range sourceTimeouts {
+		source, value, err := splitSourceOption("--timeout", entry)
+		if err != nil {
+			return err
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid --timeout %q: %v", entry, err)
+		}
+		installer.SourceTimeouts[source] = timeout
+	}
+	return nil
+}
+
+// binDirs returns the bin directories to install into, in order of preference: bin_dirs from
+// the config, or defaults without it. The primary directory (the --bin-dir flag, else the
+// config's bin_dir) goes in front; the remaining directories stay as fallbacks.
+func binDirs(cfg config.Config, flag string, defaults []string) []string {
+	dirs := defaults
+	if len(cfg.BinDirs) > 0 {
+		dirs = cfg.BinDirs
+	}
+	primary := cfg.BinDir
+	if flag != "" {
+		primary = flag
+	}
+	if primary == "" {
+		return dirs
+	}
+	ordered := []string{primary}
+	for _, dir := range dirs {
+		if dir != primary {
+			ordered = append(ordered, dir)
+		}
+	}
+	return ordered
+}
+
+// splitSourceOption splits a per-source flag value of the form source=value.
+func splitSourceOption(flag, entry string) (string, string, error) {
+	source, value, ok := strings.Cut(entry, "=")
+	if !ok || source == "" || value == "" {
+		return "", "", fmt.Errorf("invalid %s %q: expected source=value", flag, entry)
+	}
+	return source, value, nil
+}
+
+// syncRemote copies the binary and config to remoteHost and re-runs the current command there.
+// The invocation's own arguments are forwarded, minus --host and --remote-binary, so flags
+// behave the same remotely.
+// The config directory is recreated as the remote working directory, so --config is replaced
+// by the main config file's name there.
+func syncRemote() {
+	files, err := config.Files(configPath)
+	if err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	configDir := filepath.Dir(configPath)
+	for i, f := range files {
+		if rel, err := filepath.Rel(configDir, f); err == nil {
+			files[i] = rel
+		}
+	}
+
+	// Forward the original arguments without the --host, --remote-binary, and --config flags
+	// and their values
+	var args []string
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if arg == "--host" || arg == "--remote-binary" || arg == "--config" || arg == "-c" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "--host=") || strings.HasPrefix(arg, "--remote-binary=") ||
+			strings.HasPrefix(arg, "--config=") || strings.HasPrefix(arg, "-c=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	args = append(args, "--config", filepath.Base(configPath))
+
+	if err := remote.Sync(remoteHost, remoteBinary, files, args); err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printRemovals lists the tools a sync would uninstall with the steps and strategies that
+// would be used. Nothing is changed and the state file is left untouched.
+func printRemovals(tools []config.Tool) {
+	removals := installer.PreviewRemovals(tools, state.LoadState(statePath))
+	if len(removals) == 0 {
+		fmt.Println("No tools would be uninstalled.")
+		return
+	}
+
+	fmt.Printf("%d tool(s) would be uninstalled:\n", len(removals))
+	for _, r := range removals {
+		fmt.Printf("- %s@%s\n", r.Name, r.Version)
+		for _, step := range r.Steps {
+			fmt.Printf("    %s\n", step)
+		}
+		fmt.Println("    tried in order until one succeeds:")
+		for i, strategy := range r.Strategies {
+			fmt.Printf("      %d. %s\n", i+1, strategy)
+		}
+	}
+}
+
+// finishRun reports the net effect of a run, then saves the updated state and records the run
+// in the history. A dry run changes nothing, so nothing is saved or recorded.
+func finishRun(command string, before, st *state.State, tools []config.Tool) {
+	if dryRun {
+		logger.Info("[DRY-RUN] No changes were made; state not saved.\n")
+		return
+	}
+	if checkpointer != nil {
+		checkpointer.Stop()
+	}
+	reportStateDiff(before, st)
+	state.SaveState(statePath, st)
+	recordHistory(command, before, st, tools)
+}
+
+// filterTools applies --only and --skip to the configured tools. While either is set, tools
+// outside the result are not treated as removed from the config. Naming a tool that isn't
+// configured is an error, so a typo doesn't silently sync nothing.
+func filterTools(tools []config.Tool) []config.Tool {
+	if len(onlyTools) == 0 && len(skipTools) == 0 {
+		return tools
+	}
+	installer.KeepUnlisted = true
+
+	configured := map[string]bool{}
+	for _, t := range tools {
+		configured[t.Name] = true
+	}
+	toSet := func(flag string, names []string) map[string]bool {
+		set := map[string]bool{}
+		for _, name := range names {
+			if !configured[name] {
+				logger.Error("[ERROR] %s: no tool named %q in the config\n", flag, name)
+				os.Exit(1)
+			}
+			set[name] = true
+		}
+		return set
+	}
+	only, skip := toSet("--only", onlyTools), toSet("--skip", skipTools)
+
+	var filtered []config.Tool
+	for _, t := range tools {
+		if (len(only) == 0 || only[t.Name]) && !skip[t.Name] {
+			filtered = append(filtered, t)
+		}
+	}
+	logger.Info("[INFO] Syncing %d of %d tools (filtered with --only/--skip)\n", len(filtered), len(tools))
+	return filtered
+}
+
+// lockState takes the state file lock for a command that writes state, exiting if another run
+// holds it. It returns the function that releases the lock. Dry runs save nothing and don't lock.
+func lockState() func() {
+	if dryRun {
+		return func() {}
+	}
+	unlock, err := state.Lock(statePath)
+	if err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		os.Exit(1)
+	}
+	return unlock
+}
+
+// reportPermissionProblems logs every write-permission problem found by the preflight checks.
+// It returns nil when there were none and the sync may proceed, or an error summarizing them
+// that the command returns so the process exits non-zero.
+func reportPermissionProblems(problems []error) error {
+	for _, p := range problems {
+		logger.Error("[ERROR] %v\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d permission problem(s); no changes were made", len(problems))
+	}
+	return nil
+}
+
+// reportStateDiff prints the net change between the state loaded at the start of a run
+// and the state about to be saved: tools added/updated/removed and settings changed.
+// With --json the diff is emitted as a single JSON document instead.
+func reportStateDiff(before, after *state.State) {
+	diff := state.Compare(before, after)
+
+	if jsonOutput {
+		out, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			logger.Error("[ERROR] Failed to marshal state diff: %v\n", err)
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if diff.Empty() {
+		logger.Info("[INFO] State unchanged.\n")
+		return
+	}
+	logger.Info("[INFO] State changes:\n")
+	for _, line := range diff.Lines() {
+		logger.Info("  %s\n", line)
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/installer"
+)
+
+func TestBinDirs(t *testing.T) {
+	defaults := []string{"/usr/local/bin", "~/bin"}
+	tests := []struct {
+		name string
+		cfg  config.Config
+		flag string
+		want string
+	}{
+		{name: "defaults", want: "/usr/local/bin,~/bin"},
+		{name: "bin_dirs replaces the defaults", cfg: config.Config{BinDirs: []string{"~/.local/bin", "~/bin"}}, want: "~/.local/bin,~/bin"},
+		{name: "bin_dir goes first", cfg: config.Config{BinDir: "/opt/bin"}, want: "/opt/bin,/usr/local/bin,~/bin"},
+		{name: "bin_dir already listed is not repeated", cfg: config.Config{BinDirs: []string{"~/.local/bin", "~/bin"}, BinDir: "~/bin"}, want: "~/bin,~/.local/bin"},
+		{name: "--bin-dir beats bin_dir", cfg: config.Config{BinDir: "/opt/bin"}, flag: "/tmp/bin", want: "/tmp/bin,/usr/local/bin,~/bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(binDirs(tt.cfg, tt.flag, defaults), ","); got != tt.want {
+				t.Errorf("binDirs = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterTools(t *testing.T) {
+	tools := []config.Tool{{Name: "jq"}, {Name: "fd"}, {Name: "rg"}, {Name: "bat"}}
+	tests := []struct {
+		name       string
+		only, skip []string
+		want       string
+		keep       bool
+	}{
+		{name: "no filter", want: "jq,fd,rg,bat"},
+		{name: "only", only: []string{"rg", "jq"}, want: "jq,rg", keep: true},
+		{name: "skip", skip: []string{"fd"}, want: "jq,rg,bat", keep: true},
+		{name: "only and skip", only: []string{"jq", "fd"}, skip: []string{"fd"}, want: "jq", keep: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			only, skip, keep := onlyTools, skipTools, installer.KeepUnlisted
+			t.Cleanup(func() { onlyTools, skipTools, installer.KeepUnlisted = only, skip, keep })
+			onlyTools, skipTools, installer.KeepUnlisted = tt.only, tt.skip, false
+
+			var names []string
+			for _, tool := range filterTools(tools) {
+				names = append(names, tool.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("filterTools = %s, want %s", got, tt.want)
+			}
+			// A filtered list must not make the rest look removed from the config
+			if installer.KeepUnlisted != tt.keep {
+				t.Errorf("KeepUnlisted = %v, want %v", installer.KeepUnlisted, tt.keep)
+			}
+		})
+	}
+}
+
+func TestStdoutIsResult(t *testing.T) {
+	json, output := jsonOutput, scriptOutput
+	t.Cleanup(func() { jsonOutput, scriptOutput = json, output })
+
+	tests := []struct {
+		name   string
+		cmd    *cobra.Command
+		json   bool
+		output string
+		want   bool
+	}{
+		{name: "sync", cmd: syncCmd},
+		{name: "sync --json", cmd: syncCmd, json: true, want: true},
+		{name: "sync tools --json", cmd: syncToolsCmd, json: true, want: true},
+		{name: "history --json", cmd: historyCmd, json: true, want: true},
+		{name: "generate-script", cmd: generateScriptCmd, want: true},
+		{name: "generate-script --output", cmd: generateScriptCmd, output: "plan.sh"},
+	}
+	for _, tt := range tests {
+		jsonOutput, scriptOutput = tt.json, tt.output
+		if got := stdoutIsResult(tt.cmd); got != tt.want {
+			t.Errorf("%s: stdoutIsResult = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeSyncConfig writes a config whose aliases go to HOME/.zshrc, with extra appended to the
+// config: section, and points configPath and statePath at it for the duration of the test.
+func writeSyncConfig(t *testing.T, home, extra string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml":   "config:\n  tools_file: tools.yaml\n  settings_file: settings.yaml\n  aliases_file: aliases.yaml\n" + extra,
+		"tools.yaml":    "",
+		"settings.yaml": "",
+		"aliases.yaml":  "aliases:\n  shell: zsh\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	config, state := configPath, statePath
+	t.Cleanup(func() { configPath, statePath = config, state })
+	configPath, statePath = filepath.Join(dir, "config.yaml"), filepath.Join(dir, "state.json")
+}
+
+func TestSyncFailsOnPermissionProblems(t *testing.T) {
+	// A directory where the rc file should be can't be appended to, even by root
+	home := t.TempDir()
+	if err := os.Mkdir(filepath.Join(home, ".zshrc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeSyncConfig(t, home, "")
+
+	for _, cmd := range []*cobra.Command{syncCmd, syncAliasesCmd} {
+		if err := cmd.RunE(cmd, nil); err == nil || !strings.Contains(err.Error(), "permission problem") {
+			t.Errorf("%s: err = %v, want the permission problems reported", cmd.CommandPath(), err)
+		}
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file was written despite the permission problems: %v", err)
+	}
+}
+
+func TestSyncFailsWhenPreSyncHookFails(t *testing.T) {
+	writeSyncConfig(t, t.TempDir(), "  pre_sync:\n    - exit 3\n")
+
+	err := syncCmd.RunE(syncCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "aborting sync") {
+		t.Fatalf("err = %v, want the failed pre_sync hook to abort the sync", err)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file was written despite the failed pre_sync hook: %v", err)
+	}
+}
+
+func TestMigratesLegacyState(t *testing.T) {
+	host := remoteHost
+	t.Cleanup(func() { remoteHost = host })
+
+	for _, tt := range []struct {
+		cmd  *cobra.Command
+		host string
+		want bool
+	}{
+		{cmd: syncCmd, want: true},
+		{cmd: syncToolsCmd, want: true},
+		{cmd: statusCmd, want: true},
+		{cmd: syncCmd, host: "me@example.com", want: false},
+		{cmd: historyCmd, want: false},
+		{cmd: generateScriptCmd, want: false},
+	} {
+		remoteHost = tt.host
+		if got := migratesLegacyState(tt.cmd); got != tt.want {
+			t.Errorf("migratesLegacyState(%s) with host %q = %v, want %v", tt.cmd.CommandPath(), tt.host, got, tt.want)
+		}
+	}
+}
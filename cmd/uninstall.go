@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/installer"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// uninstallCmd removes a single tool recorded in the state file, without editing the config
+// or syncing anything else.
+var uninstallCmd = &cobra.Command{
+	Use:     "uninstall <tool>",
+	Aliases: []string{"remove"},
+	Short:   "Uninstall a single tool and remove it from state",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		installer.DryRun = dryRun
+		installer.AssumeYesFor[installer.ConfirmUninstall] = assumeYes
+		installer.SudoRemoval = sudoRemoval
+		defer lockState()()
+
+		st := state.LoadState(statePath)
+		before := st.Clone()
+
+		if err := installer.UninstallTool(name, st); err != nil {
+			logger.Error("[ERROR] %v\n", err)
+			os.Exit(1)
+		}
+
+		// A tool that is still configured comes back on the next sync
+		if cfg, err := config.LoadConfig(configPath); err == nil {
+			for _, tool := range cfg.Tools {
+				if tool.Name == name && tool.IsEnabled() {
+					logger.Warn("[WARN] %s is still in %s and will be reinstalled by the next sync\n", name, configPath)
+				}
+			}
+		}
+
+		finishRun("uninstall", before, st, nil)
+	},
+}
+
+func init() {
+	uninstallCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	uninstallCmd.Flags().BoolVar(&sudoRemoval, "sudo-removal", false, "Fall back to deleting /usr/local/bin/<tool> with sudo rm -f (confirmed)")
+	uninstallCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Approve the sudo removal without prompting")
+	uninstallCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Log how the tool would be removed without changing anything")
+	rootCmd.AddCommand(uninstallCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+// validateCmd parses the config and all sub-configs and reports structural problems,
+// without touching the system. It exits non-zero when any are found.
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config for problems without applying it",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Error("[ERROR] %v\n", err)
+			os.Exit(1)
+		}
+
+		problems := config.Validate(cfg)
+		for _, p := range problems {
+			logger.Error("[ERROR] %v\n", p)
+		}
+		if len(problems) > 0 {
+			logger.Error("[ERROR] Found %d problem(s) in %s\n", len(problems), configPath)
+			os.Exit(1)
+		}
+		logger.Info("[INFO] %s is valid (%d tools, %d settings, %d aliases)\n",
+			configPath, len(cfg.Tools), len(cfg.Settings), len(cfg.Aliases.Entries))
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&configPath, "config", "c", "", configUsage)
+	rootCmd.AddCommand(validateCmd)
+}
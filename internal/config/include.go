@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includeDirective is the optional `include:` list that any sub-config file may contain.
+// Each entry is a path to another file of the same kind, resolved relative to the including file.
+type includeDirective struct {
+	Include []string `yaml:"include"`
+}
+
+// loadWithIncludes reads the YAML file at path, passes its contents to visit, then recursively
+// does the same for every file listed in its `include:` directive. Entries from included files
+// therefore come after the including file's own entries. It returns every file that was read.
+// stack holds the absolute paths currently being loaded and is used to detect include cycles.
+func loadWithIncludes(path string, stack []string, visit func(data []byte) error) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("include cycle detected: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := visit(data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+
+	var directive includeDirective
+	if err := yaml.Unmarshal(data, &directive); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+
+	files := []string{path}
+	for _, inc := range directive.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		included, err := loadWithIncludes(inc, append(stack, abs), visit)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, included...)
+	}
+	return files, nil
+}
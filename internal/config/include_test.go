@@ -0,0 +1,82 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFollowsNestedIncludes(t *testing.T) {
+	path := writeConfig(t, map[string]string{
+		"tools.yaml":         "include: [tools/cli.yaml]\ntools:\n  - name: jq\n    source: brew\n",
+		"tools/cli.yaml":     "include: [lang/go.yaml, ../extra.yaml]\ntools:\n  - name: fd\n    source: brew\n",
+		"tools/lang/go.yaml": "tools:\n  - name: gopls\n    source: brew\n",
+		"extra.yaml":         "tools:\n  - name: rg\n    source: brew\n",
+		"aliases.yaml":       "include: [more-aliases.yaml]\naliases:\n  shell: zsh\n  entries:\n    - name: gs\n      value: git status\n",
+		"more-aliases.yaml":  "aliases:\n  shell: bash\n  entries:\n    - name: ll\n      value: ls -al\n",
+	})
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range cfg.Tools {
+		names = append(names, tool.Name)
+	}
+	// Each file's own entries come before those of the files it includes
+	if got := strings.Join(names, ","); got != "jq,fd,gopls,rg" {
+		t.Errorf("tools = %s, want jq,fd,gopls,rg", got)
+	}
+	if cfg.Aliases.Shell != "zsh" || len(cfg.Aliases.Entries) != 2 {
+		t.Errorf("aliases = %+v, want the including file's shell and both entries", cfg.Aliases)
+	}
+
+	files, err := Files(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, f := range files {
+		r, _ := filepath.Rel(filepath.Dir(path), f)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	want := "config.yaml,tools.yaml,tools/cli.yaml,tools/lang/go.yaml,extra.yaml,settings.yaml,aliases.yaml,more-aliases.yaml"
+	if got := strings.Join(rel, ","); got != want {
+		t.Errorf("Files = %s, want %s", got, want)
+	}
+}
+
+func TestLoadConfigDetectsIncludeCycles(t *testing.T) {
+	tests := map[string]map[string]string{
+		"self": {
+			"tools.yaml": "include: [tools.yaml]\n",
+		},
+		"indirect": {
+			"tools.yaml": "include: [a/one.yaml]\n",
+			"a/one.yaml": "include: [../b/two.yaml]\n",
+			"b/two.yaml": "include: [../tools.yaml]\n",
+		},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, files))
+			if err == nil || !strings.Contains(err.Error(), "include cycle detected") {
+				t.Errorf("LoadConfig error = %v, want an include cycle", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigAllowsIncludingAFileTwice(t *testing.T) {
+	// A shared file included from two siblings is not a cycle
+	path := writeConfig(t, map[string]string{
+		"tools.yaml":  "include: [a.yaml, b.yaml]\n",
+		"a.yaml":      "include: [common.yaml]\n",
+		"b.yaml":      "include: [common.yaml]\n",
+		"common.yaml": "tools:\n  - name: jq\n    source: brew\n",
+	})
+	if _, err := LoadConfig(path); err != nil {
+		t.Errorf("LoadConfig: %v", err)
+	}
+}
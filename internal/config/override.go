@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ApplyOverrides applies `path=value` overrides (from `--set`) to a loaded config.
+// Paths have the form <section>.<name>.<field>, where name may itself contain dots:
+//
+//	tools.jq.version=1.7.1
+//	settings.com.apple.dock:autohide.value=true
+//	aliases.gs.value=git status -sb
+//	aliases.shell=bash
+//
+// Field names are the same as in the YAML files. Only scalar fields can be overridden.
+// An error is returned if a path doesn't resolve to an existing entry and field.
+func ApplyOverrides(cfg *Config, overrides []string) error {
+	for _, o := range overrides {
+		path, value, ok := strings.Cut(o, "=")
+		if !ok {
+			return fmt.Errorf("invalid override %q: expected path=value", o)
+		}
+		if err := applyOverride(cfg, path, value); err != nil {
+			return fmt.Errorf("invalid override %q: %w", o, err)
+		}
+	}
+	return nil
+}
+
+// applyOverride resolves a single dot-path to a struct field and sets it.
+func applyOverride(cfg *Config, path, value string) error {
+	section, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return fmt.Errorf("path must be <section>.<name>.<field>")
+	}
+
+	// aliases.shell is the one section-level scalar
+	if section == "aliases" && rest == "shell" {
+		cfg.Aliases.Shell = value
+		return nil
+	}
+
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 {
+		return fmt.Errorf("path must be <section>.<name>.<field>")
+	}
+	name, field := rest[:i], rest[i+1:]
+
+	switch section {
+	case "tools":
+		for idx := range cfg.Tools {
+			if cfg.Tools[idx].Name == name {
+				return setField(reflect.ValueOf(&cfg.Tools[idx]).Elem(), field, value)
+			}
+		}
+		return fmt.Errorf("no tool named %q", name)
+	case "settings":
+		for idx := range cfg.Settings {
+			s := cfg.Settings[idx]
+			if s.ID() == name {
+				return setField(reflect.ValueOf(&cfg.Settings[idx]).Elem(), field, value)
+			}
+		}
+		return fmt.Errorf("no setting %q (use domain:key, plus @currentHost/@sudo if set)", name)
+	case "aliases":
+		for idx := range cfg.Aliases.Entries {
+			if cfg.Aliases.Entries[idx].Name == name {
+				return setField(reflect.ValueOf(&cfg.Aliases.Entries[idx]).Elem(), field, value)
+			}
+		}
+		return fmt.Errorf("no alias named %q", name)
+	default:
+		return fmt.Errorf("unknown section %q (expected tools, settings, or aliases)", section)
+	}
+}
+
+// setField sets the scalar field of v whose YAML name is field, parsing value to its type.
+func setField(v reflect.Value, field, value string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) != field {
+			continue
+		}
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.String:
+			f.SetString(value)
+		case f.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("field %s expects a bool: %w", field, err)
+			}
+			f.SetBool(b)
+		case f.Kind() == reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("field %s expects an integer: %w", field, err)
+			}
+			f.SetInt(int64(n))
+		case f.Kind() == reflect.Pointer && f.Type().Elem().Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("field %s expects a bool: %w", field, err)
+			}
+			f.Set(reflect.ValueOf(&b))
+		default:
+			return fmt.Errorf("field %s is not a scalar and cannot be overridden", field)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown field %q", field)
+}
+
+// yamlName returns the key a struct field uses in YAML: its yaml tag name if present,
+// otherwise the lowercased field name (yaml.v3's default).
+func yamlName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("yaml"); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(f.Name)
+}
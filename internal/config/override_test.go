@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// overrideConfig returns a config with one tool, one setting and one alias to override.
+func overrideConfig() Config {
+	return Config{
+		Tools:    []Tool{{Name: "jq", Source: "github", Version: "1.7.0"}, {Name: "golangci/golangci-lint", Source: "github", Version: "1.55.0"}},
+		Settings: []Setting{{Domain: "com.apple.dock", Key: "autohide", Type: "bool", Value: "false"}},
+		Aliases:  Aliases{Shell: "zsh", Entries: []Alias{{Name: "gs", Value: "git status"}}},
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	cfg := overrideConfig()
+	err := ApplyOverrides(&cfg, []string{
+		"tools.jq.version=1.7.1",
+		"tools.jq.critical=true",
+		"tools.jq.enabled=false",
+		"tools.jq.api_base=https://ghe.example.com/api/v3",
+		"tools.golangci/golangci-lint.version=1.59.1",
+		"settings.com.apple.dock:autohide.value=true",
+		"aliases.gs.value=git status -sb",
+		"aliases.shell=bash",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jq := cfg.Tools[0]
+	if jq.Version != "1.7.1" || !jq.Critical || jq.IsEnabled() || jq.APIBase != "https://ghe.example.com/api/v3" {
+		t.Errorf("jq = %+v, want version 1.7.1, critical, disabled, with the api_base", jq)
+	}
+	if cfg.Tools[1].Version != "1.59.1" {
+		t.Errorf("golangci-lint version = %s, want 1.59.1", cfg.Tools[1].Version)
+	}
+	if cfg.Settings[0].Value != "true" {
+		t.Errorf("autohide = %s, want true", cfg.Settings[0].Value)
+	}
+	if cfg.Aliases.Entries[0].Value != "git status -sb" || cfg.Aliases.Shell != "bash" {
+		t.Errorf("aliases = %+v, want gs overridden and shell bash", cfg.Aliases)
+	}
+}
+
+func TestApplyOverridesRejectsBadPaths(t *testing.T) {
+	tests := map[string]string{
+		"tools.jq.version":          "expected path=value",
+		"tools.fd.version=1":        `no tool named "fd"`,
+		"tools.jq.taps=a":           "not a scalar",
+		"tools.jq.nope=1":           `unknown field "nope"`,
+		"tools.jq.critical=maybe":   "expects a bool",
+		"settings.autohide.value=1": "no setting",
+		"aliases.ll.value=ls":       `no alias named "ll"`,
+		"packages.jq.version=1":     `unknown section "packages"`,
+		"tools=1":                   "<section>.<name>.<field>",
+	}
+	for override, want := range tests {
+		cfg := overrideConfig()
+		err := ApplyOverrides(&cfg, []string{override})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ApplyOverrides(%q) = %v, want an error mentioning %q", override, err, want)
+		}
+	}
+}
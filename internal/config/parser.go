@@ -1,41 +1,196 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"setup-machine/internal/version"
+	"strings"
 )
 
 // Config is the top-level structure returned after loading all YAML configurations.
-// It contains parsed data for tools, macOS settings, and shell aliases.
+// It contains parsed data for tools, macOS settings, and shell aliases,
+// plus the whole-run hooks declared in the main config.
 type Config struct {
 	Tools    []Tool
 	Settings []Setting
 	Aliases  Aliases
+	PreSync  []string // Shell commands run before a full sync; a failure aborts the sync
+	PostSync []string // Shell commands run after a full sync; failures are reported as warnings
+
+	GitHubAPIBase string // Default GitHub API base URL for github tools (empty means api.github.com)
+	GitHubToken   string // Token for GitHub API requests; the GITHUB_TOKEN environment variable takes precedence
+
+	BinDirs []string // Candidate install directories for binaries, tried in order (empty means the built-in default)
+	BinDir  string   // Primary install directory, tried before BinDirs (which remain the fallbacks)
 }
 
 // Tool represents a CLI tool or binary to be managed by the setup tool.
 // - Name: Logical name for the tool.
-// - Version: Version to install.
+// - Version: Version to install; empty or "latest" tracks the newest release, and a range like `>=1.2 <2` or `~1.4` the highest matching one (github tools only).
 // - Source/URL/Repo/Tag: Used for resolving installation method (e.g., GitHub, custom URL, etc.).
+// - Enabled: Set to false to temporarily disable the tool without removing it (defaults to true).
+// - Critical: Verify the installed binary (existence + checksum) on every sync and reinstall it if broken.
+// - Launcher: Wrapper script template ({{install_dir}}, {{asset}}) for non-native artifacts, e.g. `exec java -jar {{asset}} "$@"`.
+// - APIBase: GitHub API base URL for this tool, e.g. https://ghe.example.com/api/v3 (GitHub Enterprise).
+// - Taps: Homebrew taps (owner/repo) that must be tapped before installing a brew tool.
+// - Cask: Install the brew tool as a cask (GUI apps, `brew install --cask`) instead of a formula.
+// - PreInstall/PostInstall: Shell commands run before/after the tool is installed or upgraded (not when it is current).
+// - HooksRequired: Treat a failing pre_install/post_install command as a failed install instead of a warning.
+// - Trusted: Allow the script source to run the install script downloaded from URL; it runs arbitrary code.
+// - Args/Env: Arguments and environment variables passed to an install script (script source).
+// - Files: Config files/dotfiles to place alongside the tool (e.g. into ~/.config/<tool>/).
+// - RequireApproval: Ask the user to acknowledge the tool's license (LicenseURL) before its first install.
+// - Checksum: Expected SHA256 of the download; empty auto-detects a release checksums file, "skip" disables verification.
+// - Binaries: Executables to install from an archive (github/url sources), by file name; empty installs every executable named like the tool.
+// - Spec: The `source:name[@version]` shorthand of a tool added with `setup-machine install` instead of the config; not read from YAML.
+// - AssetPattern: Glob selecting the GitHub release asset, e.g. `tool_{version}_macos_universal.zip`; {version}, {os}, {arch} are expanded.
 type Tool struct {
-	Name    string
-	Version string
+	Name     string
+	Version  string
+	Source   string
+	URL      string
+	Repo     string
+	Tag      string
+	Enabled  *bool
+	Critical bool
+	Launcher string
+	APIBase  string `yaml:"api_base"`
+	Taps     []string
+	Cask     bool
+	Files    []FileSpec
+	Checksum string
+	Binaries []string
+
+	PreInstall    []string `yaml:"pre_install"`
+	PostInstall   []string `yaml:"post_install"`
+	HooksRequired bool     `yaml:"hooks_required"`
+
+	Trusted bool
+	Args    []string
+	Env     map[string]string
+
+	RequireApproval bool   `yaml:"require_approval"`
+	LicenseURL      string `yaml:"license_url"`
+	AssetPattern    string `yaml:"asset_pattern"`
+
+	Spec string `yaml:"-"`
+}
+
+// FileSpec describes a file managed alongside a tool, such as its config in ~/.config.
+// Exactly one of Content (inline) or Source (URL) provides the data. The data may use the
+// {{name}}, {{version}}, {{home}}, and {{install_path}} placeholders. Dest may start with ~.
+type FileSpec struct {
 	Source  string
-	URL     string
-	Repo    string
-	Tag     string
+	Content string
+	Dest    string
+}
+
+// IsEnabled reports whether the tool should be synced. Tools are enabled unless
+// `enabled: false` is set explicitly.
+func (t Tool) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// WantsLatest reports whether the tool tracks the newest release instead of a pinned
+// version, i.e. its version is empty or "latest".
+func (t Tool) WantsLatest() bool {
+	return t.Version == "" || strings.EqualFold(t.Version, "latest")
 }
 
 // Setting represents a macOS `defaults` system setting.
 // - Domain: macOS domain (e.g., com.apple.finder).
 // - Key: Specific setting key.
-// - Value: Desired setting value as a string.
-// - Type: Value type ("bool", "int", "string", "float").
+// - Value: Desired setting value as a string, or a YAML list/map for the array, array-add, and dict types.
+// - Type: Value type ("bool", "int", "string", "float", "array", "array-add", "dict").
+// - Enabled: Set to false to temporarily disable the setting without removing it (defaults to true).
+// - After: Optional "domain:key" of another setting that must be applied before this one.
+// - ApplyOnce: Apply only on first setup; afterwards the system value is left alone (unless --force).
+// - Restart: App to `killall` after the setting changes; inferred for common domains (Finder, Dock), "none" disables it.
+// - CurrentHost: Write the per-host preference (`defaults -currentHost`), e.g. for display settings.
+// - Sudo: Write the system-wide preference in /Library/Preferences as root (`sudo defaults`).
+//
+// Settings are applied in config order unless After requires otherwise.
 type Setting struct {
-	Domain string
-	Key    string
-	Value  string
-	Type   string
+	Domain      string
+	Key         string
+	Value       string
+	Type        string
+	Enabled     *bool
+	After       string
+	ApplyOnce   bool `yaml:"apply_once"`
+	Restart     string
+	CurrentHost bool `yaml:"current_host"`
+	Sudo        bool
+
+	Items []string          `yaml:"-"` // List value of an array/array-add setting; Value then holds it as JSON
+	Dict  map[string]string `yaml:"-"` // Map value of a dict setting; Value then holds it as JSON
+}
+
+// UnmarshalYAML decodes a setting, accepting a list or map for `value` (array and dict
+// settings). Structured values are kept in Items/Dict, and Value is set to their JSON form so
+// state comparisons and log messages keep working with a single string.
+func (s *Setting) UnmarshalYAML(node *yaml.Node) error {
+	type plain Setting // Same fields without this method, so decoding doesn't recurse
+
+	var structured *yaml.Node
+	decoded := *node
+	if node.Kind == yaml.MappingNode {
+		decoded.Content = append([]*yaml.Node(nil), node.Content...)
+		for i := 0; i+1 < len(decoded.Content); i += 2 {
+			v := decoded.Content[i+1]
+			if decoded.Content[i].Value == "value" && (v.Kind == yaml.SequenceNode || v.Kind == yaml.MappingNode) {
+				structured = v
+				decoded.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+			}
+		}
+	}
+	if err := decoded.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	if structured == nil {
+		return nil
+	}
+
+	var err error
+	var canonical []byte
+	if structured.Kind == yaml.SequenceNode {
+		if err = structured.Decode(&s.Items); err == nil {
+			canonical, err = json.Marshal(s.Items)
+		}
+	} else {
+		if err = structured.Decode(&s.Dict); err == nil {
+			canonical, err = json.Marshal(s.Dict) // Keys are sorted, so the form is stable
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("setting %s:%s: value must be a flat list or map of scalars: %w", s.Domain, s.Key, err)
+	}
+	s.Value = string(canonical)
+	return nil
+}
+
+// IsEnabled reports whether the setting should be applied. Settings are enabled unless
+// `enabled: false` is set explicitly.
+func (s Setting) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// ID returns the unique identifier of a setting: "domain:key", suffixed with "@currentHost"
+// and/or "@sudo" when set, since those address a different preference file than the plain key.
+// It is used as the state key and in `after` references.
+func (s Setting) ID() string {
+	id := s.Domain + ":" + s.Key
+	if s.CurrentHost {
+		id += "@currentHost"
+	}
+	if s.Sudo {
+		id += "@sudo"
+	}
+	return id
 }
 
 // Aliases holds shell-specific alias definitions.
@@ -58,70 +213,170 @@ type Alias struct {
 	Value string
 }
 
-// LoadConfig reads the main config.yaml file and the three referenced sub-configs:
-// tools.yaml, settings.yaml, and aliases.yaml. It returns a populated Config struct.
-func LoadConfig(configFile string) Config {
-	// mainConfig holds the paths to tools, settings, and aliases config files
-	mainConfig := struct {
-		Config struct {
-			ToolsFile    string `yaml:"tools_file"`
-			SettingsFile string `yaml:"settings_file"`
-			AliasesFile  string `yaml:"aliases_file"`
-		} `yaml:"config"`
-	}{}
+// mainConfigFile mirrors the structure of the main config.yaml, which holds
+// the paths to the tools, settings, and aliases config files.
+type mainConfigFile struct {
+	Config struct {
+		ToolsFile    string   `yaml:"tools_file"`
+		SettingsFile string   `yaml:"settings_file"`
+		AliasesFile  string   `yaml:"aliases_file"`
+		PreSync      []string `yaml:"pre_sync"`
+		PostSync     []string `yaml:"post_sync"`
+		MinVersion   string   `yaml:"min_version"`
+		GitHubAPI    string   `yaml:"github_api_base"`
+		GitHubToken  string   `yaml:"github_token"`
+		BinDirs      []string `yaml:"bin_dirs"`
+		BinDir       string   `yaml:"bin_dir"`
+	} `yaml:"config"`
+}
 
-	// Read and parse the main config.yaml which holds metadata (paths to other YAMLs)
+// readMainConfig reads and parses the main config.yaml.
+func readMainConfig(configFile string) (mainConfigFile, error) {
+	var mainConfig mainConfigFile
 	raw, err := os.ReadFile(configFile)
 	if err != nil {
-		panic("Failed to read config.yaml: " + err.Error())
+		return mainConfig, fmt.Errorf("failed to read config.yaml: %w", err)
 	}
 	if err := yaml.Unmarshal(raw, &mainConfig); err != nil {
-		panic("Failed to unmarshal config.yaml: " + err.Error())
+		return mainConfig, fmt.Errorf("failed to unmarshal config.yaml: %w", err)
 	}
+	return mainConfig, nil
+}
 
-	// ----- Load tools.yaml -----
-	toolsData, err := os.ReadFile(mainConfig.Config.ToolsFile)
-	if err != nil {
-		panic("Failed to read tools.yaml: " + err.Error())
+// checkMinVersion returns an error when the config requires a newer setup-machine than
+// the running binary. Development builds are not versioned and always pass.
+func checkMinVersion(minVersion string) error {
+	if minVersion == "" || version.IsDev() {
+		return nil
+	}
+	if version.Compare(version.Version, minVersion) < 0 {
+		return fmt.Errorf("config requires setup-machine >= %s, but this is %s; please upgrade", minVersion, version.Version)
 	}
-	var toolsWrapper struct {
-		Tools []Tool `yaml:"tools"`
+	return nil
+}
+
+// Files returns the main config file followed by every sub-config file it references,
+// including files pulled in through `include:` directives.
+// This is the full set of files needed to reproduce the configuration elsewhere.
+func Files(configFile string) ([]string, error) {
+	mainConfig, err := readMainConfig(configFile)
+	if err != nil {
+		return nil, err
 	}
-	if err := yaml.Unmarshal(toolsData, &toolsWrapper); err != nil {
-		panic("Failed to unmarshal tools.yaml: " + err.Error())
+
+	files := []string{configFile}
+	for _, sub := range []string{mainConfig.Config.ToolsFile, mainConfig.Config.SettingsFile, mainConfig.Config.AliasesFile} {
+		subFiles, err := loadWithIncludes(resolveSubPath(configFile, sub), nil, func([]byte) error { return nil })
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, subFiles...)
 	}
+	return files, nil
+}
 
-	// ----- Load settings.yaml -----
-	// This expects the structure: settings: { macos: [ {domain, key, value, type}, ... ] }
-	settingsData, err := os.ReadFile(mainConfig.Config.SettingsFile)
+// Hash returns a SHA256 over the contents of the main config and all its sub-configs,
+// identifying exactly which configuration a run was performed with.
+func Hash(configFile string) (string, error) {
+	files, err := Files(configFile)
 	if err != nil {
-		panic("Failed to read settings.yaml: " + err.Error())
+		return "", err
+	}
+	h := sha256.New()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
 	}
-	var settingsWrapper struct {
-		Settings struct {
-			MacOS []Setting `yaml:"macos"`
-		} `yaml:"settings"`
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadConfig reads the main config.yaml file and the three referenced sub-configs:
+// tools.yaml, settings.yaml, and aliases.yaml, whose relative paths are resolved against the
+// directory of the main config file. It returns a populated Config struct,
+// or an error describing the first file that could not be read or parsed.
+func LoadConfig(configFile string) (Config, error) {
+	// Read and parse the main config.yaml which holds metadata (paths to other YAMLs)
+	mainConfig, err := readMainConfig(configFile)
+	if err != nil {
+		return Config{}, err
 	}
-	if err := yaml.Unmarshal(settingsData, &settingsWrapper); err != nil {
-		panic("Failed to unmarshal settings.yaml: " + err.Error())
+
+	// Refuse to run a config authored for newer features than this binary understands
+	if err := checkMinVersion(mainConfig.Config.MinVersion); err != nil {
+		return Config{}, err
 	}
 
-	// ----- Load aliases.yaml -----
-	aliasesData, err := os.ReadFile(mainConfig.Config.AliasesFile)
+	// ----- Load tools.yaml (and any files it includes) -----
+	var tools []Tool
+	_, err = loadWithIncludes(resolveSubPath(configFile, mainConfig.Config.ToolsFile), nil, func(data []byte) error {
+		var toolsWrapper struct {
+			Tools []Tool `yaml:"tools"`
+		}
+		if err := yaml.Unmarshal(data, &toolsWrapper); err != nil {
+			return err
+		}
+		tools = append(tools, toolsWrapper.Tools...)
+		return nil
+	})
 	if err != nil {
-		panic("Failed to read aliases.yaml: " + err.Error())
+		return Config{}, fmt.Errorf("failed to load tools.yaml: %w", err)
 	}
-	var aliasesWrapper struct {
-		Aliases Aliases `yaml:"aliases"`
+
+	// ----- Load settings.yaml (and any files it includes) -----
+	// This expects the structure: settings: { macos: [ {domain, key, value, type}, ... ] }
+	var settings []Setting
+	_, err = loadWithIncludes(resolveSubPath(configFile, mainConfig.Config.SettingsFile), nil, func(data []byte) error {
+		var settingsWrapper struct {
+			Settings struct {
+				MacOS []Setting `yaml:"macos"`
+			} `yaml:"settings"`
+		}
+		if err := yaml.Unmarshal(data, &settingsWrapper); err != nil {
+			return err
+		}
+		settings = append(settings, settingsWrapper.Settings.MacOS...)
+		return nil
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to load settings.yaml: %w", err)
 	}
-	if err := yaml.Unmarshal(aliasesData, &aliasesWrapper); err != nil {
-		panic("Failed to unmarshal aliases.yaml: " + err.Error())
+
+	// ----- Load aliases.yaml (and any files it includes) -----
+	// The shell is taken from the first file that sets it; raw configs and entries are concatenated.
+	var aliases Aliases
+	_, err = loadWithIncludes(resolveSubPath(configFile, mainConfig.Config.AliasesFile), nil, func(data []byte) error {
+		var aliasesWrapper struct {
+			Aliases Aliases `yaml:"aliases"`
+		}
+		if err := yaml.Unmarshal(data, &aliasesWrapper); err != nil {
+			return err
+		}
+		if aliases.Shell == "" {
+			aliases.Shell = aliasesWrapper.Aliases.Shell
+		}
+		aliases.RawConfigs = append(aliases.RawConfigs, aliasesWrapper.Aliases.RawConfigs...)
+		aliases.Entries = append(aliases.Entries, aliasesWrapper.Aliases.Entries...)
+		return nil
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to load aliases.yaml: %w", err)
 	}
 
 	// Assemble and return the full config object
 	return Config{
-		Tools:    toolsWrapper.Tools,
-		Settings: settingsWrapper.Settings.MacOS,
-		Aliases:  aliasesWrapper.Aliases,
-	}
+		Tools:    tools,
+		Settings: settings,
+		Aliases:  aliases,
+		PreSync:  mainConfig.Config.PreSync,
+		PostSync: mainConfig.Config.PostSync,
+
+		GitHubAPIBase: mainConfig.Config.GitHubAPI,
+		GitHubToken:   mainConfig.Config.GitHubToken,
+
+		BinDirs: mainConfig.Config.BinDirs,
+		BinDir:  mainConfig.Config.BinDir,
+	}, nil
 }
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/version"
+)
+
+// writeConfig writes files (name -> contents) into a temp directory and returns the path of
+// its config.yaml. A missing config.yaml points at tools.yaml, settings.yaml and aliases.yaml.
+func writeConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, ok := files["config.yaml"]; !ok {
+		files["config.yaml"] = "config:\n  tools_file: tools.yaml\n  settings_file: settings.yaml\n  aliases_file: aliases.yaml\n"
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"tools.yaml", "settings.yaml", "aliases.yaml"} {
+		if _, ok := files[name]; !ok {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+// setVersion pretends the running binary is release v for the duration of a test.
+func setVersion(t *testing.T, v string) {
+	t.Helper()
+	orig := version.Version
+	version.Version = v
+	t.Cleanup(func() { version.Version = orig })
+}
+
+func TestLoadConfigMinVersion(t *testing.T) {
+	tests := []struct {
+		running string
+		min     string
+		wantErr bool
+	}{
+		{running: "1.4.0", min: "1.3", wantErr: false},
+		{running: "1.4.0", min: "1.4.0", wantErr: false},
+		{running: "v1.4.0", min: "v1.4", wantErr: false},
+		{running: "1.4.0", min: "1.10.0", wantErr: true},
+		{running: "1.4.0", min: "99.0", wantErr: true},
+		{running: "dev", min: "99.0", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		setVersion(t, tt.running)
+		path := writeConfig(t, map[string]string{
+			"config.yaml": "config:\n  min_version: \"" + tt.min + "\"\n  tools_file: tools.yaml\n  settings_file: settings.yaml\n  aliases_file: aliases.yaml\n",
+		})
+		_, err := LoadConfig(path)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "please upgrade") {
+				t.Errorf("setup-machine %s with min_version %s: err = %v, want an upgrade error", tt.running, tt.min, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("setup-machine %s with min_version %s: %v", tt.running, tt.min, err)
+		}
+	}
+}
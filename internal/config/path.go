@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LocalPath is the config file looked for in the current directory, the last place searched.
+const LocalPath = "config.yaml"
+
+// SearchPaths returns where the main config file is looked for when none is given, in order:
+// $XDG_CONFIG_HOME/setup-machine/config.yaml, ~/.config/setup-machine/config.yaml, and
+// ./config.yaml.
+func SearchPaths() []string {
+	var paths []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "setup-machine", "config.yaml"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "setup-machine", "config.yaml"))
+	}
+	return append(paths, LocalPath)
+}
+
+// Discover returns the first of SearchPaths that exists. When none does, it returns
+// LocalPath, so the error from loading it names the file users most likely expected.
+func Discover() string {
+	for _, path := range SearchPaths() {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return LocalPath
+}
+
+// resolveSubPath resolves a path from the main config (tools_file, ...) relative to the
+// directory of the main config file, so a config directory works wherever it is and from
+// whichever directory setup-machine runs. Absolute and empty paths are returned as-is.
+func resolveSubPath(configFile, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseToolSpec builds a tool from the `source:name[@version]` shorthand of the install
+// command, e.g. github:sharkdp/bat@v0.24.0, npm:@scope/pkg@1.2.0, or pipx:httpie.
+//   - github versions may carry the tag's "v" prefix; it is dropped, as in tools.yaml.
+//   - brew has no version suffix: versioned formulas are separate names (python@3.12).
+//   - url takes the download URL as the spec; the tool name must then be set by the caller.
+//
+// The spec is kept in Tool.Spec so the tool can be rebuilt from the state file later.
+func ParseToolSpec(spec string) (Tool, error) {
+	source, rest, ok := strings.Cut(spec, ":")
+	if !ok || rest == "" {
+		return Tool{}, fmt.Errorf("invalid tool %q: expected source:name[@version], e.g. github:owner/repo@v1.2.3", spec)
+	}
+	if !validSources[source] {
+		return Tool{}, fmt.Errorf("invalid tool %q: unknown source %q (want one of %s)", spec, source, strings.Join(sourceNames(), ", "))
+	}
+
+	tool := Tool{Source: source, Spec: spec}
+	switch source {
+	case "url":
+		tool.URL = rest
+		return tool, nil
+	case "brew":
+		tool.Name = rest
+		return tool, nil
+	}
+
+	// Split off the version at the last "@"; a leading "@" belongs to an npm scope
+	tool.Name = rest
+	if i := strings.LastIndex(rest, "@"); i > 0 {
+		tool.Name, tool.Version = rest[:i], rest[i+1:]
+		if tool.Version == "" {
+			return Tool{}, fmt.Errorf("invalid tool %q: empty version after @", spec)
+		}
+	}
+	if source == "github" && len(tool.Version) > 1 && tool.Version[0] == 'v' && tool.Version[1] >= '0' && tool.Version[1] <= '9' {
+		tool.Version = tool.Version[1:]
+	}
+	return tool, nil
+}
+
+// sourceNames returns the known tool sources, sorted.
+func sourceNames() []string {
+	names := make([]string, 0, len(validSources))
+	for name := range validSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"setup-machine/internal/version"
+	"strings"
+)
+
+// validSources are the tool sources the installer knows how to handle.
+var validSources = map[string]bool{"github": true, "url": true, "brew": true, "npm": true, "pip": true, "pipx": true, "apt": true, "dnf": true, "script": true}
+
+// validTypes are the setting types understood by `defaults write` (empty means string).
+var validTypes = map[string]bool{"": true, "bool": true, "int": true, "float": true, "string": true, "array": true, "array-add": true, "dict": true}
+
+// Validate checks a loaded config for structural problems without touching the system:
+// entries missing what their source or type needs, and entries that would otherwise be
+// silently collapsed (tools sharing a name, settings sharing a domain:key, aliases sharing
+// a name). Duplicates name the positions (1-based, in load order) of the offending entries.
+func Validate(cfg Config) []error {
+	var errs []error
+
+	toolNames := make([]string, len(cfg.Tools))
+	for i, t := range cfg.Tools {
+		toolNames[i] = t.Name
+		errs = append(errs, validateTool(i+1, t)...)
+	}
+	errs = append(errs, duplicates("tool", toolNames)...)
+
+	settingKeys := make([]string, len(cfg.Settings))
+	for i, s := range cfg.Settings {
+		settingKeys[i] = s.ID()
+		if s.Domain == "" || s.Key == "" {
+			errs = append(errs, fmt.Errorf("setting %d (%s:%s) needs both a domain and a key", i+1, s.Domain, s.Key))
+		}
+		if !validTypes[s.Type] {
+			errs = append(errs, fmt.Errorf("setting %s:%s has invalid type %q (want bool, int, float, string, array, array-add, or dict)", s.Domain, s.Key, s.Type))
+		}
+		switch {
+		case (s.Type == "array" || s.Type == "array-add") && s.Items == nil:
+			errs = append(errs, fmt.Errorf("setting %s:%s of type %s needs a list value", s.Domain, s.Key, s.Type))
+		case s.Type == "dict" && s.Dict == nil:
+			errs = append(errs, fmt.Errorf("setting %s:%s of type dict needs a map value", s.Domain, s.Key))
+		case s.Items != nil && s.Type != "array" && s.Type != "array-add", s.Dict != nil && s.Type != "dict":
+			errs = append(errs, fmt.Errorf("setting %s:%s has a structured value but type %q", s.Domain, s.Key, s.Type))
+		}
+	}
+	errs = append(errs, duplicates("setting", settingKeys)...)
+
+	aliasNames := make([]string, len(cfg.Aliases.Entries))
+	for i, a := range cfg.Aliases.Entries {
+		aliasNames[i] = a.Name
+	}
+	errs = append(errs, duplicates("alias", aliasNames)...)
+
+	return errs
+}
+
+// validateTool checks that a tool has what its source needs to be installed.
+func validateTool(pos int, t Tool) []error {
+	if t.Name == "" {
+		return []error{fmt.Errorf("tool %d has no name", pos)}
+	}
+
+	var errs []error
+	switch {
+	case !validSources[t.Source]:
+		errs = append(errs, fmt.Errorf("tool %q has unknown source %q", t.Name, t.Source))
+	case t.Source == "github" && t.Repo == "" && !strings.Contains(t.Name, "/"):
+		errs = append(errs, fmt.Errorf("github tool %q has no repo (set repo: owner/name)", t.Name))
+	case (t.Source == "url" || t.Source == "script") && t.URL == "":
+		errs = append(errs, fmt.Errorf("%s tool %q has an empty url", t.Source, t.Name))
+	case t.Source == "script" && !t.Trusted:
+		errs = append(errs, fmt.Errorf("script tool %q runs a downloaded install script; set trusted: true to allow it", t.Name))
+	case t.Cask && t.Source != "brew":
+		errs = append(errs, fmt.Errorf("tool %q sets cask but its source is %q (casks need source: brew)", t.Name, t.Source))
+	}
+	if version.IsConstraint(t.Version) {
+		if _, err := version.ParseConstraint(t.Version); err != nil {
+			errs = append(errs, fmt.Errorf("tool %q: %v", t.Name, err))
+		} else if t.Source != "github" {
+			errs = append(errs, fmt.Errorf("tool %q: version ranges are only supported for github tools", t.Name))
+		}
+	}
+	if _, err := path.Match(t.AssetPattern, ""); err != nil {
+		errs = append(errs, fmt.Errorf("tool %q has invalid asset_pattern %q: %v", t.Name, t.AssetPattern, err))
+	}
+	if len(t.Binaries) > 0 && t.Source != "github" && t.Source != "url" {
+		errs = append(errs, fmt.Errorf("tool %q sets binaries but its source is %q (binaries apply to github and url archives)", t.Name, t.Source))
+	}
+	for _, b := range t.Binaries {
+		if b == "" || strings.ContainsAny(b, `/\`) {
+			errs = append(errs, fmt.Errorf("tool %q has invalid binary %q (use the executable's file name)", t.Name, b))
+		}
+	}
+	for _, f := range t.Files {
+		if f.Dest == "" {
+			errs = append(errs, fmt.Errorf("tool %q has a file without a dest", t.Name))
+		}
+	}
+	return errs
+}
+
+// duplicates returns one error per name that occurs more than once in names,
+// in order of first occurrence.
+func duplicates(kind string, names []string) []error {
+	positions := map[string][]int{}
+	var order []string
+	for i, name := range names {
+		if _, seen := positions[name]; !seen {
+			order = append(order, name)
+		}
+		positions[name] = append(positions[name], i+1)
+	}
+
+	var errs []error
+	for _, name := range order {
+		if p := positions[name]; len(p) > 1 {
+			errs = append(errs, fmt.Errorf("duplicate %s %q (entries %v)", kind, name, p))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsDuplicates(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "tools",
+			cfg: Config{Tools: []Tool{
+				{Name: "jq", Source: "brew"},
+				{Name: "fd", Source: "brew"},
+				{Name: "jq", Source: "apt"},
+				{Name: "fd", Source: "brew"},
+				{Name: "jq", Source: "brew"},
+			}},
+			want: []string{`duplicate tool "jq" (entries [1 3 5])`, `duplicate tool "fd" (entries [2 4])`},
+		},
+		{
+			name: "settings",
+			cfg: Config{Settings: []Setting{
+				{Domain: "com.apple.dock", Key: "autohide", Value: "true", Type: "bool"},
+				{Domain: "com.apple.dock", Key: "autohide", Value: "false", Type: "bool"},
+			}},
+			want: []string{`duplicate setting "com.apple.dock:autohide" (entries [1 2])`},
+		},
+		{
+			name: "aliases",
+			cfg:  Config{Aliases: Aliases{Entries: []Alias{{Name: "ll", Value: "ls -l"}, {Name: "gs", Value: "git status"}, {Name: "ll", Value: "ls -al"}}}},
+			want: []string{`duplicate alias "ll" (entries [1 3])`},
+		},
+		{
+			// The same key in the currentHost or sudo domain is a different preference
+			name: "settings in different preference files",
+			cfg: Config{Settings: []Setting{
+				{Domain: "com.apple.dock", Key: "autohide", Value: "true", Type: "bool"},
+				{Domain: "com.apple.dock", Key: "autohide", Value: "true", Type: "bool", CurrentHost: true},
+				{Domain: "com.apple.dock", Key: "autohide", Value: "true", Type: "bool", Sudo: true},
+			}},
+		},
+		{
+			name: "no duplicates",
+			cfg: Config{
+				Tools:   []Tool{{Name: "jq", Source: "brew"}, {Name: "fd", Source: "brew"}},
+				Aliases: Aliases{Entries: []Alias{{Name: "ll", Value: "ls -l"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Validate(tt.cfg) {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigKeepsDuplicatesForValidate(t *testing.T) {
+	// Duplicates spread across included files still reach Validate as separate entries
+	path := writeConfig(t, map[string]string{
+		"tools.yaml":      "include:\n  - more-tools.yaml\ntools:\n  - name: jq\n    source: brew\n",
+		"more-tools.yaml": "tools:\n  - name: jq\n    source: apt\n",
+	})
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := Validate(cfg)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), `duplicate tool "jq"`) {
+		t.Errorf("Validate = %v, want the duplicate jq reported", problems)
+	}
+}
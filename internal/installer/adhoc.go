@@ -0,0 +1,55 @@
+package installer
+
+import (
+	"fmt"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"sort"
+)
+
+// adHocTools rebuilds the tools added with `setup-machine install` from their recorded specs,
+// so syncs keep verifying and repairing them like configured tools. A tool the config now
+// defines is left to the config. A tool whose spec no longer parses is left out with a warning;
+// SyncTools still never uninstalls it as an orphan.
+func adHocTools(configured []config.Tool, st *state.State) []config.Tool {
+	inConfig := map[string]bool{}
+	for _, tool := range configured {
+		inConfig[tool.Name] = true
+	}
+
+	var names []string
+	for name, ts := range st.Tools {
+		if ts.Spec != "" && !inConfig[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var tools []config.Tool
+	for _, name := range names {
+		tool, err := config.ParseToolSpec(st.Tools[name].Spec)
+		if err != nil {
+			logger.Warn("[WARN] Cannot sync %s, which was added with install: %v\n", name, err)
+			continue
+		}
+		// The name may have been given separately (--name), e.g. for url tools
+		tool.Name = name
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// InstallAdHoc installs a single tool that is not in the config and records it in st with its
+// spec, so later syncs keep it instead of uninstalling it. Nothing else is synced or removed.
+func InstallAdHoc(tool config.Tool, st *state.State) error {
+	KeepUnlisted = true
+	SyncTools([]config.Tool{tool}, st)
+	if DryRun {
+		return nil
+	}
+	if ts, ok := st.Tools[tool.Name]; !ok || ts.Spec != tool.Spec {
+		return fmt.Errorf("failed to install %s", tool.Name)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Markers delimiting the part of the shell rc file owned by setup-machine. Everything between
+// them is rewritten on each alias sync; everything outside them belongs to the user.
+const (
+	aliasBlockBegin = "# >>> setup-machine managed aliases (do not edit) >>>"
+	aliasBlockEnd   = "# <<< setup-machine managed aliases <<<"
+)
+
+// rcFile is a shell rc file split around the managed alias block.
+type rcFile struct {
+	before []string // Lines before the block (the whole file if there is no block yet)
+	block  []string // Lines between the markers, excluding the markers themselves
+	after  []string // Lines after the block
+	exists bool     // The file has a managed block, even if it is empty
+}
+
+// readRCFile reads and splits an rc file. A missing file yields an empty rcFile.
+// A begin marker without an end marker is an error rather than a guess, since treating the
+// rest of the file as managed could delete the user's own lines.
+func readRCFile(path string) (rcFile, error) {
+	var rc rcFile
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return rc, nil
+	}
+	if err != nil {
+		return rc, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case aliasBlockBegin:
+			if begin < 0 {
+				begin = i
+			}
+		case aliasBlockEnd:
+			if begin >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+	if begin >= 0 && end < 0 {
+		return rc, fmt.Errorf("%s has %q without a matching %q; fix the file before syncing aliases", path, aliasBlockBegin, aliasBlockEnd)
+	}
+	if begin < 0 {
+		rc.before = lines
+		return rc, nil
+	}
+
+	rc.before = lines[:begin]
+	rc.block = lines[begin+1 : end]
+	rc.after = lines[end+1:]
+	rc.exists = true
+	return rc, nil
+}
+
+// adopt removes the lines outside the block that are in lines and returns them, once each,
+// in file order. Versions without a managed block appended aliases to the end of the file;
+// adopting them lets the block take them over instead of leaving them behind for good.
+func (rc *rcFile) adopt(lines map[string]bool) []string {
+	var kept, adopted []string
+	seen := make(map[string]bool)
+	for _, line := range rc.before {
+		trimmed := strings.TrimSpace(line)
+		if !lines[trimmed] {
+			kept = append(kept, line)
+			continue
+		}
+		if !seen[trimmed] {
+			seen[trimmed] = true
+			adopted = append(adopted, trimmed)
+		}
+	}
+	rc.before = kept
+	return adopted
+}
+
+// render returns the file contents with the managed block replaced by block. A new block is
+// appended at the end of the file; an empty block removes the markers altogether.
+func (rc rcFile) render(block []string) []byte {
+	lines := append([]string(nil), rc.before...)
+	if len(block) > 0 {
+		lines = append(lines, aliasBlockBegin)
+		lines = append(lines, block...)
+		lines = append(lines, aliasBlockEnd)
+	}
+	lines = append(lines, rc.after...)
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// equalLines reports whether two blocks hold the same lines, ignoring surrounding whitespace.
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
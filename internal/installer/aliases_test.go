@@ -0,0 +1,145 @@
+package installer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+// writeRC writes lines to an rc file in a temp directory and returns its path.
+func writeRC(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".zshrc")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPlanAliasBlockAdoptsAppendedAliases(t *testing.T) {
+	aliases := config.Aliases{
+		Shell:      "zsh",
+		RawConfigs: []string{"fi"},
+		Entries:    []config.Alias{{Name: "gs", Value: "git status"}, {Name: "ll", Value: "ls -al"}},
+	}
+	path := writeRC(t, "export EDITOR=vim", `alias gs="git status"`, `alias old="gone"`, "fi", `alias gs="git status"`)
+
+	rc, err := readRCFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, applied, adopted := planAliasBlock(aliases, "zsh", &rc)
+
+	if got, want := strings.Join(adopted, "|"), `alias gs="git status"`; got != want {
+		t.Errorf("adopted = %s, want %s", got, want)
+	}
+	// "fi" stays the user's: raw config lines are never adopted, and not duplicated either
+	if got, want := strings.Join(rc.before, "|"), `export EDITOR=vim|alias old="gone"|fi`; got != want {
+		t.Errorf("lines outside the block = %s, want %s", got, want)
+	}
+	if got, want := strings.Join(block, "|"), `alias gs="git status"|alias ll="ls -al"`; got != want {
+		t.Errorf("block = %s, want %s", got, want)
+	}
+	if len(applied) != 2 {
+		t.Errorf("applied = %v, want gs and ll", applied)
+	}
+}
+
+func TestPlanAliasBlockKeepsUserAliasesOnceBlockExists(t *testing.T) {
+	aliases := config.Aliases{Shell: "zsh", Entries: []config.Alias{{Name: "gs", Value: "git status"}}}
+	path := writeRC(t, `alias gs="git status"`, aliasBlockBegin, aliasBlockEnd)
+
+	rc, err := readRCFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _, adopted := planAliasBlock(aliases, "zsh", &rc)
+	if len(adopted) != 0 || len(block) != 0 {
+		t.Errorf("adopted = %v, block = %v; want the user's alias left outside the block", adopted, block)
+	}
+}
+
+func TestAliasBlockScriptMatchesSync(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	run := func(path string, aliases config.Aliases) string {
+		t.Helper()
+		var b scriptBuilder
+		b.WriteString("set -euo pipefail\n")
+		writeAliasBlockScript(&b, path, aliases)
+		if out, err := exec.Command("bash", "-c", b.String()).CombinedOutput(); err != nil {
+			t.Fatalf("script failed: %v\n%s\n%s", err, out, b.String())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	aliases := config.Aliases{
+		Shell:   "zsh",
+		Entries: []config.Alias{{Name: "gs", Value: "git status"}, {Name: "ll", Value: "ls -al"}},
+	}
+	path := writeRC(t, "export EDITOR=vim", `alias gs="git status"`)
+
+	want := strings.Join([]string{"export EDITOR=vim", aliasBlockBegin, `alias gs="git status"`, `alias ll="ls -al"`, aliasBlockEnd}, "\n") + "\n"
+	if got := run(path, aliases); got != want {
+		t.Errorf("first run wrote:\n%s\nwant:\n%s", got, want)
+	}
+	if got := run(path, aliases); got != want {
+		t.Errorf("second run wrote:\n%s\nwant it unchanged:\n%s", got, want)
+	}
+
+	// Dropping an alias from the config prunes it from the block, which stays in place
+	if err := os.WriteFile(path, []byte(want+"export PAGER=less\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	aliases.Entries = aliases.Entries[1:]
+	want = strings.Join([]string{"export EDITOR=vim", aliasBlockBegin, `alias ll="ls -al"`, aliasBlockEnd, "export PAGER=less"}, "\n") + "\n"
+	if got := run(path, aliases); got != want {
+		t.Errorf("after removing gs:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAliasBlockScriptRemovesEmptyBlock(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	path := writeRC(t, "export EDITOR=vim", aliasBlockBegin, `alias gs="git status"`, aliasBlockEnd)
+
+	var b scriptBuilder
+	b.WriteString("set -euo pipefail\n")
+	writeAliasBlockScript(&b, path, config.Aliases{Shell: "zsh"})
+	if out, err := exec.Command("bash", "-c", b.String()).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "export EDITOR=vim\n" {
+		t.Errorf("rc file = %q, want the block removed", data)
+	}
+}
+
+func TestSyncAliasesCreatesMissingRCFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	st := &state.State{}
+
+	SyncAliases(config.Aliases{Shell: "fish", Entries: []config.Alias{{Name: "gs", Value: "git status"}}}, st)
+
+	data, err := os.ReadFile(filepath.Join(home, ".config", "fish", "config.fish"))
+	if err != nil {
+		t.Fatalf("config.fish was not created: %v", err)
+	}
+	if !strings.Contains(string(data), "alias gs 'git status'") {
+		t.Errorf("config.fish = %q, want the gs alias", data)
+	}
+	if st.Aliases["gs"] != "git status" {
+		t.Errorf("st.Aliases = %v, want gs recorded", st.Aliases)
+	}
+}
@@ -0,0 +1,57 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+)
+
+// BinDirs lists the candidate directories for installed binaries, in order of preference.
+// Entries may start with ~ and reference environment variables. It can be replaced through
+// `bin_dirs` in the main config.
+var BinDirs = []string{"/usr/local/bin", "~/bin"}
+
+// resolveBinDir expands ~ and environment variables in a BinDirs entry.
+func resolveBinDir(dir string) string {
+	return expandHome(os.ExpandEnv(dir))
+}
+
+// installToBinDir copies binaries into the first BinDirs entry that accepts all of them,
+// creating the directory if it is missing. It returns the directory that was used.
+func installToBinDir(binaries []string, log *logger.Logger) (string, error) {
+	var lastErr error
+	for _, entry := range BinDirs {
+		dir := resolveBinDir(entry)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Debug("[DEBUG] Skipping bin directory %s: %v\n", dir, err)
+			lastErr = err
+			continue
+		}
+
+		var err error
+		for _, binaryPath := range binaries {
+			if err = copyBinary(binaryPath, dir); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Debug("[DEBUG] Cannot install into %s: %v\n", dir, err)
+			lastErr = err
+			continue
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("no writable bin directory among %v: %w", BinDirs, lastErr)
+}
+
+// binDirOf returns the BinDirs entry (resolved) that contains installPath, or "" when the
+// tool was installed elsewhere (e.g. by brew or a .pkg installer).
+func binDirOf(installPath string) string {
+	for _, entry := range BinDirs {
+		if dir := resolveBinDir(entry); dir == filepath.Dir(installPath) {
+			return dir
+		}
+	}
+	return ""
+}
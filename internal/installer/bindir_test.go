@@ -0,0 +1,65 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/logger"
+)
+
+func TestInstallToBinDirFallsBack(t *testing.T) {
+	tmp := t.TempDir()
+	// A regular file in the way makes the first directory impossible to create, even for root
+	blocker := filepath.Join(tmp, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fallback := filepath.Join(tmp, "home", "bin")
+	useBinDirs(t, filepath.Join(blocker, "bin"), fallback, filepath.Join(tmp, "unused"))
+
+	binary := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := installToBinDir([]string{binary}, &logger.Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != fallback {
+		t.Errorf("installed into %s, want the first usable directory %s", dir, fallback)
+	}
+	if _, err := os.Stat(filepath.Join(fallback, "tool")); err != nil {
+		t.Errorf("tool was not installed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "unused")); err == nil {
+		t.Error("a later directory was created although an earlier one worked")
+	}
+	if got := binDirOf(filepath.Join(fallback, "tool")); got != fallback {
+		t.Errorf("binDirOf = %q, want %s", got, fallback)
+	}
+}
+
+func TestInstallToBinDirFailsWhenNoneUsable(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	useBinDirs(t, filepath.Join(blocker, "a"), filepath.Join(blocker, "b"))
+
+	_, err := installToBinDir([]string{blocker}, &logger.Logger{})
+	if err == nil || !strings.Contains(err.Error(), "no writable bin directory") {
+		t.Errorf("installToBinDir error = %v, want no writable bin directory", err)
+	}
+}
+
+func TestResolveBinDirExpandsHomeAndEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("TOOLS", "/opt/tools")
+	for entry, want := range map[string]string{"~/bin": "/home/me/bin", "$TOOLS/bin": "/opt/tools/bin", "/usr/local/bin": "/usr/local/bin"} {
+		if got := resolveBinDir(entry); got != want {
+			t.Errorf("resolveBinDir(%s) = %s, want %s", entry, got, want)
+		}
+	}
+}
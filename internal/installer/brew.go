@@ -0,0 +1,93 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// runBrew executes Homebrew with the given arguments and returns its combined output.
+// Tests swap it for a recorder to check which brew commands a sync runs, and in what order.
+var runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "brew", args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// installFromBrew installs a Homebrew formula (or cask) named after the tool and returns the
+// formula's executable path under the brew prefix. Casks have no single executable, so their
+// Caskroom directory is returned instead. Taps are handled beforehand by ensureTaps.
+func installFromBrew(ctx context.Context, tool config.Tool) (string, error) {
+	output, err := runBrew(ctx, brewInstallArgs(tool)...)
+	if err != nil {
+		return "", fmt.Errorf("brew %s failed: %v\nOutput: %s", strings.Join(brewInstallArgs(tool), " "), err, output)
+	}
+
+	prefix, err := runBrew(ctx, "--prefix")
+	if err != nil {
+		return "", fmt.Errorf("cannot determine brew prefix: %v\nOutput: %s", err, prefix)
+	}
+	if tool.Cask {
+		return filepath.Join(strings.TrimSpace(string(prefix)), "Caskroom", filepath.Base(tool.Name)), nil
+	}
+	return filepath.Join(strings.TrimSpace(string(prefix)), "bin", filepath.Base(tool.Name)), nil
+}
+
+// brewInstallArgs returns the brew arguments that install a tool.
+func brewInstallArgs(tool config.Tool) []string {
+	if tool.Cask {
+		return []string{"install", "--cask", tool.Name}
+	}
+	return []string{"install", tool.Name}
+}
+
+// brewUninstallArgs returns the brew arguments that remove a formula or cask.
+func brewUninstallArgs(name string, cask bool) []string {
+	if cask {
+		return []string{"uninstall", "--cask", name}
+	}
+	return []string{"uninstall", name}
+}
+
+// ensureTaps runs `brew tap` for each tap that isn't already tapped, in order.
+// It returns the taps that were newly added.
+func ensureTaps(taps []string, log *logger.Logger) ([]string, error) {
+	if len(taps) == 0 {
+		return nil, nil
+	}
+
+	output, err := runBrew(context.Background(), "tap")
+	if err != nil {
+		return nil, fmt.Errorf("cannot list brew taps: %v\nOutput: %s", err, output)
+	}
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(output), "\n") {
+		existing[strings.ToLower(strings.TrimSpace(line))] = true
+	}
+
+	var added []string
+	for _, tap := range taps {
+		if existing[strings.ToLower(tap)] {
+			log.Debug("[DEBUG] Tap %s already present\n", tap)
+			continue
+		}
+		log.Info("[INFO] Tapping %s\n", tap)
+		if output, err := runBrew(context.Background(), "tap", tap); err != nil {
+			return added, fmt.Errorf("brew tap %s failed: %v\nOutput: %s", tap, err, output)
+		}
+		added = append(added, tap)
+	}
+	return added, nil
+}
+
+// uninstallFromBrew removes a formula or cask previously installed with the brew source.
+func uninstallFromBrew(name string, cask bool) error {
+	output, err := runBrew(context.Background(), brewUninstallArgs(name, cask)...)
+	if err != nil {
+		return fmt.Errorf("brew %s failed: %v\nOutput: %s", strings.Join(brewUninstallArgs(name, cask), " "), err, output)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package installer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// fakeBrew replaces runBrew with a runner that records each call's arguments. `brew tap`
+// lists tapped, and `brew --prefix` prints prefix.
+func fakeBrew(t *testing.T, tapped []string, prefix string) *[]string {
+	t.Helper()
+	orig := runBrew
+	t.Cleanup(func() { runBrew = orig })
+
+	var calls []string
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch {
+		case len(args) == 1 && args[0] == "tap":
+			return []byte(strings.Join(tapped, "\n") + "\n"), nil
+		case args[0] == "--prefix":
+			return []byte(prefix + "\n"), nil
+		}
+		return nil, nil
+	}
+	return &calls
+}
+
+func TestEnsureTapsOnlyAddsMissingTaps(t *testing.T) {
+	calls := fakeBrew(t, []string{"homebrew/core", "hashicorp/tap"}, "")
+
+	added, err := ensureTaps([]string{"HashiCorp/tap", "goreleaser/tap"}, &logger.Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0] != "goreleaser/tap" {
+		t.Errorf("added = %v, want [goreleaser/tap]", added)
+	}
+	want := []string{"tap", "tap goreleaser/tap"}
+	if strings.Join(*calls, "|") != strings.Join(want, "|") {
+		t.Errorf("brew calls = %q, want %q", *calls, want)
+	}
+}
+
+func TestSyncToolsTapsBeforeInstalling(t *testing.T) {
+	calls := fakeBrew(t, nil, "/opt/homebrew")
+	st := &state.State{Tools: map[string]state.ToolState{}}
+
+	SyncTools([]config.Tool{{Name: "terraform", Source: "brew", Version: "1.9", Taps: []string{"hashicorp/tap"}}}, st)
+
+	want := []string{"tap", "tap hashicorp/tap", "install terraform", "--prefix"}
+	if strings.Join(*calls, "|") != strings.Join(want, "|") {
+		t.Errorf("brew calls = %q, want %q", *calls, want)
+	}
+	if len(st.Taps) != 1 || st.Taps[0] != "hashicorp/tap" {
+		t.Errorf("recorded taps = %v, want [hashicorp/tap]", st.Taps)
+	}
+	if got := st.Tools["terraform"].InstallPath; got != "/opt/homebrew/bin/terraform" {
+		t.Errorf("install path = %s, want /opt/homebrew/bin/terraform", got)
+	}
+}
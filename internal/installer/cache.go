@@ -0,0 +1,129 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// NoCache disables the download cache for this run: every asset is downloaded afresh and
+// nothing is stored. It's set via the `--no-cache` flag.
+var NoCache bool
+
+// CacheDir returns the directory holding cached downloads: $XDG_CACHE_HOME/setup-machine/downloads,
+// i.e. ~/.cache/setup-machine/downloads unless XDG_CACHE_HOME is set. A relative XDG_CACHE_HOME
+// is ignored, as the XDG spec requires, and without a home directory the cache goes to the
+// temp directory rather than somewhere relative to the current directory.
+func CacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil || !filepath.IsAbs(home) {
+			return filepath.Join(os.TempDir(), "setup-machine", "downloads")
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "setup-machine", "downloads")
+}
+
+// cacheable reports whether a download can be reused by later runs. It can when its checksum
+// is known, or when it is a GitHub release asset, whose URL names the release and so always
+// refers to the same file. Other URLs (e.g. ".../latest/installer.pkg") may change content.
+func cacheable(url, expected string) bool {
+	return !NoCache && (expected != "" || strings.Contains(url, "/releases/download/"))
+}
+
+// cachePath returns where a download is cached: keyed by the URL and expected checksum, and
+// ending in the asset's own name so archive detection by extension keeps working.
+func cachePath(url, expected string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + expected))
+	return filepath.Join(CacheDir(), hex.EncodeToString(sum[:8])+"-"+path.Base(url))
+}
+
+// cachedDownload puts the file at url into dest and verifies it against expected (if set),
+// reusing a cached copy from an earlier run when there is one. Fresh downloads are added
+// to the cache after they verify; a cached copy that no longer verifies is discarded.
+func cachedDownload(ctx context.Context, url, dest, expected string, log *logger.Logger) error {
+	if !cacheable(url, expected) {
+		if err := downloadFile(ctx, url, dest, log); err != nil {
+			return err
+		}
+		return verifyDownload(dest, expected, log)
+	}
+
+	cached := cachePath(url, expected)
+	if _, err := os.Stat(cached); err == nil {
+		if err := copyFile(cached, dest); err == nil && verifyDownload(dest, expected, log) == nil {
+			log.Info("[INFO] Using cached download %s\n", cached)
+			return nil
+		}
+		log.Warn("[WARN] Discarding invalid cached download %s\n", cached)
+		_ = os.Remove(cached)
+	}
+
+	if err := downloadFile(ctx, url, dest, log); err != nil {
+		return err
+	}
+	if err := verifyDownload(dest, expected, log); err != nil {
+		return err
+	}
+
+	// Failing to cache only costs a download next time
+	if err := os.MkdirAll(CacheDir(), 0755); err == nil {
+		err = copyFile(dest, cached)
+		if err != nil {
+			log.Debug("[DEBUG] Could not cache %s: %v\n", url, err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies src to dst through a temp file in dst's directory, so a partially written
+// copy never appears under the final name.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// ClearCache deletes every cached download and returns how many files were removed.
+func ClearCache() (int, error) {
+	entries, err := os.ReadDir(CacheDir())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(CacheDir(), e.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,29 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheDir(t *testing.T) {
+	home := t.TempDir()
+	tests := []struct {
+		name, xdg, home, want string
+	}{
+		{name: "home", home: home, want: filepath.Join(home, ".cache", "setup-machine", "downloads")},
+		{name: "XDG_CACHE_HOME", xdg: "/var/cache/me", home: home, want: "/var/cache/me/setup-machine/downloads"},
+		{name: "relative XDG_CACHE_HOME is ignored", xdg: "cache", home: home, want: filepath.Join(home, ".cache", "setup-machine", "downloads")},
+		{name: "no home", want: filepath.Join(os.TempDir(), "setup-machine", "downloads")},
+		{name: "relative home", home: "somewhere", want: filepath.Join(os.TempDir(), "setup-machine", "downloads")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CACHE_HOME", tt.xdg)
+			t.Setenv("HOME", tt.home)
+			if got := CacheDir(); got != tt.want {
+				t.Errorf("CacheDir() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
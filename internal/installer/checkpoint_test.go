@@ -0,0 +1,68 @@
+package installer
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+func TestSyncToolsCheckpointsCompletedInstalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	checkpointer := state.NewCheckpointer(path, 0)
+	orig := Checkpoint
+	Checkpoint = checkpointer.Save
+	t.Cleanup(func() { Checkpoint = orig; checkpointer.Stop() })
+
+	// jq and fd install right away; slow hangs until the test lets it finish. Brew installs
+	// are serialized by default, so the limits are lifted to keep slow from blocking the rest.
+	release := make(chan struct{})
+	brew, concurrency, jobs := runBrew, SourceConcurrency, Jobs
+	t.Cleanup(func() { runBrew, SourceConcurrency, Jobs = brew, concurrency, jobs })
+	SourceConcurrency = map[string]int{"brew": 3}
+	Jobs = 3
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		switch {
+		case args[0] == "--prefix":
+			return []byte("/opt/homebrew\n"), nil
+		case len(args) > 1 && args[1] == "slow":
+			<-release
+		}
+		return nil, nil
+	}
+
+	st := &state.State{Tools: map[string]state.ToolState{}}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		SyncTools([]config.Tool{
+			{Name: "jq", Source: "brew", Version: "1.7"},
+			{Name: "slow", Source: "brew", Version: "1.0"},
+			{Name: "fd", Source: "brew", Version: "9.0"},
+		}, st)
+	}()
+	defer wg.Wait()
+	defer close(release)
+
+	// The run is "killed" here: nothing has called SaveState, yet the completed installs
+	// are already on disk
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		saved := state.LoadState(path)
+		if saved.Tools["jq"].Version == "1.7" && saved.Tools["fd"].Version == "9.0" {
+			if _, ok := saved.Tools["slow"]; ok {
+				t.Error("the unfinished install was checkpointed")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("checkpointed tools = %v, want jq and fd", saved.Tools)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,146 @@
+package installer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// checksumSkip is the Tool.Checksum value that disables download verification for a tool.
+const checksumSkip = "skip"
+
+// expectedChecksum returns the SHA256 a downloaded GitHub asset must have.
+// An explicit `checksum:` in the config wins; otherwise the release is searched for a
+// checksum asset (checksums.txt, SHA256SUMS, <asset>.sha256, ...). It returns "" when the
+// tool opts out with `checksum: skip` or the release publishes no checksum for the asset.
+func expectedChecksum(ctx context.Context, tool config.Tool, release GitHubRelease, assetName string, log *logger.Logger) (string, error) {
+	switch strings.ToLower(tool.Checksum) {
+	case checksumSkip:
+		log.Debug("[DEBUG] Checksum verification disabled for %s\n", tool.Name)
+		return "", nil
+	case "":
+	default:
+		return normalizeChecksum(tool.Checksum), nil
+	}
+
+	for _, asset := range release.Assets {
+		if !isChecksumAsset(asset.Name, assetName) {
+			continue
+		}
+		log.Debug("[DEBUG] Using checksum asset %s\n", asset.Name)
+
+		tmp, err := os.CreateTemp("", "setup-machine-checksums-")
+		if err != nil {
+			return "", err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err := downloadFile(ctx, asset.BrowserDownloadURL, tmp.Name(), log); err != nil {
+			return "", fmt.Errorf("failed to download checksum asset %s: %w", asset.Name, err)
+		}
+		sum, err := checksumFor(tmp.Name(), assetName)
+		if err != nil {
+			return "", fmt.Errorf("cannot read checksum asset %s: %w", asset.Name, err)
+		}
+		if sum != "" {
+			return sum, nil
+		}
+	}
+
+	log.Debug("[DEBUG] No published checksum found for %s\n", assetName)
+	return "", nil
+}
+
+// isChecksumAsset reports whether a release asset looks like a checksum file covering assetName.
+// Signatures and certificates published next to a checksum file (checksums.txt.sig, .pem,
+// .asc from cosign or GPG) share its name but hold no digests, so they never count.
+func isChecksumAsset(name, assetName string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".sig", ".pem", ".asc"} {
+		if strings.HasSuffix(lower, ext) {
+			return false
+		}
+	}
+	if lower == strings.ToLower(assetName)+".sha256" {
+		return true
+	}
+	return strings.Contains(lower, "checksums") || strings.Contains(lower, "sha256sums")
+}
+
+// checksumFor reads a checksum file and returns the digest listed for assetName.
+// It understands the `sha256sum` format ("<hex>  <name>", optionally "*<name>") and files
+// that contain nothing but a single digest, as is common for <asset>.sha256.
+func checksumFor(file, assetName string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	// Only well-formed SHA256 digests count; anything else isn't a checksum listing after all
+	if len(lines) == 1 && len(lines[0]) == 1 {
+		if sum := normalizeChecksum(lines[0][0]); isSHA256(sum) {
+			return sum, nil
+		}
+		return "", nil
+	}
+	for _, fields := range lines {
+		if len(fields) >= 2 && path.Base(strings.TrimPrefix(fields[1], "*")) == assetName {
+			if sum := normalizeChecksum(fields[0]); isSHA256(sum) {
+				return sum, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// isSHA256 reports whether sum is a SHA256 digest: 64 lowercase hex characters.
+func isSHA256(sum string) bool {
+	if len(sum) != 64 {
+		return false
+	}
+	for _, c := range sum {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeChecksum lowercases a digest and strips an optional "sha256:" prefix.
+func normalizeChecksum(sum string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(sum)), "sha256:")
+}
+
+// verifyDownload checks that the file at path has the expected SHA256 digest.
+// An empty expected digest means there is nothing to verify.
+func verifyDownload(file, expected string, log *logger.Logger) error {
+	if expected == "" {
+		return nil
+	}
+	actual, err := fileSHA256(file)
+	if err != nil {
+		return fmt.Errorf("cannot checksum %s: %w", file, err)
+	}
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path.Base(file), expected, actual)
+	}
+	log.Info("[INFO] Verified SHA256 of %s\n", path.Base(file))
+	return nil
+}
@@ -0,0 +1,155 @@
+package installer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+// fakeRelease serves a GitHub release of "cli" v1.0.0 whose asset is a .tar.gz holding the
+// cli binary, along with a checksums.txt listing sum(digest of the asset) for it and the
+// checksums.txt.sig signing it.
+func fakeRelease(t *testing.T, sum func(actual string) string) {
+	t.Helper()
+	tarball, err := os.ReadFile(writeTar(t, []tarEntry{{name: "cli", mode: 0755, body: "#!/bin/sh\necho cli\n"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	zw.Write(tarball)
+	zw.Close()
+	digest := sha256.Sum256(archive.Bytes())
+	actual := hex.EncodeToString(digest[:])
+
+	assetName := fmt.Sprintf("cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/tools/cli/releases/tags/v1.0.0":
+			// The signature comes first, so it is skipped rather than read as the digest
+			fmt.Fprintf(w, `{"tag_name": "v1.0.0", "assets": [
+				{"name": %q, "browser_download_url": "%s/releases/download/v1.0.0/%s"},
+				{"name": "checksums.txt.sig", "browser_download_url": "%s/releases/download/v1.0.0/checksums.txt.sig"},
+				{"name": "checksums.txt", "browser_download_url": "%s/releases/download/v1.0.0/checksums.txt"}]}`,
+				assetName, srv.URL, assetName, srv.URL, srv.URL)
+		case "/releases/download/v1.0.0/" + assetName:
+			w.Write(archive.Bytes())
+		case "/releases/download/v1.0.0/checksums.txt.sig":
+			w.Write([]byte("MEUCIQDabcxyz+/base64sig==\n"))
+		case "/releases/download/v1.0.0/checksums.txt":
+			fmt.Fprintf(w, "%s  %s\n", sum(actual), assetName)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	base := GitHubAPIBase
+	GitHubAPIBase = srv.URL
+	t.Cleanup(func() { GitHubAPIBase = base })
+}
+
+func TestInstallToolAbortsOnChecksumMismatch(t *testing.T) {
+	wrong := strings.Repeat("0", 64)
+	tests := []struct {
+		name       string
+		published  func(actual string) string
+		configured string
+		wantErr    string
+	}{
+		{name: "published checksum", published: func(string) string { return wrong }, wantErr: "checksum mismatch"},
+		{name: "configured checksum", published: func(actual string) string { return actual }, configured: "sha256:" + wrong, wantErr: "checksum mismatch"},
+		{name: "matching checksum", published: func(actual string) string { return actual }},
+		{name: "verification skipped", published: func(string) string { return wrong }, configured: "skip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			bin := filepath.Join(t.TempDir(), "bin")
+			useBinDirs(t, bin)
+			fakeRelease(t, tt.published)
+
+			tool := config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "1.0.0", Checksum: tt.configured}
+			_, err := installTool(context.Background(), tool, &logger.Logger{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := os.Stat(filepath.Join(bin, "cli")); err != nil {
+					t.Errorf("cli was not installed: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("installTool error = %v, want %s", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(bin, "cli")); !os.IsNotExist(err) {
+				t.Error("cli was installed from an asset that failed verification")
+			}
+			if cached, _ := os.ReadDir(CacheDir()); len(cached) != 0 {
+				t.Errorf("a download that failed verification was cached: %v", cached)
+			}
+		})
+	}
+}
+
+func TestChecksumFor(t *testing.T) {
+	a, b := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	tests := []struct {
+		name, contents, want string
+	}{
+		{name: "sha256sum output", contents: a + "  other.tar.gz\n" + strings.ToUpper(b) + "  cli.tar.gz\n", want: b},
+		{name: "binary mode marker", contents: b + " *cli.tar.gz\n", want: b},
+		{name: "path in the listing", contents: b + "  dist/cli.tar.gz\n", want: b},
+		{name: "single digest file", contents: "sha256:" + strings.ToUpper(b) + "\n", want: b},
+		{name: "asset not listed", contents: a + "  other.tar.gz\n" + b + "  another.tar.gz\n"},
+		{name: "signature", contents: "MEUCIQDabcxyz+/base64sig==\n"},
+		{name: "short digest", contents: "abc123  cli.tar.gz\n"},
+		{name: "not hex", contents: strings.Repeat("z", 64) + "  cli.tar.gz\n"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "checksums.txt")
+		if err := os.WriteFile(file, []byte(tt.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := checksumFor(file, "cli.tar.gz"); err != nil || got != tt.want {
+			t.Errorf("%s: checksumFor = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestIsChecksumAsset(t *testing.T) {
+	tests := map[string]bool{
+		"checksums.txt":           true,
+		"cli_1.0.0_checksums.txt": true,
+		"SHA256SUMS":              true,
+		"cli.tar.gz.sha256":       true,
+		"checksums.txt.sig":       false,
+		"checksums.txt.pem":       false,
+		"SHA256SUMS.asc":          false,
+		"cli.tar.gz.sha256.sig":   false,
+		"cli.tar.gz":              false,
+		"other.tar.gz.sha256":     false,
+	}
+	for name, want := range tests {
+		if got := isChecksumAsset(name, "cli.tar.gz"); got != want {
+			t.Errorf("isChecksumAsset(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"sort"
+	"strings"
+)
+
+// tempDirs are the directories setup-machine's temp files and directories can be left in:
+// /tmp, where older versions downloaded to, and the system temp directory.
+func tempDirs() []string {
+	dirs := []string{"/tmp"}
+	if dir := filepath.Clean(os.TempDir()); dir != "/tmp" {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// TempArtifacts returns the setup-machine-* files and directories left in the temp
+// directories by interrupted runs: downloads, extraction and mount directories, and other
+// temp files. Only that prefix is matched, so nothing another program put in the temp
+// directory is touched. Nothing is removed.
+func TempArtifacts() []string {
+	var artifacts []string
+	for _, dir := range tempDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			logger.Debug("[DEBUG] Cannot read %s: %v\n", dir, err)
+			continue
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), "setup-machine-") {
+				artifacts = append(artifacts, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(artifacts)
+	return artifacts
+}
+
+// CleanTempArtifacts removes the artifacts found by TempArtifacts and returns how many were
+// removed (or would be, in dry-run mode).
+func CleanTempArtifacts() int {
+	removed := 0
+	for _, artifact := range TempArtifacts() {
+		if DryRun {
+			logger.Info("[DRY-RUN] Would remove %s\n", artifact)
+			removed++
+			continue
+		}
+
+		// A .dmg mount point is only left behind when detaching failed; if the image is still
+		// attached the directory isn't empty, and removing it must not reach into the image
+		var err error
+		if strings.HasPrefix(filepath.Base(artifact), "setup-machine-dmg-") {
+			err = os.Remove(artifact)
+		} else {
+			err = os.RemoveAll(artifact)
+		}
+		if err != nil {
+			logger.Warn("[WARN] Could not remove %s: %v\n", artifact, err)
+			continue
+		}
+		logger.Info("[INFO] Removed %s\n", artifact)
+		removed++
+	}
+	return removed
+}
+
+// PruneState drops state entries that point at paths no longer on disk: tools whose install
+// path is gone, and managed files of a tool that were deleted. A pruned tool is installed
+// again by the next sync. It returns the number of entries pruned (or that would be, in
+// dry-run mode, where st is left untouched).
+func PruneState(st *state.State) int {
+	var names []string
+	for name := range st.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pruned := 0
+	for _, name := range names {
+		ts := st.Tools[name]
+		if ts.InstallPath != "" && !pathExists(ts.InstallPath) {
+			if DryRun {
+				logger.Info("[DRY-RUN] Would prune %s@%s: %s no longer exists\n", name, ts.Version, ts.InstallPath)
+			} else {
+				logger.Info("[INFO] Pruned %s@%s: %s no longer exists\n", name, ts.Version, ts.InstallPath)
+				delete(st.Tools, name)
+			}
+			pruned++
+			continue
+		}
+
+		var files []string
+		for file := range ts.Files {
+			if !pathExists(file) {
+				files = append(files, file)
+			}
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if DryRun {
+				logger.Info("[DRY-RUN] Would prune managed file %s of %s: it no longer exists\n", file, name)
+			} else {
+				logger.Info("[INFO] Pruned managed file %s of %s: it no longer exists\n", file, name)
+				delete(ts.Files, file)
+			}
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// pathExists reports whether path exists; a dangling symlink counts, since it is still
+// something on disk the tool owns.
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"setup-machine/internal/state"
+)
+
+func TestTempArtifactsOnlyMatchesOwnPrefix(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	for _, name := range []string{"setup-machine-download-123", "setup-machine-dmg-456", "bat-v0.24.0-x86_64-apple-darwin.tar.gz", "jq", "other-setup-machine-x"} {
+		if err := os.Mkdir(filepath.Join(tmp, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, artifact := range TempArtifacts() {
+		if filepath.Dir(artifact) == tmp {
+			got = append(got, filepath.Base(artifact))
+		}
+	}
+	want := []string{"setup-machine-dmg-456", "setup-machine-download-123"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("TempArtifacts() in %s = %v, want %v", tmp, got, want)
+	}
+}
+
+func TestPruneStateDropsMissingPaths(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present")
+	if err := os.WriteFile(present, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	st := &state.State{Tools: map[string]state.ToolState{
+		"kept": {InstallPath: present, Files: map[string]string{
+			present:                      "sha",
+			filepath.Join(dir, "config"): "sha",
+		}},
+		"gone": {InstallPath: filepath.Join(dir, "gone")},
+	}}
+
+	if pruned := PruneState(st); pruned != 2 {
+		t.Errorf("PruneState() = %d, want 2", pruned)
+	}
+	if _, ok := st.Tools["gone"]; ok {
+		t.Error("tool with a missing install path was kept")
+	}
+	kept, ok := st.Tools["kept"]
+	if !ok {
+		t.Fatal("tool with an existing install path was pruned")
+	}
+	if len(kept.Files) != 1 || kept.Files[present] == "" {
+		t.Errorf("kept.Files = %v, want only %s", kept.Files, present)
+	}
+}
@@ -0,0 +1,107 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"syscall"
+	"time"
+)
+
+// defaultConcurrency is the number of parallel installs allowed for sources without an entry
+// in SourceConcurrency.
+const defaultConcurrency = 4
+
+// Jobs caps how many tools are synced at the same time across all sources, on top of the
+// per-source limits in SourceConcurrency. It's set via `--jobs`; values below 1 mean one.
+var Jobs = runtime.NumCPU()
+
+// SourceConcurrency caps how many tools of each source are installed at the same time.
+// These sources are serialized: Homebrew takes its own locks and breaks under concurrent
+// invocations; global npm, `pip --user` and pipx installs race on a shared prefix and bin
+// directory; apt and dnf hold a system-wide package lock; and install scripts may do any of
+// the above. GitHub and url downloads are network-bound and parallelize well.
+var SourceConcurrency = map[string]int{
+	"brew":   1,
+	"npm":    1,
+	"pip":    1,
+	"pipx":   1,
+	"apt":    1,
+	"dnf":    1,
+	"script": 1,
+	"github": 8,
+	"url":    4,
+}
+
+// SourceTimeouts bounds how long a single install of each source may take. Zero (or a missing
+// entry) means no timeout.
+var SourceTimeouts = map[string]time.Duration{
+	"brew":   15 * time.Minute,
+	"npm":    10 * time.Minute,
+	"pip":    10 * time.Minute,
+	"pipx":   10 * time.Minute,
+	"apt":    15 * time.Minute,
+	"dnf":    15 * time.Minute,
+	"script": 15 * time.Minute,
+	"github": 10 * time.Minute,
+	"url":    10 * time.Minute,
+}
+
+// sourceSemaphores returns one semaphore (a buffered channel) per source used by tools,
+// sized from SourceConcurrency.
+func sourceSemaphores(tools []config.Tool) map[string]chan struct{} {
+	sems := map[string]chan struct{}{}
+	for _, tool := range tools {
+		if _, ok := sems[tool.Source]; ok {
+			continue
+		}
+		limit, ok := SourceConcurrency[tool.Source]
+		if !ok || limit < 1 {
+			limit = defaultConcurrency
+		}
+		sems[tool.Source] = make(chan struct{}, limit)
+	}
+	return sems
+}
+
+// installWithTimeout runs installTool, stopping it once the source's timeout expires. The
+// install's commands are started with commandContext and its downloads carry the deadline, so
+// a timed-out install is killed rather than left running in the background, and its
+// concurrency slot is only handed on once it really has stopped (e.g. never start a second brew
+// while one is still running).
+func installWithTimeout(tool config.Tool, log *logger.Logger) (installResult, error) {
+	ctx := context.Background()
+	timeout := SourceTimeouts[tool.Source]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	installed, err := installTool(ctx, tool, log)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return installResult{}, fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return installed, err
+}
+
+// commandWaitDelay is how long a cancelled command gets to exit after SIGTERM before it is
+// killed, and how long its output is waited for once it has exited (a background child can
+// hold the pipe open forever).
+var commandWaitDelay = 10 * time.Second
+
+// commandContext is exec.CommandContext for the commands installs run: when ctx is done the
+// command gets SIGTERM, so brew, npm and friends can clean up their locks, and is killed if
+// it is still running commandWaitDelay later.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
@@ -0,0 +1,173 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+// installCounter records how many fake installs run at once, per source and overall.
+type installCounter struct {
+	mu       sync.Mutex
+	running  map[string]int
+	peak     map[string]int
+	total    int
+	peakAll  int
+	finished int
+}
+
+func newInstallCounter() *installCounter {
+	return &installCounter{running: map[string]int{}, peak: map[string]int{}}
+}
+
+func (c *installCounter) enter(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running[source]++
+	c.peak[source] = max(c.peak[source], c.running[source])
+	c.total++
+	c.peakAll = max(c.peakAll, c.total)
+}
+
+func (c *installCounter) leave(source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running[source]--
+	c.total--
+	c.finished++
+}
+
+// fakeInstalls swaps runBrew and runPython for runners that count concurrent installs in c
+// and take a little while, so overlapping installs are seen. SourceConcurrency, Jobs and
+// SourceTimeouts are restored after the test.
+func fakeInstalls(t *testing.T, c *installCounter) {
+	t.Helper()
+	brew, python := runBrew, runPython
+	concurrency, jobs, timeouts := SourceConcurrency, Jobs, SourceTimeouts
+	t.Cleanup(func() {
+		runBrew, runPython = brew, python
+		SourceConcurrency, Jobs, SourceTimeouts = concurrency, jobs, timeouts
+	})
+
+	prefix := t.TempDir()
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		if args[0] == "--prefix" {
+			return []byte(prefix), nil
+		}
+		c.enter("brew")
+		defer c.leave("brew")
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	}
+	runPython = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		c.enter("pipx")
+		defer c.leave("pipx")
+		time.Sleep(20 * time.Millisecond)
+		return nil, nil
+	}
+}
+
+// concurrencyTools returns brew and pipx tools to sync.
+func concurrencyTools(brew, pipx int) []config.Tool {
+	var tools []config.Tool
+	for i := range brew {
+		tools = append(tools, config.Tool{Name: fmt.Sprintf("formula%d", i), Source: "brew", Version: "1.0"})
+	}
+	for i := range pipx {
+		tools = append(tools, config.Tool{Name: fmt.Sprintf("cli%d", i), Source: "pipx", Version: "1.0"})
+	}
+	return tools
+}
+
+func TestSyncToolsRespectsSourceLimits(t *testing.T) {
+	c := newInstallCounter()
+	fakeInstalls(t, c)
+	SourceConcurrency = map[string]int{"brew": 1, "pipx": 3}
+	Jobs = 16
+
+	st := &state.State{Tools: map[string]state.ToolState{}}
+	SyncTools(concurrencyTools(4, 8), st)
+
+	if c.peak["brew"] != 1 {
+		t.Errorf("peak concurrent brew installs = %d, want 1", c.peak["brew"])
+	}
+	if c.peak["pipx"] > 3 {
+		t.Errorf("peak concurrent pipx installs = %d, want at most 3", c.peak["pipx"])
+	}
+	if len(st.Tools) != 12 {
+		t.Errorf("recorded %d tools, want 12", len(st.Tools))
+	}
+}
+
+func TestSyncToolsRespectsJobs(t *testing.T) {
+	c := newInstallCounter()
+	fakeInstalls(t, c)
+	SourceConcurrency = map[string]int{"brew": 1, "pipx": 8}
+	Jobs = 2
+
+	st := &state.State{Tools: map[string]state.ToolState{}}
+	SyncTools(concurrencyTools(3, 8), st)
+
+	if c.peakAll > 2 {
+		t.Errorf("peak concurrent installs = %d, want at most 2 (Jobs)", c.peakAll)
+	}
+	if c.peak["brew"] != 1 {
+		t.Errorf("peak concurrent brew installs = %d, want 1", c.peak["brew"])
+	}
+	if len(st.Tools) != 11 {
+		t.Errorf("recorded %d tools, want 11", len(st.Tools))
+	}
+}
+
+func TestSyncToolsKillsTimedOutInstalls(t *testing.T) {
+	c := newInstallCounter()
+	fakeInstalls(t, c)
+	SourceConcurrency = map[string]int{"brew": 1}
+	SourceTimeouts = map[string]time.Duration{"brew": 100 * time.Millisecond}
+
+	// The install runs a real command, so the timeout has to stop an actual process
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		c.enter("brew")
+		defer c.leave("brew")
+		return commandContext(ctx, "sleep", "30").CombinedOutput()
+	}
+
+	st := &state.State{Tools: map[string]state.ToolState{}}
+	start := time.Now()
+	SyncTools(concurrencyTools(2, 0), st)
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("SyncTools took %s; the timed-out installs were not killed", elapsed)
+	}
+	if c.finished != 2 || c.total != 0 {
+		t.Errorf("%d installs finished and %d still running when SyncTools returned, want 2 and 0", c.finished, c.total)
+	}
+	if c.peak["brew"] != 1 {
+		t.Errorf("peak concurrent brew installs = %d, want 1", c.peak["brew"])
+	}
+	if len(st.Tools) != 0 {
+		t.Errorf("timed-out installs were recorded: %v", st.Tools)
+	}
+}
+
+func TestCommandContextKillsCommandsIgnoringSIGTERM(t *testing.T) {
+	delay := commandWaitDelay
+	commandWaitDelay = 100 * time.Millisecond
+	t.Cleanup(func() { commandWaitDelay = delay })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := commandContext(ctx, "sh", "-c", `trap "" TERM; exec sleep 30`).Run()
+	if err == nil {
+		t.Error("command survived its context")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("command ran for %s after its context ended", elapsed)
+	}
+}
@@ -0,0 +1,75 @@
+package installer
+
+import (
+	"bufio"
+	"github.com/mattn/go-isatty"
+	"os"
+	"setup-machine/internal/logger"
+	"strings"
+	"sync"
+)
+
+// Confirmation categories. Settings and uninstalls can be auto-approved on their own
+// (--yes-settings, --yes-uninstall); every category is approved by --yes.
+const (
+	ConfirmSettings  = "settings"
+	ConfirmUninstall = "uninstall"
+	ConfirmLicense   = "license"
+)
+
+// AssumeYes auto-approves every confirmation. It's set via `--yes`.
+var AssumeYes bool
+
+// AssumeYesFor auto-approves confirmations of a single category, e.g. AssumeYesFor[ConfirmSettings].
+var AssumeYesFor = map[string]bool{}
+
+// approvedAll records categories the user answered "all" for during this run.
+var approvedAll = map[string]bool{}
+
+var (
+	confirmMu    sync.Mutex
+	confirmInput = bufio.NewReader(os.Stdin)
+)
+
+// isInteractive reports whether stdin is a terminal someone can answer prompts on.
+// A character-device check isn't enough: </dev/null is one too, but nobody is there to answer.
+var isInteractive = func() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
+// confirm asks the user to approve an action of the given category.
+// Precedence: the category flag (e.g. --yes-uninstall), then the global --yes, then an
+// earlier "all" answer for the category, then an interactive y/N/a prompt. Without a
+// terminal there is no one to ask, so nonInteractive is returned.
+func confirm(category, prompt string, nonInteractive bool) bool {
+	if AssumeYesFor[category] || AssumeYes {
+		return true
+	}
+
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+
+	if approvedAll[category] {
+		return true
+	}
+	if !isInteractive() {
+		return nonInteractive
+	}
+
+	// Log output from other tools waits until the question is answered
+	answer, err := logger.Prompt(prompt+" [y/N/a(ll)] ", func() (string, error) {
+		return confirmInput.ReadString('\n')
+	})
+	if err != nil {
+		logger.Debug("[DEBUG] Reading confirmation failed: %v\n", err)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "a", "all":
+		approvedAll[category] = true
+		return true
+	}
+	return false
+}
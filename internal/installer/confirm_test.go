@@ -0,0 +1,69 @@
+package installer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"setup-machine/internal/logger"
+)
+
+// syncBuffer is a bytes.Buffer that can be read while other goroutines write to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestConfirmPromptIsNotInterleavedWithLogs(t *testing.T) {
+	out := &syncBuffer{}
+	input, answer := io.Pipe()
+	origOut, origInput, origInteractive := color.Output, confirmInput, isInteractive
+	t.Cleanup(func() { color.Output, confirmInput, isInteractive = origOut, origInput, origInteractive })
+	color.Output, confirmInput = out, bufio.NewReader(input)
+	isInteractive = func() bool { return true }
+
+	approved := make(chan bool)
+	go func() { approved <- confirm(ConfirmUninstall, "Uninstall jq?", false) }()
+	for !strings.Contains(out.String(), "Uninstall jq?") {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Another tool logs while the question waits for an answer
+	logged := make(chan struct{})
+	go func() {
+		logger.WithPrefix("bat").Info("[INFO] Installed bat\n")
+		close(logged)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if got := out.String(); strings.Contains(got, "Installed bat") {
+		t.Errorf("a log line was written while the prompt waited for an answer:\n%s", got)
+	}
+
+	if _, err := answer.Write([]byte("y\n")); err != nil {
+		t.Fatal(err)
+	}
+	if !<-approved {
+		t.Error("the answer y did not approve")
+	}
+	<-logged
+	if got := out.String(); !strings.HasPrefix(got, "Uninstall jq? [y/N/a(ll)] ") || !strings.Contains(got, "Installed bat") {
+		t.Errorf("output = %q, want the prompt followed by the log line", got)
+	}
+}
@@ -0,0 +1,240 @@
+package installer
+
+import (
+	"os/exec"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runDefaults executes the macOS `defaults` command with the given arguments, through sudo if
+// asked, and returns its combined output. Tests replace it with an in-memory preferences store.
+var runDefaults = func(sudo bool, args ...string) ([]byte, error) {
+	cmd := exec.Command("defaults", args...)
+	if sudo {
+		cmd = exec.Command("sudo", append([]string{"defaults"}, args...)...)
+	}
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// defaultsCommandLine renders a `defaults` invocation for dry-run output and messages.
+func defaultsCommandLine(sudo bool, args []string) string {
+	line := "defaults " + strings.Join(args, " ")
+	if sudo {
+		line = "sudo " + line
+	}
+	return line
+}
+
+// defaultsArgs returns the `defaults` arguments for verb on a setting's key, addressing the
+// per-host preference (-currentHost) or the system-wide file in /Library/Preferences (sudo)
+// when the setting asks for it.
+func defaultsArgs(s config.Setting, verb string, rest ...string) []string {
+	var args []string
+	if s.CurrentHost {
+		args = append(args, "-currentHost")
+	}
+	args = append(args, verb, settingDomain(s), s.Key)
+	return append(args, rest...)
+}
+
+// settingDomain returns the domain argument for a setting. Sudo settings target the system-wide
+// preference file, which `defaults` only reaches through its path; NSGlobalDomain's is
+// .GlobalPreferences.
+func settingDomain(s config.Setting) string {
+	if !s.Sudo || strings.HasPrefix(s.Domain, "/") {
+		return s.Domain
+	}
+	domain := s.Domain
+	if domain == "NSGlobalDomain" || domain == "-g" || domain == "-globalDomain" {
+		domain = ".GlobalPreferences"
+	}
+	return "/Library/Preferences/" + domain
+}
+
+// readSetting returns the current value of a setting as printed by `defaults read`.
+func readSetting(s config.Setting) (string, error) {
+	output, err := runDefaults(s.Sudo, defaultsArgs(s, "read")...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// settingMatches reports whether a value printed by `defaults read` is equivalent to the
+// desired config value, taking the setting's type into account (e.g. bools read back as 1/0).
+func settingMatches(s config.Setting, actual string) bool {
+	switch s.Type {
+	case "bool":
+		want, ok1 := parseDefaultsBool(s.Value)
+		got, ok2 := parseDefaultsBool(actual)
+		return ok1 && ok2 && want == got
+	case "int", "float":
+		want, err1 := strconv.ParseFloat(s.Value, 64)
+		got, err2 := strconv.ParseFloat(actual, 64)
+		return err1 == nil && err2 == nil && want == got
+	case "array":
+		got, ok := parsePlistArray(actual)
+		return ok && equalLines(got, s.Items)
+	case "array-add":
+		// Satisfied once every item is present; other entries in the array are left alone
+		got, ok := parsePlistArray(actual)
+		if !ok {
+			return false
+		}
+		have := map[string]bool{}
+		for _, item := range got {
+			have[item] = true
+		}
+		for _, item := range s.Items {
+			if !have[item] {
+				return false
+			}
+		}
+		return true
+	case "dict":
+		got, ok := parsePlistDict(actual)
+		if !ok || len(got) != len(s.Dict) {
+			return false
+		}
+		for k, v := range s.Dict {
+			if gv, found := got[k]; !found || gv != v {
+				return false
+			}
+		}
+		return true
+	default:
+		return s.Value == actual
+	}
+}
+
+// parsePlistArray parses the old-style plist array printed by `defaults read`, e.g.
+// `( a, "b c" )`. Only flat arrays of scalars are supported; nested values report false.
+func parsePlistArray(v string) ([]string, bool) {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "(") || !strings.HasSuffix(v, ")") {
+		return nil, false
+	}
+	tokens, ok := plistTokens(v[1 : len(v)-1])
+	if !ok {
+		return nil, false
+	}
+	items := []string{}
+	for i := 0; i < len(tokens); i++ {
+		items = append(items, tokens[i])
+		if i+1 < len(tokens) {
+			if tokens[i+1] != "," {
+				return nil, false
+			}
+			i++
+		}
+	}
+	return items, true
+}
+
+// parsePlistDict parses the old-style plist dictionary printed by `defaults read`, e.g.
+// `{ key = value; "other key" = "a b"; }`. Only flat dictionaries of scalars are supported.
+func parsePlistDict(v string) (map[string]string, bool) {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "{") || !strings.HasSuffix(v, "}") {
+		return nil, false
+	}
+	tokens, ok := plistTokens(v[1 : len(v)-1])
+	if !ok || len(tokens)%4 != 0 {
+		return nil, false
+	}
+	dict := map[string]string{}
+	for i := 0; i < len(tokens); i += 4 {
+		if tokens[i+1] != "=" || tokens[i+3] != ";" {
+			return nil, false
+		}
+		dict[tokens[i]] = tokens[i+2]
+	}
+	return dict, true
+}
+
+// plistTokens splits the body of a plist array or dictionary into scalar values and the
+// separators `,`, `=`, and `;`. Quoted strings are unescaped. Nested containers report false.
+func plistTokens(s string) ([]string, bool) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == ',' || c == '=' || c == ';':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '(' || c == ')' || c == '{' || c == '}':
+			return nil, false
+		case c == '"':
+			var b strings.Builder
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, false
+			}
+			tokens = append(tokens, b.String())
+			i++
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r,=;(){}\"", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, s[start:i])
+		}
+	}
+	return tokens, true
+}
+
+// parseDefaultsBool parses the boolean spellings accepted by `defaults write -bool`
+// and the 1/0 form printed by `defaults read`.
+func parseDefaultsBool(v string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true, true
+	case "0", "false", "no":
+		return false, true
+	}
+	return false, false
+}
+
+// defaultsWriteArgs returns the `defaults` arguments that write a setting with its declared type.
+func defaultsWriteArgs(s config.Setting) []string {
+	args := defaultsArgs(s, "write")
+	switch s.Type {
+	case "bool":
+		args = append(args, "-bool", s.Value)
+	case "int":
+		args = append(args, "-int", s.Value)
+	case "float":
+		args = append(args, "-float", s.Value)
+	case "array", "array-add":
+		args = append(args, "-"+s.Type)
+		args = append(args, s.Items...)
+	case "dict":
+		// Sorted so the command (and dry-run output) is the same on every run
+		keys := make([]string, 0, len(s.Dict))
+		for k := range s.Dict {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		args = append(args, "-dict")
+		for _, k := range keys {
+			args = append(args, k, s.Dict[k])
+		}
+	default:
+		// Default to string type if none of the above
+		args = append(args, "-string", s.Value)
+	}
+	return args
+}
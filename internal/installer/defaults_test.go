@@ -0,0 +1,195 @@
+package installer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+// fakePrefs is an in-memory `defaults` store for fakeDefaults. Writes to keys in managed are
+// accepted but don't stick, like preferences enforced by MDM.
+type fakePrefs struct {
+	values  map[string]string
+	managed map[string]bool
+	writes  [][]string
+}
+
+// run handles the `defaults read` and `defaults write` calls SyncSettings makes. Values are
+// stored as the argument after the type flag, with bools in the 1/0 form `defaults read` prints.
+func (p *fakePrefs) run(sudo bool, args ...string) ([]byte, error) {
+	if args[0] == "-currentHost" {
+		args = args[1:]
+	}
+	id := args[1] + ":" + args[2]
+	switch args[0] {
+	case "read":
+		v, ok := p.values[id]
+		if !ok {
+			return nil, errors.New("does not exist")
+		}
+		return []byte(v + "\n"), nil
+	case "write":
+		p.writes = append(p.writes, args)
+		if p.managed[id] {
+			return nil, nil
+		}
+		v := args[4]
+		if args[3] == "-bool" {
+			if b, _ := parseDefaultsBool(v); b {
+				v = "1"
+			} else {
+				v = "0"
+			}
+		}
+		p.values[id] = v
+		return nil, nil
+	}
+	return nil, errors.New("unexpected defaults call")
+}
+
+// newFakePrefs installs a fakePrefs holding values as the `defaults` runner, and answers the
+// settings confirmation as a non-interactive run would.
+func newFakePrefs(t *testing.T, values map[string]string) *fakePrefs {
+	t.Helper()
+	p := &fakePrefs{values: values, managed: map[string]bool{}}
+	fakeDefaults(t, p.run)
+	interactive := isInteractive
+	isInteractive = func() bool { return false }
+	t.Cleanup(func() { isInteractive = interactive })
+	return p
+}
+
+func TestSyncSettingsSkipsValuesAlreadyOnTheSystem(t *testing.T) {
+	p := newFakePrefs(t, map[string]string{"com.example.app:ShowAll": "1"})
+	st := &state.State{Settings: map[string]state.SettingState{}}
+
+	SyncSettings([]config.Setting{{Domain: "com.example.app", Key: "ShowAll", Type: "bool", Value: "true"}}, st)
+
+	if len(p.writes) != 0 {
+		t.Errorf("wrote %v, want no writes for a value the system already has", p.writes)
+	}
+	got, ok := st.Settings["com.example.app:ShowAll"]
+	if !ok || got.Value != "true" || got.Previous != "1" {
+		t.Errorf("recorded %+v (ok=%v), want Value true with Previous 1", got, ok)
+	}
+}
+
+func TestSyncSettingsRecordsWrittenValues(t *testing.T) {
+	p := newFakePrefs(t, map[string]string{"com.example.app:Size": "32"})
+	st := &state.State{Settings: map[string]state.SettingState{}}
+
+	SyncSettings([]config.Setting{
+		{Domain: "com.example.app", Key: "Size", Type: "int", Value: "48"},
+		{Domain: "com.example.app", Key: "Name", Value: "new"},
+	}, st)
+
+	if len(p.writes) != 2 {
+		t.Fatalf("wrote %v, want 2 writes", p.writes)
+	}
+	if got := st.Settings["com.example.app:Size"]; got.Value != "48" || got.Previous != "32" || got.PreviousUnset {
+		t.Errorf("Size recorded as %+v, want Value 48 with Previous 32", got)
+	}
+	if got := st.Settings["com.example.app:Name"]; got.Value != "new" || !got.PreviousUnset {
+		t.Errorf("Name recorded as %+v, want Value new with PreviousUnset", got)
+	}
+}
+
+func TestSyncSettingsDoesNotRecordRevertedWrites(t *testing.T) {
+	p := newFakePrefs(t, map[string]string{"com.example.app:ShowAll": "0"})
+	p.managed["com.example.app:ShowAll"] = true
+	st := &state.State{Settings: map[string]state.SettingState{}}
+
+	SyncSettings([]config.Setting{{Domain: "com.example.app", Key: "ShowAll", Type: "bool", Value: "true"}}, st)
+
+	if len(p.writes) != 1 {
+		t.Errorf("wrote %v, want one write", p.writes)
+	}
+	if got, ok := st.Settings["com.example.app:ShowAll"]; ok {
+		t.Errorf("reverted setting was recorded as %+v", got)
+	}
+}
+
+func TestDefaultsWriteArgs(t *testing.T) {
+	tests := []struct {
+		setting config.Setting
+		want    string
+	}{
+		{config.Setting{Domain: "com.apple.dock", Key: "autohide", Type: "bool", Value: "true"}, "write com.apple.dock autohide -bool true"},
+		{config.Setting{Domain: "com.apple.dock", Key: "tilesize", Type: "int", Value: "48"}, "write com.apple.dock tilesize -int 48"},
+		{config.Setting{Domain: "com.example.app", Key: "Name", Value: "a b"}, "write com.example.app Name -string a b"},
+		{config.Setting{Domain: "com.example.app", Key: "Paths", Type: "array", Items: []string{"/a", "/b c"}}, "write com.example.app Paths -array /a /b c"},
+		{config.Setting{Domain: "com.example.app", Key: "Paths", Type: "array-add", Items: []string{"/c"}}, "write com.example.app Paths -array-add /c"},
+		{config.Setting{Domain: "com.example.app", Key: "Colors", Type: "dict", Dict: map[string]string{"fg": "white", "bg": "black"}}, "write com.example.app Colors -dict bg black fg white"},
+		{config.Setting{Domain: "com.apple.screensaver", Key: "idleTime", Type: "int", Value: "0", CurrentHost: true}, "-currentHost write com.apple.screensaver idleTime -int 0"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(defaultsWriteArgs(tt.setting), " "); got != tt.want {
+			t.Errorf("defaultsWriteArgs(%s) = %q, want %q", tt.setting.ID(), got, tt.want)
+		}
+	}
+}
+
+func TestSettingMatchesCollections(t *testing.T) {
+	array := config.Setting{Type: "array", Items: []string{"/a", "b c"}}
+	arrayAdd := config.Setting{Type: "array-add", Items: []string{"/a"}}
+	dict := config.Setting{Type: "dict", Dict: map[string]string{"fg": "white", "bg": "dark grey"}}
+
+	tests := []struct {
+		setting config.Setting
+		actual  string
+		want    bool
+	}{
+		{array, "(\n    \"/a\",\n    \"b c\"\n)", true},
+		{array, "(\n    \"b c\",\n    \"/a\"\n)", false},
+		{array, "(\n    \"/a\"\n)", false},
+		{array, "(\n    (\n        nested\n    )\n)", false},
+		{arrayAdd, "(\n    \"/z\",\n    \"/a\"\n)", true},
+		{arrayAdd, "(\n    \"/z\"\n)", false},
+		{dict, "{\n    bg = \"dark grey\";\n    fg = white;\n}", true},
+		{dict, "{\n    bg = \"dark grey\";\n}", false},
+		{dict, "{\n    bg = black;\n    fg = white;\n}", false},
+		{dict, "not a dict", false},
+	}
+	for _, tt := range tests {
+		if got := settingMatches(tt.setting, tt.actual); got != tt.want {
+			t.Errorf("settingMatches(%s, %q) = %v, want %v", tt.setting.Type, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestSyncSettingsAppliesApplyOnceSettingsOnce(t *testing.T) {
+	setting := config.Setting{Domain: "com.example.app", Key: "Theme", Value: "dark", ApplyOnce: true}
+
+	// First sync: not recorded yet, so it is written
+	p := newFakePrefs(t, map[string]string{"com.example.app:Theme": "light"})
+	st := &state.State{Settings: map[string]state.SettingState{}}
+	SyncSettings([]config.Setting{setting}, st)
+	if len(p.writes) != 1 {
+		t.Fatalf("first sync wrote %v, want one write", p.writes)
+	}
+
+	// The user changes it back, and the config's value changes too: both are left alone
+	p.values["com.example.app:Theme"] = "light"
+	setting.Value = "solarized"
+	SyncSettings([]config.Setting{setting}, st)
+	if len(p.writes) != 1 {
+		t.Errorf("apply-once setting was re-applied: %v", p.writes[1:])
+	}
+	if p.values["com.example.app:Theme"] != "light" {
+		t.Errorf("Theme = %s, want the user's light", p.values["com.example.app:Theme"])
+	}
+
+	// --force re-asserts it
+	Force = true
+	t.Cleanup(func() { Force = false })
+	SyncSettings([]config.Setting{setting}, st)
+	if p.values["com.example.app:Theme"] != "solarized" {
+		t.Errorf("Theme = %s after --force, want solarized", p.values["com.example.app:Theme"])
+	}
+	if got := st.Settings["com.example.app:Theme"]; got.Value != "solarized" || got.Previous != "light" {
+		t.Errorf("recorded %+v, want Value solarized with the original Previous light", got)
+	}
+}
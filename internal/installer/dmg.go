@@ -0,0 +1,69 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// applicationsDir is where app bundles from disk images are installed.
+const applicationsDir = "/Applications"
+
+// runHdiutil executes hdiutil with the given arguments and returns its combined output.
+// Tests swap it to check that images are detached again on every path.
+var runHdiutil = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "hdiutil", args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// installFromDMG mounts a downloaded disk image, copies the app bundle it contains into
+// /Applications (replacing an older copy), and unmounts it again. It returns the path of the
+// installed .app, which is what gets removed on uninstall.
+func installFromDMG(ctx context.Context, image string, log *logger.Logger) (string, error) {
+	mountPoint, err := os.MkdirTemp("", "setup-machine-dmg-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(mountPoint)
+
+	// -nobrowse keeps the volume out of Finder; stdin is /dev/null, so an image that shows a
+	// license agreement fails to attach instead of waiting for an answer
+	log.Debug("[DEBUG] Mounting %s at %s\n", image, mountPoint)
+	if output, err := runHdiutil(ctx, "attach", image, "-nobrowse", "-readonly", "-noautoopen", "-mountpoint", mountPoint); err != nil {
+		return "", fmt.Errorf("hdiutil attach failed (the image may require accepting a license agreement; install it manually): %v\nOutput: %s", err, output)
+	}
+	// Detaching must happen even when ctx was what stopped the install
+	defer func() {
+		if output, err := runHdiutil(context.Background(), "detach", mountPoint, "-quiet"); err != nil {
+			log.Debug("[DEBUG] hdiutil detach failed, forcing it: %v\nOutput: %s\n", err, output)
+			if output, err := runHdiutil(context.Background(), "detach", mountPoint, "-force", "-quiet"); err != nil {
+				log.Warn("[WARN] Failed to unmount %s: %v\nOutput: %s\n", mountPoint, err, output)
+			}
+		}
+	}()
+
+	apps, _ := filepath.Glob(filepath.Join(mountPoint, "*.app"))
+	if len(apps) == 0 {
+		return "", fmt.Errorf("no .app bundle found in %s", filepath.Base(image))
+	}
+	if len(apps) > 1 {
+		log.Warn("[WARN] %s contains %d app bundles; installing %s\n", filepath.Base(image), len(apps), filepath.Base(apps[0]))
+	}
+
+	dest := filepath.Join(applicationsDir, filepath.Base(apps[0]))
+	if err := os.RemoveAll(dest); err != nil {
+		return "", fmt.Errorf("cannot replace %s: %w", dest, err)
+	}
+	// ditto keeps the bundle's symlinks, permissions, and extended attributes (code signatures)
+	dittoCmd := commandContext(ctx, "ditto", apps[0], dest)
+	log.Trace("[TRACE] Running command: %s\n", strings.Join(dittoCmd.Args, " "))
+	if output, err := dittoCmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("copying %s to %s failed: %v\nOutput: %s", filepath.Base(apps[0]), applicationsDir, err, output)
+	}
+	log.Info("[INFO] Installed %s\n", dest)
+	return dest, nil
+}
@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/logger"
+)
+
+// fakeHdiutil replaces runHdiutil with a runner that records each call's verb and options
+// (the image and mount point vary per run). Verbs listed in fail return an error.
+func fakeHdiutil(t *testing.T, fail ...string) *[]string {
+	t.Helper()
+	orig := runHdiutil
+	t.Cleanup(func() { runHdiutil = orig })
+
+	var calls []string
+	runHdiutil = func(ctx context.Context, args ...string) ([]byte, error) {
+		call := args[0]
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") {
+				call += " " + arg
+			}
+		}
+		calls = append(calls, call)
+		for _, f := range fail {
+			if call == f {
+				return []byte("hdiutil: " + f + " failed"), errors.New("exit status 1")
+			}
+		}
+		return nil, nil
+	}
+	return &calls
+}
+
+func TestInstallFromDMGDetachesAfterFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		fail  []string
+		calls []string
+	}{
+		{
+			name:  "no app bundle in the image",
+			calls: []string{"attach -nobrowse -readonly -noautoopen -mountpoint", "detach -quiet"},
+		},
+		{
+			name:  "detach is forced when it fails",
+			fail:  []string{"detach -quiet"},
+			calls: []string{"attach -nobrowse -readonly -noautoopen -mountpoint", "detach -quiet", "detach -force -quiet"},
+		},
+		{
+			name:  "nothing to detach when attach fails",
+			fail:  []string{"attach -nobrowse -readonly -noautoopen -mountpoint"},
+			calls: []string{"attach -nobrowse -readonly -noautoopen -mountpoint"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeHdiutil(t, tt.fail...)
+			if _, err := installFromDMG(context.Background(), "Tool.dmg", &logger.Logger{}); err == nil {
+				t.Error("installFromDMG succeeded, want an error")
+			}
+			if strings.Join(*calls, "|") != strings.Join(tt.calls, "|") {
+				t.Errorf("hdiutil calls = %q, want %q", *calls, tt.calls)
+			}
+		})
+	}
+}
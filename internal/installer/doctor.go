@@ -0,0 +1,155 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"sort"
+	"strings"
+)
+
+// Check is one line of the `doctor` report: an external dependency or a bin directory.
+type Check struct {
+	Name     string // What was checked, e.g. "brew" or "bin dir /usr/local/bin"
+	OK       bool   // Whether the check passed
+	Required bool   // Whether the current config cannot be synced without it
+	Detail   string // Where it was found, or why it failed
+}
+
+// requiredBinaries maps each external command to the reason the config needs it.
+// Only commands used by what is actually configured are required.
+func requiredBinaries(cfg config.Config) map[string]string {
+	need := map[string]string{}
+	for _, tool := range cfg.Tools {
+		if !tool.IsEnabled() {
+			continue
+		}
+		switch tool.Source {
+		case "github", "url":
+			need["file"] = "detecting downloaded binaries"
+			if tool.Source == "url" {
+				need["chmod"] = "url tools"
+				if strings.HasSuffix(tool.URL, ".pkg") {
+					need["sudo"] = ".pkg installs"
+					need["installer"] = ".pkg installs"
+				}
+				if strings.HasSuffix(tool.URL, ".dmg") {
+					need["hdiutil"] = ".dmg installs"
+					need["ditto"] = ".dmg installs"
+				}
+			}
+		case "brew":
+			need["brew"] = "brew tools"
+		case "npm":
+			need["npm"] = "npm tools"
+		case "pipx":
+			need["pipx"] = "pipx tools"
+		case "pip":
+			need["python3"] = "pip tools"
+		case "apt":
+			need["apt-get"] = "apt tools"
+			need["sudo"] = "apt tools"
+		case "dnf":
+			need["dnf"] = "dnf tools"
+			need["sudo"] = "dnf tools"
+		case "script":
+			need["sh"] = "script tools"
+		}
+	}
+	if len(cfg.Settings) > 0 {
+		need["defaults"] = "macOS settings"
+	}
+	for _, s := range cfg.Settings {
+		if s.Sudo && s.IsEnabled() {
+			need["sudo"] = "system-wide settings"
+		}
+	}
+	if len(cfg.PreSync) > 0 || len(cfg.PostSync) > 0 {
+		need["sh"] = "pre_sync/post_sync hooks"
+	}
+	return need
+}
+
+// optionalBinaries are used only by some code paths (uninstalls, restarts, --host) and never fail doctor.
+var optionalBinaries = map[string]string{
+	"killall": "restarting apps after settings changes",
+	"pkgutil": "uninstalling .pkg installs",
+	"ssh":     "sync --host",
+}
+
+// Doctor checks that every external command the config relies on is on PATH, and that the
+// bin directories are writable and on PATH. It only looks; nothing is installed or changed.
+func Doctor(cfg config.Config) []Check {
+	var checks []Check
+
+	required := requiredBinaries(cfg)
+	names := make([]string, 0, len(required)+len(optionalBinaries))
+	for name := range required {
+		names = append(names, name)
+	}
+	for name := range optionalBinaries {
+		if _, ok := required[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		reason, isRequired := required[name]
+		if !isRequired {
+			reason = optionalBinaries[name]
+		}
+		check := Check{Name: name, Required: isRequired}
+		if path, err := exec.LookPath(name); err == nil {
+			check.OK = true
+			check.Detail = fmt.Sprintf("%s (for %s)", path, reason)
+		} else {
+			check.Detail = "not found on PATH (needed for " + reason + ")"
+		}
+		checks = append(checks, check)
+	}
+
+	return append(checks, binDirChecks(cfg)...)
+}
+
+// binDirChecks reports whether each bin directory is writable and on PATH. A single unusable
+// directory is only a warning, since installs fall back to the next one; it is required that
+// at least one is writable when tools are downloaded into them.
+func binDirChecks(cfg config.Config) []Check {
+	onPath := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		onPath[filepath.Clean(dir)] = true
+	}
+
+	var checks []Check
+	anyWritable := false
+	for _, entry := range BinDirs {
+		dir := filepath.Clean(resolveBinDir(entry))
+		check := Check{Name: "bin dir " + dir, OK: true}
+		var problems []string
+		if err := checkWritable(filepath.Join(dir, ".setup-machine-doctor")); err != nil {
+			problems = append(problems, "not writable: "+err.Error())
+		} else {
+			anyWritable = true
+		}
+		if !onPath[dir] {
+			problems = append(problems, "not on $PATH")
+		}
+		if len(problems) > 0 {
+			check.OK = false
+			check.Detail = strings.Join(problems, "; ")
+		} else {
+			check.Detail = "writable and on $PATH"
+		}
+		checks = append(checks, check)
+	}
+
+	if !anyWritable {
+		if _, ok := requiredBinaries(cfg)["file"]; ok {
+			checks = append(checks, Check{Name: "bin dirs", Required: true, Detail: "none of the bin directories is writable; github/url tools cannot be installed"})
+		}
+	}
+	return checks
+}
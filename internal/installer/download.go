@@ -0,0 +1,110 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds a whole HTTP exchange, including reading the body, so a server
+// that stops responding mid-download fails the attempt instead of hanging the sync.
+const DefaultHTTPTimeout = 5 * time.Minute
+
+// HTTPClient is used for every HTTP request made by the installer: GitHub API calls and
+// asset downloads alike. Its Timeout is set from the `--http-timeout` flag.
+var HTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// downloadFile fetches url and writes the response body to dest.
+// When the server sends a Content-Length, the number of bytes written must match it;
+// otherwise the download is treated as truncated and an error is returned, so a dropped
+// connection is caught here rather than as a confusing extraction failure later.
+// Network errors, truncated downloads, and 5xx responses are retried with backoff.
+// Progress is reported while large files download (see progress.go).
+func downloadFile(ctx context.Context, url, dest string, log *logger.Logger) error {
+	return withRetry(ctx, "Download of "+url, log, func() error {
+		return downloadOnce(ctx, url, dest, log)
+	})
+}
+
+// downloadOnce makes a single download attempt for downloadFile.
+func downloadOnce(ctx context.Context, url, dest string, log *logger.Logger) error {
+	log.Debug("[DEBUG] Downloading %s to %s\n", url, dest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// Release assets on a private GitHub (Enterprise) host need the token too; the client
+	// drops it again if the download redirects to another host
+	if token := githubTokenFor(url); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return retryable(fmt.Errorf("HTTP GET %s failed: %w", url, err))
+	}
+	defer resp.Body.Close()
+
+	traceResponse(log, resp)
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("HTTP GET %s failed: HTTP status %d", url, resp.StatusCode)
+		if isServerError(resp.StatusCode) {
+			return retryable(err)
+		}
+		return err
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	progress, done := trackProgress(log, path.Base(dest), resp.ContentLength)
+	written, copyErr := io.Copy(out, io.TeeReader(resp.Body, progress))
+	done()
+	closeErr := out.Close()
+	if copyErr != nil {
+		// A body cut short of its Content-Length surfaces as an unexpected EOF
+		if resp.ContentLength >= 0 && errors.Is(copyErr, io.ErrUnexpectedEOF) {
+			return retryable(fmt.Errorf("incomplete download of %s (got %d of %d bytes)", url, written, resp.ContentLength))
+		}
+		// Reading the body failed mid-transfer far more often than writing dest did
+		return retryable(fmt.Errorf("failed to write %s: %w", dest, copyErr))
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return retryable(fmt.Errorf("incomplete download of %s (got %d of %d bytes)", url, written, resp.ContentLength))
+	}
+	log.Debug("[DEBUG] Downloaded %d bytes to %s\n", written, dest)
+	return nil
+}
+
+// downloadPath returns where to download url to: a file named like the URL in a fresh
+// setup-machine-download-* directory under the system temp directory. remove deletes the
+// directory and everything extracted into it; when a run is killed before that, the
+// setup-machine- prefix is what lets the clean command find the leftovers.
+func downloadPath(url string) (file string, remove func(), err error) {
+	dir, err := os.MkdirTemp("", "setup-machine-download-*")
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(dir, path.Base(url)), func() { os.RemoveAll(dir) }, nil
+}
+
+// traceResponse logs the request and response details of an HTTP exchange at trace level.
+func traceResponse(log *logger.Logger, resp *http.Response) {
+	log.Trace("[TRACE] %s %s -> %s\n", resp.Request.Method, resp.Request.URL, resp.Status)
+	for name, values := range resp.Header {
+		log.Trace("[TRACE]   %s: %s\n", name, strings.Join(values, ", "))
+	}
+}
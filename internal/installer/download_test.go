@@ -0,0 +1,122 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+// fastRetries makes withRetry retry twice without waiting, for the duration of a test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	retries, delay := MaxRetries, RetryDelay
+	MaxRetries, RetryDelay = 2, time.Millisecond
+	t.Cleanup(func() { MaxRetries, RetryDelay = retries, delay })
+}
+
+func TestDownloadFileRejectsTruncatedBodies(t *testing.T) {
+	fastRetries(t)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Promise 1000 bytes, send 10, and drop the connection
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "asset.tar.gz")
+	err := downloadFile(context.Background(), srv.URL+"/asset.tar.gz", dest, &logger.Logger{})
+	if err == nil || !strings.Contains(err.Error(), "incomplete download") || !strings.Contains(err.Error(), "got 10 of 1000 bytes") {
+		t.Errorf("downloadFile error = %v, want an incomplete download of 10 of 1000 bytes", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("server saw %d attempts, want 3 (truncated downloads are retried)", got)
+	}
+}
+
+func TestDownloadFileRetriesUntilComplete(t *testing.T) {
+	fastRetries(t)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		if attempts.Add(1) == 1 {
+			w.Write([]byte("01234"))
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "asset.tar.gz")
+	if err := downloadFile(context.Background(), srv.URL+"/asset.tar.gz", dest, &logger.Logger{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "0123456789" {
+		t.Errorf("downloaded %q, want the complete body", data)
+	}
+}
+
+// useHTTPTimeout gives HTTPClient a short timeout for the duration of a test.
+func useHTTPTimeout(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	orig := HTTPClient
+	HTTPClient = &http.Client{Timeout: timeout}
+	t.Cleanup(func() { HTTPClient = orig })
+}
+
+// isTimeout reports whether err was caused by a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestHTTPRequestsTimeOut(t *testing.T) {
+	fastRetries(t)
+	useHTTPTimeout(t, 50*time.Millisecond)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".tar.gz") {
+			// Start the download, then stop responding mid-body
+			w.Header().Set("Content-Length", "1000")
+			w.Write([]byte("0123456789"))
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		get  func() error
+	}{
+		{"stalled download", func() error {
+			return downloadFile(context.Background(), srv.URL+"/asset.tar.gz", filepath.Join(t.TempDir(), "asset.tar.gz"), &logger.Logger{})
+		}},
+		{"unresponsive GitHub API", func() error {
+			tool := config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "1.0.0", APIBase: srv.URL}
+			_, _, _, err := resolveGitHubAsset(context.Background(), tool, &logger.Logger{})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		err := tt.get()
+		if !isTimeout(err) {
+			t.Errorf("%s: error = %v, want a timeout", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: took %s despite the 50ms timeout", tt.name, elapsed)
+		}
+	}
+}
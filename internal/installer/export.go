@@ -0,0 +1,101 @@
+package installer
+
+import (
+	"encoding/json"
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportConfig turns everything tracked in the state file into config entries with pinned
+// versions, so a machine can be reproduced elsewhere. Entries are sorted by name.
+// The state doesn't record everything a config holds (e.g. a github tool's repo, raw_configs),
+// so the result is a starting point to review rather than an exact copy.
+func ExportConfig(st *state.State) ([]config.Tool, []config.Setting, config.Aliases) {
+	var tools []config.Tool
+	for name, ts := range st.Tools {
+		if !ts.InstalledByDevSetup {
+			continue
+		}
+		tools = append(tools, config.Tool{Name: name, Version: ts.Version, Source: guessSource(ts), Cask: ts.Cask})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	var settings []config.Setting
+	for _, ss := range st.Settings {
+		s := config.Setting{Domain: ss.Domain, Key: ss.Key, Value: ss.Value, Type: ss.Type, CurrentHost: ss.CurrentHost, Sudo: ss.Sudo}
+		if s.Type == "" {
+			s.Type = guessSettingType(ss.Value)
+		}
+		// Array and dict values are recorded in their JSON form; restore the structured value
+		switch s.Type {
+		case "array", "array-add":
+			if json.Unmarshal([]byte(ss.Value), &s.Items) != nil {
+				s.Type = "string"
+			}
+		case "dict":
+			if json.Unmarshal([]byte(ss.Value), &s.Dict) != nil {
+				s.Type = "string"
+			}
+		}
+		settings = append(settings, s)
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Domain+":"+settings[i].Key < settings[j].Domain+":"+settings[j].Key
+	})
+
+	var aliases config.Aliases
+	for name, value := range st.Aliases {
+		aliases.Entries = append(aliases.Entries, config.Alias{Name: name, Value: value})
+	}
+	sort.Slice(aliases.Entries, func(i, j int) bool { return aliases.Entries[i].Name < aliases.Entries[j].Name })
+
+	return tools, settings, aliases
+}
+
+// guessSource returns the install source recorded for a tool, or, for state written before
+// sources were recorded, a best guess from where the tool was installed.
+func guessSource(ts state.ToolState) string {
+	if ts.Source != "" {
+		return ts.Source
+	}
+	p := ts.InstallPath
+	switch {
+	case strings.Contains(p, "/Cellar/"), strings.HasPrefix(p, "/opt/homebrew/"), strings.Contains(p, "/Homebrew/"), strings.Contains(p, "/linuxbrew/"):
+		return "brew"
+	case strings.Contains(p, "/node_modules/"), strings.Contains(p, "/lib/node"):
+		return "npm"
+	case strings.Contains(p, "/pipx/"):
+		return "pipx"
+	case strings.Contains(p, "/site-packages/"), strings.Contains(p, "/Library/Python/"):
+		return "pip"
+	case ts.ArtifactDir != "":
+		return "url"
+	}
+	return "github"
+}
+
+// guessSettingType infers the `defaults write` type of a value recorded as a string.
+func guessSettingType(value string) string {
+	switch {
+	case value == "true" || value == "false":
+		return "bool"
+	case isInt(value):
+		return "int"
+	case isFloat(value):
+		return "float"
+	}
+	return "string"
+}
+
+func isInt(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func isFloat(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
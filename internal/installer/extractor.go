@@ -12,14 +12,33 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"setup-machine/internal/config"
 	"setup-machine/internal/logger"
 	"strings"
 )
 
-// ExtractAndInstall extracts an archive and installs its binary/binaries into /usr/local/bin or fallback $HOME/bin
-func ExtractAndInstall(src, dest string) (string, error) {
-	// Extract the archive to the destination
-	extractedPath, err := ExtractArchive(src, dest)
+// ExtractAndInstall extracts an archive and installs its binary/binaries into the first usable BinDirs entry
+// When tool.Binaries is set only the executables with those file names are installed, and the
+// first one is the returned install path; otherwise every executable named like the tool is.
+// Messages are logged through log so they carry the calling tool's prefix.
+func ExtractAndInstall(src, dest string, tool config.Tool, log *logger.Logger) (string, error) {
+	// Extract into a fresh directory under dest: archives without a top-level directory are
+	// scanned as a whole, which must not pick up anything else that lives in dest (e.g. /tmp).
+	// Binaries are copied out before returning, so the directory is removed afterwards.
+	workDir, err := os.MkdirTemp(dest, "setup-machine-extract-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	// Extract the archive to the work directory
+	extractedPath, err := ExtractArchive(src, workDir)
+	if err != nil {
+		return "", err
+	}
+
+	// Unwrap doubly-packed release artifacts (archive within archive)
+	extractedPath, err = expandNestedArchives(extractedPath, log)
 	if err != nil {
 		return "", err
 	}
@@ -30,42 +49,55 @@ func ExtractAndInstall(src, dest string) (string, error) {
 		return "", err
 	}
 
-	// Infer tool name from source archive filename
-	toolName := extractToolNameFromPath(src)
+	// Match executables by the configured name (the repo part for owner/repo github tools);
+	// the archive file name is only a guess at it, used when the configured name matches nothing
+	toolName := filepath.Base(tool.Name)
+	guessedName := extractToolNameFromPath(src)
+	if tool.Name == "" {
+		toolName = guessedName
+	}
 
 	var binaries []string
-	// If extracted path is a directory, scan for binaries
-	if info.IsDir() {
-		binaries, err = findExecutables(extractedPath, toolName)
+	switch {
+	case info.IsDir() && len(tool.Binaries) > 0:
+		// Toolchain archives bundle many helpers; install only the requested executables
+		binaries, err = selectExecutables(extractedPath, tool.Binaries)
+		if err != nil {
+			return "", err
+		}
+	case info.IsDir():
+		// If extracted path is a directory, scan for binaries
+		binaries, err = findExecutables(extractedPath, toolName, log)
+		if err != nil && guessedName != toolName {
+			// e.g. a repo named differently from the binary it ships
+			log.Debug("[DEBUG] No executable named like %s; trying %s from the archive name\n", toolName, guessedName)
+			binaries, err = findExecutables(extractedPath, guessedName, log)
+		}
 		if err != nil || len(binaries) == 0 {
 			return "", fmt.Errorf("no binary found in folder: %w", err)
 		}
-	} else {
+	default:
 		// If it's a single file, assume it's the binary
 		binaries = []string{extractedPath}
 	}
+	for _, binary := range binaries {
+		log.Info("[INFO] Installing binary %s\n", filepath.Base(binary))
+	}
 
-	// Try to copy binaries to /usr/local/bin
-	destination := "/usr/local/bin"
-	for _, binaryPath := range binaries {
-		if err := copyBinary(binaryPath, destination); err != nil {
-			// If /usr/local/bin fails, fallback to ~/bin
-			homeBin := filepath.Join(os.Getenv("HOME"), "bin")
-			if err := os.MkdirAll(homeBin, 0755); err != nil {
-				return "", fmt.Errorf("cannot create fallback bin directory: %w", err)
-			}
-			destination = homeBin
-			if err := copyBinary(binaryPath, homeBin); err != nil {
-				return "", fmt.Errorf("failed to copy binary to fallback location: %w", err)
-			}
-		}
+	// Copy binaries to the first usable bin directory
+	destination, err := installToBinDir(binaries, log)
+	if err != nil {
+		return "", err
 	}
 
 	finalPath := filepath.Join(destination, filepath.Base(binaries[0]))
 	return finalPath, nil
 }
 
-// extractToolNameFromPath attempts to derive a reasonable tool name from a given archive path
+// extractToolNameFromPath attempts to derive a reasonable tool name from a given archive path.
+// Release archives are named <tool>-<version>-<os>-<arch>, and the tool name may itself contain
+// dashes (golangci-lint, aws-vault), so the name is everything before the first segment that
+// looks like a version, OS, or architecture.
 func extractToolNameFromPath(path string) string {
 	filename := filepath.Base(path)
 
@@ -77,37 +109,89 @@ func extractToolNameFromPath(path string) string {
 		}
 	}
 
-	// Split on delimiters like "-" or "_" and return the first part
-	parts := strings.FieldsFunc(filename, func(r rune) bool {
-		return r == '-' || r == '_'
-	})
-
-	if len(parts) > 0 {
-		return parts[0]
+	// Walk the segments between "-" and "_" delimiters and cut before the first platform or
+	// version segment; the first segment always belongs to the name
+	start := 0
+	for i := 0; i <= len(filename); i++ {
+		if i < len(filename) && filename[i] != '-' && filename[i] != '_' {
+			continue
+		}
+		if start > 0 && isPlatformSegment(filename[start:i]) {
+			return strings.TrimRight(filename[:start], "-_")
+		}
+		start = i + 1
 	}
 	return filename
 }
 
+// platformSegments are archive name segments that describe the build, not the tool.
+var platformSegments = map[string]bool{
+	"darwin": true, "macos": true, "mac": true, "osx": true, "apple": true, "linux": true,
+	"windows": true, "win": true, "freebsd": true, "universal": true, "unknown": true,
+	"amd64": true, "x86": true, "x64": true, "x86_64": true, "arm64": true, "aarch64": true,
+	"arm": true, "armv7": true, "386": true, "i386": true,
+}
+
+// isPlatformSegment reports whether an archive name segment is a version (1.55, v0.24.0), an
+// OS, or an architecture.
+func isPlatformSegment(segment string) bool {
+	lower := strings.ToLower(segment)
+	if platformSegments[lower] {
+		return true
+	}
+	lower = strings.TrimPrefix(lower, "v")
+	return lower != "" && lower[0] >= '0' && lower[0] <= '9'
+}
+
 // ExtractArchive routes to appropriate extraction function based on archive type
+// At most maxExtractedBytes are written; see extractArchive.
 func ExtractArchive(src, dest string) (string, error) {
+	return extractArchive(src, dest, maxExtractedBytes)
+}
+
+// extractArchive extracts src into dest, failing as soon as the extracted files add up to more
+// than limit bytes. The limit is enforced while copying, so an archive bomb is stopped after
+// writing limit bytes instead of after filling the disk.
+func extractArchive(src, dest string, limit int64) (string, error) {
+	budget := &extractBudget{limit: limit}
 	switch {
 	case strings.HasSuffix(src, ".zip"):
 		logger.Debug("[Debug] compression type is zip")
-		return extractZip(src, dest)
+		return extractZip(src, dest, budget)
 	case strings.HasSuffix(src, ".7z"):
 		logger.Debug("[Debug] compression type is .7z")
-		return extract7z(src, dest)
+		return extract7z(src, dest, budget)
 	case strings.HasSuffix(src, ".tar"), strings.HasSuffix(src, ".tar.gz"), strings.HasSuffix(src, ".tgz"),
 		strings.HasSuffix(src, ".tar.bz2"), strings.HasSuffix(src, ".tar.xz"):
 		logger.Debug("[Debug] compression type is .tar.*")
-		return extractTarArchive(src, dest)
+		return extractTarArchive(src, dest, budget)
 	default:
 		return "", fmt.Errorf("unsupported archive format: %s", src)
 	}
 }
 
+// extractBudget counts the bytes an extraction has written against its limit.
+type extractBudget struct {
+	limit int64 // Bytes the extraction may write in total
+	used  int64 // Bytes written so far
+}
+
+// copy copies one entry from src to dst, charging it to the budget. It reads at most one byte
+// past what is left, which is enough to tell the entry doesn't fit.
+func (b *extractBudget) copy(dst io.Writer, src io.Reader) error {
+	n, err := io.CopyN(dst, src, b.limit-b.used+1)
+	b.used += n
+	if b.used > b.limit {
+		return fmt.Errorf("archive expands to more than %d bytes, the extraction limit", b.limit)
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
 // extractTarArchive handles tar and compressed tar variants
-func extractTarArchive(src, dest string) (string, error) {
+func extractTarArchive(src, dest string, budget *extractBudget) (string, error) {
 	logger.Debug("[Debug] uncompressing  %s to %s\n", src, dest)
 	f, err := os.Open(src)
 	if err != nil {
@@ -135,7 +219,7 @@ func extractTarArchive(src, dest string) (string, error) {
 	}
 
 	tr := tar.NewReader(reader)
-	var topLevel string
+	var root archiveRoot
 
 	// Iterate over each file in the archive
 	for {
@@ -147,55 +231,99 @@ func extractTarArchive(src, dest string) (string, error) {
 			return "", err
 		}
 
-		// Capture the top-level folder name
-		if topLevel == "" {
-			parts := strings.Split(hdr.Name, string(os.PathSeparator))
-			if len(parts) > 0 {
-				topLevel = parts[0]
-			}
+		// Track the top-level folder name
+		root.add(hdr.Name, hdr.Typeflag == tar.TypeDir)
+
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return "", err
+		}
+		// Never write through a symlink created by an earlier entry; it could point anywhere
+		if err := checkNoSymlinkParents(dest, target); err != nil {
+			return "", err
 		}
 
-		target := filepath.Join(dest, hdr.Name)
+		mode := hdr.FileInfo().Mode().Perm()
 		switch hdr.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0755); err != nil {
+			if err := os.MkdirAll(target, mode|0700); err != nil {
 				return "", err
 			}
 		case tar.TypeReg:
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
 				return "", err
 			}
-			outFile, err := os.Create(target)
+			// Keep the archived mode so executables stay executable
+			outFile, err := createEntryFile(target, mode)
 			if err != nil {
 				return "", err
 			}
-			if _, err := io.Copy(outFile, tr); err != nil {
+			if err := budget.copy(outFile, tr); err != nil {
 				outFile.Close()
 				return "", err
 			}
 			outFile.Close()
+		case tar.TypeSymlink:
+			// Symlinks are kept as-is, but only if they point inside dest
+			if filepath.IsAbs(hdr.Linkname) {
+				return "", fmt.Errorf("archive symlink %q points to absolute path %q", hdr.Name, hdr.Linkname)
+			}
+			if _, err := safeJoin(dest, filepath.Join(filepath.Dir(hdr.Name), hdr.Linkname)); err != nil {
+				return "", fmt.Errorf("archive symlink %q points outside the extraction directory: %w", hdr.Name, err)
+			}
+			// The lexical check above can't see earlier symlinks: with "a/c" -> "..", the target
+			// "c/../x" of "a/b" looks like "a/x" but resolves to a sibling of dest
+			if err := checkLinkTarget(dest, target, hdr.Linkname); err != nil {
+				return "", err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return "", err
+			}
+			_ = os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return "", err
+			}
+		case tar.TypeLink:
+			// Hard link names are relative to the archive root
+			source, err := safeJoin(dest, hdr.Linkname)
+			if err != nil {
+				return "", err
+			}
+			if err := checkNoSymlinkParents(dest, source); err != nil {
+				return "", err
+			}
+			if info, err := os.Lstat(source); err == nil && !info.Mode().IsRegular() {
+				return "", fmt.Errorf("archive hard link %q must point to a regular file, not %q", hdr.Name, hdr.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return "", err
+			}
+			_ = os.Remove(target)
+			if err := os.Link(source, target); err != nil {
+				return "", err
+			}
+		default:
+			logger.Debug("[DEBUG] Skipping tar entry %s of type %c\n", hdr.Name, hdr.Typeflag)
 		}
 	}
-	return filepath.Join(dest, topLevel), nil
+	return root.path(dest), nil
 }
 
 // extractZip extracts a .zip archive
-func extractZip(src, dest string) (string, error) {
+func extractZip(src, dest string, budget *extractBudget) (string, error) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return "", err
 	}
 	defer r.Close()
 
-	var topLevel string
+	var root archiveRoot
 	for _, f := range r.File {
-		path := filepath.Join(dest, f.Name)
-		if topLevel == "" {
-			parts := strings.Split(f.Name, string(os.PathSeparator))
-			if len(parts) > 0 {
-				topLevel = parts[0]
-			}
+		path, err := safeJoin(dest, f.Name)
+		if err != nil {
+			return "", err
 		}
+		root.add(f.Name, f.FileInfo().IsDir())
 		if f.FileInfo().IsDir() {
 			os.MkdirAll(path, 0755)
 			continue
@@ -203,7 +331,7 @@ func extractZip(src, dest string) (string, error) {
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 			return "", err
 		}
-		outFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+		outFile, err := createEntryFile(path, f.Mode().Perm())
 		if err != nil {
 			return "", err
 		}
@@ -212,33 +340,31 @@ func extractZip(src, dest string) (string, error) {
 			outFile.Close()
 			return "", err
 		}
-		_, err = io.Copy(outFile, rc)
+		err = budget.copy(outFile, rc)
 		rc.Close()
 		outFile.Close()
 		if err != nil {
 			return "", err
 		}
 	}
-	return filepath.Join(dest, topLevel), nil
+	return root.path(dest), nil
 }
 
 // extract7z handles .7z extraction using the sevenzip library
-func extract7z(src, dest string) (string, error) {
+func extract7z(src, dest string, budget *extractBudget) (string, error) {
 	r, err := sevenzip.OpenReader(src)
 	if err != nil {
 		return "", fmt.Errorf("failed to open 7z archive: %w", err)
 	}
 	defer r.Close()
 
-	var topLevel string
+	var root archiveRoot
 	for _, f := range r.File {
-		path := filepath.Join(dest, f.Name)
-		if topLevel == "" {
-			parts := strings.Split(f.Name, string(os.PathSeparator))
-			if len(parts) > 0 {
-				topLevel = parts[0]
-			}
+		path, err := safeJoin(dest, f.Name)
+		if err != nil {
+			return "", err
 		}
+		root.add(f.Name, f.FileInfo().IsDir())
 		if f.FileInfo().IsDir() {
 			os.MkdirAll(path, f.Mode())
 			continue
@@ -250,29 +376,157 @@ func extract7z(src, dest string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		outFile, err := os.Create(path)
+		outFile, err := createEntryFile(path, 0644)
 		if err != nil {
 			rc.Close()
 			return "", err
 		}
-		_, err = io.Copy(outFile, rc)
+		err = budget.copy(outFile, rc)
 		rc.Close()
 		outFile.Close()
 		if err != nil {
 			return "", err
 		}
 	}
-	return filepath.Join(dest, topLevel), nil
+	return root.path(dest), nil
+}
+
+// archiveRoot works out where an archive unpacked to from the names of its entries.
+type archiveRoot struct {
+	top     string // Top-level component shared by every entry so far
+	entries int    // Entries seen, not counting bare "./" entries
+	split   bool   // Entries have different top-level components
+	isDir   bool   // top is a directory (listed as one, or has entries below it)
+}
+
+// add records an archive entry.
+func (r *archiveRoot) add(name string, isDir bool) {
+	top, rest, _ := strings.Cut(cleanEntryName(name), "/")
+	if top == "" {
+		return
+	}
+	if r.entries == 0 {
+		r.top = top
+	} else if top != r.top {
+		r.split = true
+	}
+	r.entries++
+	if isDir || rest != "" {
+		r.isDir = true
+	}
+}
+
+// path returns the path to search for binaries after extracting into dest: the archive's
+// single top-level directory, or its single file, when it has one. Flat archives with several
+// entries at the root have no common prefix, so dest itself is returned and searched as a whole.
+func (r *archiveRoot) path(dest string) string {
+	if r.entries == 0 || r.split || (!r.isDir && r.entries > 1) {
+		return dest
+	}
+	return filepath.Join(dest, r.top)
+}
+
+// cleanEntryName normalizes an archive entry name for comparing paths. Tar, zip, and 7z all
+// store names with "/" separators regardless of the OS that built or extracts them (some
+// Windows tools write `\`), so names are compared on those rather than os.PathSeparator.
+// Leading "./" and "/" and trailing "/" are dropped, so "./tool/bin/" becomes "tool/bin".
+func cleanEntryName(name string) string {
+	name = strings.TrimLeft(strings.ReplaceAll(name, `\`, "/"), "/")
+	for strings.HasPrefix(name, "./") {
+		name = strings.TrimLeft(name[2:], "/")
+	}
+	if name == "." {
+		return ""
+	}
+	return strings.TrimSuffix(name, "/")
+}
+
+// safeJoin returns the path an archive entry extracts to, rejecting entries that would land
+// outside dest ("zip slip"), such as "../../etc/passwd". Release assets are arbitrary
+// downloads, so a crafted archive must not be able to write anywhere else on disk.
+func safeJoin(dest, name string) (string, error) {
+	// Absolute names would otherwise be quietly re-rooted under dest; refuse them instead
+	if filepath.IsAbs(name) || strings.HasPrefix(filepath.ToSlash(name), "/") {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	target := filepath.Join(dest, name)
+	rel, err := filepath.Rel(filepath.Clean(dest), target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q escapes the extraction directory %s", name, dest)
+	}
+	return target, nil
+}
+
+// checkNoSymlinkParents fails if any directory between dest and target is a symlink. A
+// symlink extracted earlier may be harmless on its own but still redirect later entries, e.g.
+// "a" -> "sub/.." followed by "a/b" -> "../x", which would escape dest.
+func checkNoSymlinkParents(dest, target string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	dir := dest
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return nil // Not created yet, so nothing below it can be redirected
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive entry %s would be written through symlink %s", target, dir)
+		}
+	}
+	return nil
+}
+
+// checkLinkTarget fails if the symlink at target, pointing to linkname, would resolve outside
+// dest or through another symlink. linkname is walked one component at a time from the link's
+// directory, the way the kernel resolves it, so earlier symlinks can't redirect it.
+func checkLinkTarget(dest, target, linkname string) error {
+	dest = filepath.Clean(dest)
+	dir := filepath.Dir(target)
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if dir == dest {
+				return fmt.Errorf("archive symlink %s points outside the extraction directory", target)
+			}
+			dir = filepath.Dir(dir)
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("archive symlink %s points through symlink %s", target, dir)
+		}
+	}
+	return nil
+}
+
+// createEntryFile creates the file for an archive entry. Whatever is already at path is
+// removed first and the file is created with O_EXCL, which fails rather than following a
+// symlink, so a link planted by an earlier entry can't redirect the write outside dest.
+func createEntryFile(path string, mode os.FileMode) (*os.File, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.IsDir() {
+			return nil, fmt.Errorf("archive entry %s would replace a directory", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
 }
 
 // findExecutables scans a directory tree and returns all executable files matching the tool name
-func findExecutables(root string, toolName string) ([]string, error) {
-	logger.Debug("[DEBUG] Scanning directory for executables: %s", root)
+func findExecutables(root string, toolName string, log *logger.Logger) ([]string, error) {
+	log.Debug("[DEBUG] Scanning directory for executables: %s", root)
 	var executables []string
 
 	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
-			logger.Debug("[DEBUG] WalkDir error: %v", err)
+			log.Debug("[DEBUG] WalkDir error: %v", err)
 			return err
 		}
 		if d.IsDir() {
@@ -280,7 +534,7 @@ func findExecutables(root string, toolName string) ([]string, error) {
 		}
 		info, err := d.Info()
 		if err != nil {
-			logger.Debug("[DEBUG] Failed to get file info for %s: %v", path, err)
+			log.Debug("[DEBUG] Failed to get file info for %s: %v", path, err)
 			return nil
 		}
 		mode := info.Mode()
@@ -293,19 +547,20 @@ func findExecutables(root string, toolName string) ([]string, error) {
 
 		// Check if it’s executable based on permissions
 		if mode.IsRegular() && (mode.Perm()&0111 != 0 || strings.HasPrefix(mode.String(), "-rwx")) {
-			logger.Debug("[DEBUG] Found executable (perm): %s", path)
+			log.Debug("[DEBUG] Found executable (perm): %s", path)
 			executables = append(executables, path)
 			return nil
 		}
 
 		// Fallback: use `file` command to determine if it’s executable
+		log.Trace("[TRACE] Running command: file --brief %s\n", path)
 		out, err := exec.Command("file", "--brief", path).Output()
 		if err != nil {
 			return nil
 		}
 		output := strings.ToLower(string(out))
 		if strings.Contains(output, "executable") || strings.Contains(output, "mach-o") || strings.Contains(output, "elf") {
-			logger.Debug("[DEBUG] Found executable via file command: %s", path)
+			log.Debug("[DEBUG] Found executable via file command: %s", path)
 			executables = append(executables, path)
 		}
 		return nil
@@ -320,7 +575,41 @@ func findExecutables(root string, toolName string) ([]string, error) {
 	return executables, nil
 }
 
-// copyBinary copies a file to a target directory with executable permissions
+// selectExecutables returns the path of each named executable under root, in the order of
+// names. The first regular file with a matching name wins; a missing name is an error rather
+// than a silently partial install.
+func selectExecutables(root string, names []string) ([]string, error) {
+	found := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.Type().IsRegular() && found[name] == "" {
+			found[name] = path
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var binaries []string
+	for _, name := range names {
+		path, ok := found[name]
+		if !ok {
+			return nil, fmt.Errorf("binary %s not found in archive", name)
+		}
+		binaries = append(binaries, path)
+	}
+	return binaries, nil
+}
+
+// copyBinary copies a file to a target directory with executable permissions.
+// The new contents are written to a temporary file in the same directory and then renamed
+// over the target. The rename is atomic, so the target is never observed half-written, and
+// it replaces the directory entry rather than the file itself, which avoids "text file busy"
+// (ETXTBSY) when the binary being upgraded is currently running.
 func copyBinary(src, dstDir string) error {
 	dst := filepath.Join(dstDir, filepath.Base(src))
 	in, err := os.Open(src)
@@ -329,12 +618,24 @@ func copyBinary(src, dstDir string) error {
 	}
 	defer in.Close()
 
-	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	tmp, err := os.CreateTemp(dstDir, "."+filepath.Base(src)+".tmp-*")
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	// Clean up the temp file on any failure; after a successful rename this is a no-op
+	defer os.Remove(tmp.Name())
 
-	_, err = io.Copy(out, in)
-	return err
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Set the executable mode before the file becomes visible under its final name
+	if err := tmp.Chmod(0755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
 }
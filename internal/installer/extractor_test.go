@@ -0,0 +1,485 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+// tarEntry is one entry of a test tar archive.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	mode     int64
+	body     string
+}
+
+// writeTar writes entries to a .tar file in a temp directory and returns its path.
+func writeTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: mode, Size: int64(len(e.body))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeZip writes files with the given names to a .zip file in a temp directory and returns
+// its path.
+func writeZip(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("pwned")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// extractDest returns an extraction directory inside a temp directory, so tests can check
+// that nothing was written next to it.
+func extractDest(t *testing.T) (dest, outside string) {
+	t.Helper()
+	outside = t.TempDir()
+	dest = filepath.Join(outside, "dest")
+	if err := os.Mkdir(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dest, outside
+}
+
+func TestExtractTarArchiveKeepsSymlinksAndModes(t *testing.T) {
+	src := writeTar(t, []tarEntry{
+		{name: "tool/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "tool/bin/tool", typeflag: tar.TypeReg, mode: 0755, body: "#!/bin/sh\n"},
+		{name: "tool/README", typeflag: tar.TypeReg, mode: 0644, body: "docs"},
+		{name: "tool/tool", typeflag: tar.TypeSymlink, linkname: "bin/tool"},
+		{name: "tool/tool-copy", typeflag: tar.TypeLink, linkname: "tool/bin/tool"},
+	})
+	dest, _ := extractDest(t)
+
+	root, err := extractTarArchive(src, dest, &extractBudget{limit: maxExtractedBytes})
+	if err != nil {
+		t.Fatalf("extractTarArchive: %v", err)
+	}
+	if want := filepath.Join(dest, "tool"); root != want {
+		t.Errorf("root = %s, want %s", root, want)
+	}
+
+	info, err := os.Stat(filepath.Join(dest, "tool/bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("tool/bin/tool mode = %v, want 0755", info.Mode().Perm())
+	}
+	info, err = os.Stat(filepath.Join(dest, "tool/README"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("tool/README mode = %v, want it not executable", info.Mode().Perm())
+	}
+
+	link, err := os.Readlink(filepath.Join(dest, "tool/tool"))
+	if err != nil {
+		t.Fatalf("tool/tool is not a symlink: %v", err)
+	}
+	if link != "bin/tool" {
+		t.Errorf("tool/tool -> %s, want bin/tool", link)
+	}
+	body, err := os.ReadFile(filepath.Join(dest, "tool/tool-copy"))
+	if err != nil || string(body) != "#!/bin/sh\n" {
+		t.Errorf("tool/tool-copy = %q, %v; want the hard-linked binary", body, err)
+	}
+}
+
+func TestExtractTarArchiveRejectsLinkEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{
+			name: "symlink chain through an earlier symlink",
+			entries: []tarEntry{
+				{name: "a/c", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "a/b", typeflag: tar.TypeSymlink, linkname: "c/../pwned"},
+				{name: "a/b", typeflag: tar.TypeReg, body: "pwned"},
+			},
+		},
+		{
+			name: "symlink to a parent directory",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "../pwned"},
+			},
+		},
+		{
+			name: "symlink to an absolute path",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "/tmp/pwned"},
+			},
+		},
+		{
+			name: "file written below a symlinked directory",
+			entries: []tarEntry{
+				{name: "d/", typeflag: tar.TypeDir, mode: 0755},
+				{name: "a", typeflag: tar.TypeSymlink, linkname: "d"},
+				{name: "a/pwned", typeflag: tar.TypeReg, body: "pwned"},
+			},
+		},
+		{
+			name: "hard link outside dest",
+			entries: []tarEntry{
+				{name: "a", typeflag: tar.TypeLink, linkname: "../pwned"},
+			},
+		},
+		{
+			name: "hard link through a symlink",
+			entries: []tarEntry{
+				{name: "a/c", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "b", typeflag: tar.TypeLink, linkname: "a/c/x"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := writeTar(t, tt.entries)
+			dest, outside := extractDest(t)
+			if err := os.WriteFile(filepath.Join(outside, "x"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := extractTarArchive(src, dest, &extractBudget{limit: maxExtractedBytes}); err == nil {
+				t.Error("extractTarArchive succeeded, want an error")
+			}
+			if _, err := os.Lstat(filepath.Join(outside, "pwned")); err == nil {
+				t.Error("archive wrote pwned outside the extraction directory")
+			}
+		})
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "tool/bin/tool", want: filepath.Join(dest, "tool", "bin", "tool")},
+		{name: "./tool", want: filepath.Join(dest, "tool")},
+		{name: "a/../b", want: filepath.Join(dest, "b")},
+		{name: "..x", want: filepath.Join(dest, "..x")},
+		{name: "../x", wantErr: true},
+		{name: "..", wantErr: true},
+		{name: "/abs", wantErr: true},
+		{name: "a/../../x", wantErr: true},
+		{name: "a/b/../../../x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin(dest, tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("safeJoin(%q) = %s, want an error", tt.name, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("safeJoin(%q) = %s, %v; want %s", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsEscapingEntries(t *testing.T) {
+	for _, name := range []string{"../x", "/abs", "a/../../x"} {
+		t.Run("tar "+name, func(t *testing.T) {
+			src := writeTar(t, []tarEntry{
+				{name: "ok", typeflag: tar.TypeReg, body: "ok"},
+				{name: name, typeflag: tar.TypeReg, body: "pwned"},
+			})
+			dest, outside := extractDest(t)
+			if _, err := extractTarArchive(src, dest, &extractBudget{limit: maxExtractedBytes}); err == nil {
+				t.Errorf("extractTarArchive accepted %q", name)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "x")); err == nil {
+				t.Errorf("%q was written outside the extraction directory", name)
+			}
+		})
+		t.Run("zip "+name, func(t *testing.T) {
+			src := writeZip(t, "ok", name)
+			dest, outside := extractDest(t)
+			if _, err := extractZip(src, dest, &extractBudget{limit: maxExtractedBytes}); err == nil {
+				t.Errorf("extractZip accepted %q", name)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "x")); err == nil {
+				t.Errorf("%q was written outside the extraction directory", name)
+			}
+		})
+	}
+}
+
+func TestCopyBinaryReplacesRunningBinary(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("no sleep binary to run")
+	}
+	data, err := os.ReadFile(sleep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	running := filepath.Join(binDir, "sleep")
+	if err := os.WriteFile(running, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(running, "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot run a copy of sleep: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	// The upgrade has the same name as the running binary
+	upgrade := filepath.Join(t.TempDir(), "sleep")
+	if err := os.WriteFile(upgrade, []byte("#!/bin/sh\necho upgraded\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyBinary(upgrade, binDir); err != nil {
+		t.Fatalf("copyBinary over a running binary: %v", err)
+	}
+
+	got, err := os.ReadFile(running)
+	if err != nil || string(got) != "#!/bin/sh\necho upgraded\n" {
+		t.Errorf("%s = %q, %v; want the upgrade", running, got, err)
+	}
+	info, err := os.Stat(running)
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("%s mode = %v, %v; want 0755", running, info.Mode().Perm(), err)
+	}
+	entries, _ := os.ReadDir(binDir)
+	if len(entries) != 1 {
+		t.Errorf("bin directory holds %d entries, want only the binary (no temp files left)", len(entries))
+	}
+	if cmd.ProcessState != nil {
+		t.Error("the running binary was stopped by the upgrade")
+	}
+}
+
+func TestArchiveRoot(t *testing.T) {
+	type entry struct {
+		name  string
+		isDir bool
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		want    string
+	}{
+		{name: "single top-level directory", entries: []entry{{"tool/", true}, {"tool/bin/", true}, {"tool/bin/tool", false}}, want: "tool"},
+		{name: "first entry is a file in the directory", entries: []entry{{"tool/README.md", false}, {"tool/", true}, {"tool/tool", false}}, want: "tool"},
+		{name: "directory never listed", entries: []entry{{"tool/LICENSE", false}, {"tool/tool", false}}, want: "tool"},
+		{name: "dot-slash prefixes", entries: []entry{{"./", true}, {"./tool/", true}, {"./tool/tool", false}}, want: "tool"},
+		{name: "backslash separators", entries: []entry{{`tool\LICENSE`, false}, {`tool\tool`, false}}, want: "tool"},
+		{name: "single file", entries: []entry{{"tool", false}}, want: "tool"},
+		{name: "flat archive", entries: []entry{{"tool", false}, {"LICENSE", false}, {"README.md", false}}, want: "."},
+		{name: "file next to a directory", entries: []entry{{"tool", false}, {"completions/", true}, {"completions/tool.bash", false}}, want: "."},
+		{name: "several directories", entries: []entry{{"bin/tool", false}, {"share/man/tool.1", false}}, want: "."},
+		{name: "empty", want: "."},
+	}
+
+	for _, tt := range tests {
+		var root archiveRoot
+		for _, e := range tt.entries {
+			root.add(e.name, e.isDir)
+		}
+		if got := root.path("/dest"); got != filepath.Join("/dest", tt.want) {
+			t.Errorf("%s: path = %s, want %s", tt.name, got, filepath.Join("/dest", tt.want))
+		}
+	}
+}
+
+func TestExtractReturnsArchiveRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		extract func(t *testing.T, dest string) (string, error)
+		want    string
+	}{
+		{"tar whose first entry is a file", func(t *testing.T, dest string) (string, error) {
+			src := writeTar(t, []tarEntry{{name: "tool/LICENSE"}, {name: "tool/", typeflag: tar.TypeDir}, {name: "tool/tool", mode: 0755}})
+			return extractTarArchive(src, dest, &extractBudget{limit: maxExtractedBytes})
+		}, "tool"},
+		{"flat tar", func(t *testing.T, dest string) (string, error) {
+			src := writeTar(t, []tarEntry{{name: "tool", mode: 0755}, {name: "LICENSE"}, {name: "README.md"}})
+			return extractTarArchive(src, dest, &extractBudget{limit: maxExtractedBytes})
+		}, "."},
+		{"zip whose first entry is a file", func(t *testing.T, dest string) (string, error) {
+			return extractZip(writeZip(t, "tool/LICENSE", "tool/tool"), dest, &extractBudget{limit: maxExtractedBytes})
+		}, "tool"},
+		{"flat zip", func(t *testing.T, dest string) (string, error) {
+			return extractZip(writeZip(t, "tool", "LICENSE", "README.md"), dest, &extractBudget{limit: maxExtractedBytes})
+		}, "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, _ := extractDest(t)
+			root, err := tt.extract(t, dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dest, tt.want); root != want {
+				t.Errorf("root = %s, want %s", root, want)
+			}
+			// Whatever the layout, the binary must be found below the returned root
+			if _, err := os.Stat(filepath.Join(dest, tt.want, "tool")); err != nil {
+				t.Errorf("tool was not extracted: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractToolNameFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/tmp/golangci-lint-1.55.2-darwin-arm64.tar.gz":   "golangci-lint",
+		"golangci-lint-1.55-darwin.tar.gz":                "golangci-lint",
+		"aws-vault-linux-amd64.zip":                       "aws-vault",
+		"aws-vault_v7.2.0_darwin_arm64.tar.gz":            "aws-vault",
+		"ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz": "ripgrep",
+		"bat-v0.24.0-aarch64-apple-darwin.tar.gz":         "bat",
+		"fd_9.0.0_linux_amd64.tar.gz":                     "fd",
+		"jq.7z":                                           "jq",
+		"1password-cli-2.0.zip":                           "1password-cli",
+	}
+	for path, want := range tests {
+		if got := extractToolNameFromPath(path); got != want {
+			t.Errorf("extractToolNameFromPath(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+// writeTarGz writes entries to a gzipped tar file with the given name in a temp directory.
+func writeTarGz(t *testing.T, name string, entries []tarEntry) string {
+	t.Helper()
+	tarball, err := os.ReadFile(writeTar(t, entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	zw.Write(tarball)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractAndInstallMatchesHyphenatedNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+		tool    config.Tool
+		files   []string
+		want    string
+	}{
+		{
+			// golangci is a different executable that shares the prefix of the archive's first segment
+			name:    "configured name",
+			archive: "golangci-lint-1.55.2-linux-amd64.tar.gz",
+			tool:    config.Tool{Name: "golangci-lint"},
+			files:   []string{"golangci-lint-1.55.2-linux-amd64/golangci", "golangci-lint-1.55.2-linux-amd64/golangci-lint", "golangci-lint-1.55.2-linux-amd64/LICENSE"},
+			want:    "golangci-lint",
+		},
+		{
+			name:    "owner/repo name",
+			archive: "aws-vault_v7.2.0_linux_amd64.tar.gz",
+			tool:    config.Tool{Name: "99designs/aws-vault"},
+			files:   []string{"aws-vault", "README.md"},
+			want:    "aws-vault",
+		},
+		{
+			name:    "archive name when the configured name matches nothing",
+			archive: "aws-vault-7.2.0-linux-amd64.tar.gz",
+			tool:    config.Tool{Name: "vault-helper"},
+			files:   []string{"aws-vault-7.2.0/aws-vault", "aws-vault-7.2.0/LICENSE"},
+			want:    "aws-vault",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := filepath.Join(t.TempDir(), "bin")
+			useBinDirs(t, bin)
+			var entries []tarEntry
+			for _, file := range tt.files {
+				mode := int64(0644)
+				if filepath.Ext(file) == "" && filepath.Base(file) != "LICENSE" {
+					mode = 0755
+				}
+				entries = append(entries, tarEntry{name: file, mode: mode, body: "#!/bin/sh\n"})
+			}
+			src := writeTarGz(t, tt.archive, entries)
+
+			installed, err := ExtractAndInstall(src, t.TempDir(), tt.tool, &logger.Logger{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(bin, tt.want); installed != want {
+				t.Errorf("installed %s, want %s", installed, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"strings"
+)
+
+// syncManagedFiles places the config/dotfiles declared in a tool's `files:` section and
+// returns the managed files to record in the tool's state. Files are only rewritten when their
+// content differs from the desired content, and files that were managed before but are no
+// longer declared are removed.
+func syncManagedFiles(tool config.Tool, ts state.ToolState, log *logger.Logger) map[string]string {
+	if len(tool.Files) == 0 && len(ts.Files) == 0 {
+		return nil
+	}
+
+	managed := map[string]string{}
+	for _, spec := range tool.Files {
+		dest := expandHome(spec.Dest)
+		if DryRun && spec.Source != "" {
+			log.Info("[DRY-RUN] Would download %s to %s\n", spec.Source, dest)
+			continue
+		}
+		content, err := managedFileContent(tool, ts, spec, log)
+		if err != nil {
+			log.Error("[ERROR] Failed to prepare %s: %v\n", dest, err)
+			// Keep tracking a previously managed file so a transient failure doesn't orphan it
+			if sum, ok := ts.Files[dest]; ok {
+				managed[dest] = sum
+			}
+			continue
+		}
+
+		sum := sha256.Sum256(content)
+		want := hex.EncodeToString(sum[:])
+		managed[dest] = want
+
+		if current, err := fileSHA256(dest); err == nil && current == want {
+			log.Debug("[DEBUG] %s is up to date\n", dest)
+			continue
+		}
+		if DryRun {
+			log.Info("[DRY-RUN] Would write managed file %s\n", dest)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			log.Error("[ERROR] Cannot create directory for %s: %v\n", dest, err)
+			continue
+		}
+		if err := os.WriteFile(dest, content, 0644); err != nil {
+			log.Error("[ERROR] Failed to write %s: %v\n", dest, err)
+			continue
+		}
+		log.Info("[INFO] Wrote managed file %s\n", dest)
+	}
+
+	// Remove files that were managed previously but are no longer declared
+	for path := range ts.Files {
+		if _, ok := managed[path]; !ok {
+			if DryRun {
+				log.Info("[DRY-RUN] Would remove managed file %s\n", path)
+				continue
+			}
+			removeManagedFile(path, log)
+		}
+	}
+
+	if len(managed) == 0 {
+		return nil
+	}
+	return managed
+}
+
+// managedFileContent returns the rendered content for a file spec, taken either from the
+// inline `content` or downloaded from `source`. Placeholders {{name}}, {{version}}, {{home}}
+// and {{install_path}} are expanded.
+func managedFileContent(tool config.Tool, ts state.ToolState, spec config.FileSpec, log *logger.Logger) ([]byte, error) {
+	var raw string
+	switch {
+	case spec.Content != "":
+		raw = spec.Content
+	case spec.Source != "":
+		tmp, err := os.CreateTemp("", "setup-machine-file-")
+		if err != nil {
+			return nil, err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err := downloadFile(context.Background(), spec.Source, tmp.Name(), log); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return nil, err
+		}
+		raw = string(data)
+	default:
+		return nil, fmt.Errorf("file spec for %s has neither content nor source", spec.Dest)
+	}
+
+	return []byte(strings.NewReplacer(
+		"{{name}}", tool.Name,
+		"{{version}}", tool.Version,
+		"{{home}}", os.Getenv("HOME"),
+		"{{install_path}}", ts.InstallPath,
+	).Replace(raw)), nil
+}
+
+// removeManagedFile deletes a file previously placed by syncManagedFiles.
+func removeManagedFile(path string, log *logger.Logger) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warn("[WARN] Failed to remove managed file %s: %v\n", path, err)
+		return
+	}
+	log.Info("[INFO] Removed managed file %s\n", path)
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) string {
+	if path == "~" {
+		return os.Getenv("HOME")
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
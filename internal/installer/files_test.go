@@ -0,0 +1,86 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+func TestSyncManagedFilesPlacesUpdatesAndRemoves(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("theme = {{name}}\n"))
+	}))
+	defer srv.Close()
+
+	configFile := filepath.Join(home, ".config", "tool", "config.toml")
+	theme := filepath.Join(home, ".config", "tool", "theme.toml")
+	tool := config.Tool{Name: "tool", Version: "1.0", Files: []config.FileSpec{
+		{Dest: "~/.config/tool/config.toml", Content: "version = {{version}}\nhome = {{home}}\nbin = {{install_path}}\n"},
+		{Dest: "~/.config/tool/theme.toml", Source: srv.URL + "/theme.toml"},
+	}}
+	ts := state.ToolState{InstallPath: "/usr/local/bin/tool"}
+
+	// First sync places both files, with placeholders expanded
+	ts.Files = syncManagedFiles(tool, ts, &logger.Logger{})
+	if len(ts.Files) != 2 {
+		t.Fatalf("managed files = %v, want both", ts.Files)
+	}
+	if got, _ := os.ReadFile(configFile); string(got) != "version = 1.0\nhome = "+home+"\nbin = /usr/local/bin/tool\n" {
+		t.Errorf("config.toml = %q", got)
+	}
+	if got, _ := os.ReadFile(theme); string(got) != "theme = tool\n" {
+		t.Errorf("theme.toml = %q", got)
+	}
+
+	// A changed version rewrites config.toml; theme.toml is left alone
+	before, _ := os.Stat(theme)
+	tool.Version = "2.0"
+	ts.Files = syncManagedFiles(tool, ts, &logger.Logger{})
+	if got, _ := os.ReadFile(configFile); string(got) != "version = 2.0\nhome = "+home+"\nbin = /usr/local/bin/tool\n" {
+		t.Errorf("config.toml after the upgrade = %q", got)
+	}
+	if after, _ := os.Stat(theme); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("theme.toml was rewritten although its content didn't change")
+	}
+
+	// Dropping a file from the config removes it
+	tool.Files = tool.Files[:1]
+	ts.Files = syncManagedFiles(tool, ts, &logger.Logger{})
+	if _, err := os.Stat(theme); !os.IsNotExist(err) {
+		t.Errorf("theme.toml still exists after being dropped from the config: %v", err)
+	}
+	if _, ok := ts.Files[theme]; ok || len(ts.Files) != 1 {
+		t.Errorf("managed files = %v, want only config.toml", ts.Files)
+	}
+}
+
+func TestSyncManagedFilesKeepsTrackingOnFailure(t *testing.T) {
+	fastRetries(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	dest := filepath.Join(home, ".toolrc")
+	tool := config.Tool{Name: "tool", Files: []config.FileSpec{{Dest: "~/.toolrc", Source: srv.URL + "/toolrc"}}}
+	ts := state.ToolState{Files: map[string]string{dest: "previous-sha"}}
+	if err := os.WriteFile(dest, []byte("placed earlier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := syncManagedFiles(tool, ts, &logger.Logger{})
+	if files[dest] != "previous-sha" {
+		t.Errorf("managed files = %v, want %s still tracked", files, dest)
+	}
+	if _, err := os.Stat(dest); err != nil {
+		t.Errorf("%s was removed after a failed download: %v", dest, err)
+	}
+}
@@ -1,21 +1,38 @@
 package installer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os/exec"
+	"net/url"
 	"path"
+	"path/filepath"
 	"runtime"
 	"setup-machine/internal/config"
 	"setup-machine/internal/logger"
+	"setup-machine/internal/version"
 	"strings"
 )
 
+// DefaultGitHubAPIBase is the public GitHub REST API endpoint.
+const DefaultGitHubAPIBase = "https://api.github.com"
+
+// GitHubAPIBase is the API base URL used for github tools that don't set their own APIBase.
+// It can point to a GitHub Enterprise Server instance, e.g. https://ghe.example.com/api/v3.
+var GitHubAPIBase = DefaultGitHubAPIBase
+
+// GitHubToken, when set, authenticates release-metadata requests to the GitHub API and asset
+// downloads from the same host (see githubTokenFor). Anonymous requests are limited to 60 per
+// hour, which a large tool list quickly exhausts.
+var GitHubToken string
+
 // GitHubRelease represents the structure of a GitHub release JSON response.
 type GitHubRelease struct {
-	TagName string `json:"tag_name"` // The release tag (e.g., v1.0.0)
-	Assets  []struct {
+	TagName    string `json:"tag_name"`   // The release tag (e.g., v1.0.0)
+	Draft      bool   `json:"draft"`      // Unpublished release (only visible with push access)
+	Prerelease bool   `json:"prerelease"` // Marked as a pre-release by the maintainers
+	Assets     []struct {
 		Name               string `json:"name"`                 // Asset filename
 		BrowserDownloadURL string `json:"browser_download_url"` // Direct download URL for the asset
 	} `json:"assets"`
@@ -24,59 +41,86 @@ type GitHubRelease struct {
 // downloadFromGitHub downloads a specific version of a tool from GitHub Releases.
 // It locates the asset matching the OS/Arch, downloads it, extracts the archive,
 // finds the executable, installs it, and returns the installed path.
-func downloadFromGitHub(tool config.Tool) (string, error) {
-	// Determine the GitHub repository and tag
-	repo := tool.Name
-	tag := "v" + tool.Version
-	if tool.Repo != "" {
-		repo = tool.Repo
+func downloadFromGitHub(ctx context.Context, tool config.Tool, log *logger.Logger) (string, error) {
+	release, assetURL, assetName, err := resolveGitHubAsset(ctx, tool, log)
+	if err != nil {
+		return "", err
 	}
-	if tool.Tag != "" {
-		tag = tool.Tag
+
+	// Look up the expected checksum first; it also keys the download cache
+	expected, err := expectedChecksum(ctx, tool, release, assetName, log)
+	if err != nil {
+		return "", err
 	}
 
-	// Build GitHub API URL to fetch the release metadata
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, tag)
-	logger.Debug("[DEBUG] Fetching GitHub release from URL: %s\n", url)
+	// Download the asset to a temporary location (or reuse a cached copy), verifying it
+	// before anything from it is unpacked
+	compressedAssetName, remove, err := downloadPath(assetURL)
+	if err != nil {
+		return "", err
+	}
+	defer remove()
+	log.Info("[INFO] Downloading asset %s to %s\n", assetName, compressedAssetName)
+	if err := cachedDownload(ctx, assetURL, compressedAssetName, expected, log); err != nil {
+		return "", fmt.Errorf("failed to download asset %s: %w", assetName, err)
+	}
 
-	// Make HTTP request to GitHub API
-	resp, err := http.Get(url)
+	// Extract the downloaded archive
+	asset, err := ExtractAndInstall(compressedAssetName, filepath.Dir(compressedAssetName), tool, log)
 	if err != nil {
-		return "", fmt.Errorf("HTTP GET error fetching release for %s@%s: %w", tool.Name, tool.Version, err)
+		return "", fmt.Errorf("failed to extract archive: %v", err)
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			logger.Warn("[WARN] Failed to close HTTP response body: %v\n", cerr)
-		}
-	}()
 
-	// Handle non-200 responses
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("GitHub release fetch failed for %s@%s: HTTP status %d", tool.Name, tool.Version, resp.StatusCode)
+	log.Debug("[DEBUG] Extracted asset to %s\n", asset)
+	log.Info("[INFO] Installed %s \n", asset)
+	return asset, nil
+}
+
+// resolveGitHubAsset fetches the release metadata for a tool and picks the asset matching
+// the running OS/Arch. It only reads from the GitHub API; nothing is downloaded or installed.
+func resolveGitHubAsset(ctx context.Context, tool config.Tool, log *logger.Logger) (release GitHubRelease, assetURL, assetName string, err error) {
+	// Determine the GitHub repository and tag
+	tag := "v" + tool.Version
+	if tool.Tag != "" {
+		tag = tool.Tag
+	}
+	repo, err := githubRepo(tool)
+	if err != nil {
+		return release, "", "", err
 	}
 
-	// Parse the JSON response into the GitHubRelease struct
-	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return "", fmt.Errorf("failed to decode GitHub release JSON for %s@%s: %w", tool.Name, tool.Version, err)
+	// Build GitHub API URL to fetch the release metadata
+	url := fmt.Sprintf("%s/repos/%s/releases/tags/%s", githubAPIBase(tool), repo, tag)
+	log.Debug("[DEBUG] Fetching GitHub release from URL: %s\n", url)
+
+	// Fetch the release metadata, retrying transient failures
+	err = withRetry(ctx, "GitHub release fetch for "+tool.Name, log, func() error {
+		release, err = fetchRelease(ctx, url, tool, repo, tag, log)
+		return err
+	})
+	if err != nil {
+		return release, "", "", err
 	}
-	logger.Debug("[DEBUG] Release tag: %s with %d assets\n", release.TagName, len(release.Assets))
+	log.Debug("[DEBUG] Release tag: %s with %d assets\n", release.TagName, len(release.Assets))
 
 	// Detect local OS and architecture
 	arch := strings.ToLower(runtime.GOARCH)
 	osys := strings.ToLower(runtime.GOOS)
-	logger.Debug("[DEBUG] Looking for asset matching OS=%s or macos ARCH=%s\n", osys, arch)
+	log.Debug("[DEBUG] Looking for asset matching OS=%s ARCH=%s\n", osys, arch)
 
-	// Define preferred asset filename patterns for macOS/arm64
-	preferredPatterns := []string{
-		"darwin_amd64", "darwin-arm64", "darwin_aarch64", "aarch64-apple-darwin", "macos", "macOS_amd64", "macos_amd64",
+	// An explicit pattern from the config takes precedence over guessing
+	if tool.AssetPattern != "" {
+		assetURL, assetName, err = matchAssetPattern(tool, release, osys, arch, log)
+		return release, assetURL, assetName, err
 	}
 
+	// Asset filename patterns for the running platform, in order of preference
+	preferredPatterns := assetPatterns(osys, arch)
+
 	// Search for an asset that matches the preferred patterns
-	var assetURL, assetName string
 	for _, pattern := range preferredPatterns {
 		for _, asset := range release.Assets {
-			logger.Debug("[DEBUG] Within Release Patten matching asset: %s with name: %s\n", asset.BrowserDownloadURL, asset.Name)
+			log.Debug("[DEBUG] Within Release Patten matching asset: %s with name: %s\n", asset.BrowserDownloadURL, asset.Name)
 			assetNameLower := strings.ToLower(asset.Name)
 			if strings.Contains(assetNameLower, pattern) &&
 				(strings.HasSuffix(assetNameLower, ".tar.gz") ||
@@ -86,7 +130,7 @@ func downloadFromGitHub(tool config.Tool) (string, error) {
 					strings.HasSuffix(assetNameLower, ".zip")) {
 				assetURL = asset.BrowserDownloadURL
 				assetName = asset.Name
-				logger.Debug("[DEBUG] Found matching asset: %s\n", assetName)
+				log.Debug("[DEBUG] Found matching asset: %s\n", assetName)
 				break
 			}
 		}
@@ -97,26 +141,324 @@ func downloadFromGitHub(tool config.Tool) (string, error) {
 
 	// Fail if no matching asset was found
 	if assetURL == "" {
-		return "", fmt.Errorf("no matching asset found for OS=%s or macos, ARCH=%s in release %s", osys, arch, release.TagName)
+		return release, "", "", fmt.Errorf("no matching asset found for OS=%s, ARCH=%s in release %s", osys, arch, release.TagName)
 	}
 
-	// Download the asset to a temporary location using curl
-	compressedAssetName := "/tmp/" + path.Base(assetURL)
-	logger.Info("[INFO] Downloading asset %s to %s\n", assetName, compressedAssetName)
-	curlCmd := exec.Command("curl", "-L", assetURL, "-o", compressedAssetName)
-	logger.Debug("[DEBUG] Running command: %s\n", strings.Join(curlCmd.Args, " "))
-	output, err := curlCmd.CombinedOutput()
+	return release, assetURL, assetName, nil
+}
+
+// ResolveVersion pins a github tool whose version floats to a concrete release: the newest
+// one for "latest" (see config.Tool.WantsLatest), or the highest stable release satisfying a
+// range such as ">=1.2.0 <2.0.0" or "~1.4". Version becomes the release tag without a leading
+// "v" and Tag the exact tag, so the install and the recorded state use a concrete version and
+// the tool only upgrades when the selected release changes. Other tools are returned unchanged.
+func ResolveVersion(tool config.Tool, log *logger.Logger) (config.Tool, error) {
+	if tool.Source != "github" || !(tool.WantsLatest() || version.IsConstraint(tool.Version)) {
+		return tool, nil
+	}
+	repo, err := githubRepo(tool)
 	if err != nil {
-		return "", fmt.Errorf("failed to download asset %s: %v\nOutput: %s", assetName, err, output)
+		return tool, err
 	}
 
-	// Extract the downloaded archive
-	asset, err := ExtractAndInstall(compressedAssetName, "/tmp/")
+	var tag string
+	if tool.WantsLatest() {
+		tag, err = latestTag(context.Background(), tool, repo, log)
+	} else {
+		tag, err = matchingTag(context.Background(), tool, repo, log)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to extract archive: %v", err)
+		return tool, err
 	}
 
-	logger.Debug("[DEBUG] Extracted asset to %s\n", asset)
-	logger.Info("[INFO] Installed %s \n", asset)
-	return asset, nil
+	log.Debug("[DEBUG] Resolved %s %q to release %s\n", repo, tool.Version, tag)
+	tool.Tag = tag
+	tool.Version = strings.TrimPrefix(tag, "v")
+	return tool, nil
+}
+
+// latestTag returns the tag of the repository's newest release.
+func latestTag(ctx context.Context, tool config.Tool, repo string, log *logger.Logger) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", githubAPIBase(tool), repo)
+	log.Debug("[DEBUG] Resolving latest release from URL: %s\n", url)
+	var release GitHubRelease
+	err := withRetry(ctx, "Latest release lookup for "+tool.Name, log, func() error {
+		var err error
+		release, err = fetchRelease(ctx, url, tool, repo, "latest", log)
+		return err
+	})
+	return release.TagName, err
+}
+
+// matchingTag returns the tag of the highest stable release satisfying the tool's version range.
+func matchingTag(ctx context.Context, tool config.Tool, repo string, log *logger.Logger) (string, error) {
+	constraint, err := version.ParseConstraint(tool.Version)
+	if err != nil {
+		return "", err
+	}
+
+	releases, err := listReleases(ctx, tool, repo, log)
+	if err != nil {
+		return "", err
+	}
+
+	best := ""
+	for _, r := range releases {
+		if r.Draft || r.Prerelease || !constraint.Check(r.TagName) {
+			continue
+		}
+		if best == "" || version.Compare(r.TagName, best) > 0 {
+			best = r.TagName
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no release of %s satisfies %q", repo, constraint)
+	}
+	return best, nil
+}
+
+// githubRepo returns the owner/name repository of a github tool: its Repo, or else its Name.
+func githubRepo(tool config.Tool) (string, error) {
+	repo := tool.Name
+	if tool.Repo != "" {
+		repo = tool.Repo
+	}
+	return normalizeRepo(repo)
+}
+
+// maxReleasePages bounds how many pages of 100 releases listReleases fetches, so a repository
+// with an enormous release history can't exhaust the API rate limit.
+const maxReleasePages = 10
+
+// listReleases returns the repository's releases, newest first, following the API's
+// `Link: <...>; rel="next"` pagination up to maxReleasePages pages.
+func listReleases(ctx context.Context, tool config.Tool, repo string, log *logger.Logger) ([]GitHubRelease, error) {
+	var all []GitHubRelease
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", githubAPIBase(tool), repo)
+	for page := 1; url != ""; page++ {
+		if page > maxReleasePages {
+			log.Warn("[WARN] Only the first %d releases of %s were considered\n", len(all), repo)
+			break
+		}
+		log.Debug("[DEBUG] Listing releases from URL: %s\n", url)
+
+		var releases []GitHubRelease
+		var next string
+		err := withRetry(ctx, "Release listing for "+tool.Name, log, func() error {
+			var err error
+			releases = nil
+			next, err = getGitHubJSON(ctx, url, tool, &releases, fmt.Errorf("repository %s not found for %s (HTTP 404); check repo", repo, tool.Name), log)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, releases...)
+		url = next
+	}
+	return all, nil
+}
+
+// nextPageURL extracts the rel="next" target from a GitHub `Link` header, or "" on the last page.
+// The header looks like: <https://api.github.com/...&page=2>; rel="next", <...>; rel="last"
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
+// fetchRelease makes a single request for release metadata from the GitHub API.
+func fetchRelease(ctx context.Context, url string, tool config.Tool, repo, tag string, log *logger.Logger) (GitHubRelease, error) {
+	var release GitHubRelease
+	notFound := fmt.Errorf("GitHub release %s not found in %s for %s (HTTP 404); check repo and tag", tag, repo, tool.Name)
+	_, err := getGitHubJSON(ctx, url, tool, &release, notFound, log)
+	return release, err
+}
+
+// getGitHubJSON makes a single GitHub API request and decodes the JSON response into out.
+// It returns the URL of the next page for paginated listings (see nextPageURL), if any.
+// A 404 is reported as notFound. Network errors and 5xx responses are marked retryable;
+// rate limits and 404s are not, since retrying them within seconds cannot succeed.
+func getGitHubJSON(ctx context.Context, url string, tool config.Tool, out any, notFound error, log *logger.Logger) (string, error) {
+	// Make HTTP request to GitHub API, authenticated when a token is available
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := githubTokenFor(url); token != "" {
+		log.Debug("[DEBUG] Using authenticated GitHub API request\n")
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if GitHubToken != "" {
+		log.Debug("[DEBUG] Not sending the GitHub token to %s, which is not the configured GitHub host\n", url)
+	} else {
+		log.Debug("[DEBUG] Using anonymous GitHub API request (set GITHUB_TOKEN to raise the rate limit)\n")
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return "", retryable(fmt.Errorf("HTTP GET error fetching release for %s@%s: %w", tool.Name, tool.Version, err))
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			log.Warn("[WARN] Failed to close HTTP response body: %v\n", cerr)
+		}
+	}()
+
+	traceResponse(log, resp)
+
+	// Handle non-200 responses, telling rate limiting apart from a missing release
+	switch {
+	case isRateLimited(resp):
+		hint := "set GITHUB_TOKEN to authenticate"
+		if GitHubToken != "" {
+			hint = "try again after the limit resets"
+		}
+		return "", fmt.Errorf("GitHub API rate limit exceeded fetching release for %s@%s (HTTP %d); %s", tool.Name, tool.Version, resp.StatusCode, hint)
+	case resp.StatusCode == http.StatusNotFound:
+		return "", notFound
+	case isServerError(resp.StatusCode):
+		return "", retryable(fmt.Errorf("GitHub release fetch failed for %s@%s: HTTP status %d", tool.Name, tool.Version, resp.StatusCode))
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("GitHub release fetch failed for %s@%s: HTTP status %d", tool.Name, tool.Version, resp.StatusCode)
+	}
+
+	// Parse the JSON response
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub release JSON for %s@%s: %w", tool.Name, tool.Version, err)
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// matchAssetPattern selects the release asset matching the tool's AssetPattern, a glob
+// (path.Match syntax) compared case-insensitively against asset names after expanding the
+// {version}, {os}, and {arch} placeholders. The first matching asset wins.
+func matchAssetPattern(tool config.Tool, release GitHubRelease, osys, arch string, log *logger.Logger) (string, string, error) {
+	pattern := strings.NewReplacer(
+		"{version}", strings.TrimPrefix(tool.Version, "v"),
+		"{os}", osys,
+		"{arch}", arch,
+	).Replace(tool.AssetPattern)
+	log.Debug("[DEBUG] Looking for asset matching pattern %s\n", pattern)
+
+	for _, asset := range release.Assets {
+		ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(asset.Name))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid asset_pattern %q for %s: %w", tool.AssetPattern, tool.Name, err)
+		}
+		if ok {
+			log.Debug("[DEBUG] Found asset matching pattern: %s\n", asset.Name)
+			return asset.BrowserDownloadURL, asset.Name, nil
+		}
+	}
+	return "", "", fmt.Errorf("no asset matching pattern %q in release %s", pattern, release.TagName)
+}
+
+// assetPatterns returns the release asset filename patterns to look for on the given platform,
+// most specific first. Release naming is not standardized, so each platform lists the common
+// Go-style (linux_amd64) and Rust target-triple (x86_64-unknown-linux-gnu) spellings.
+func assetPatterns(goos, goarch string) []string {
+	switch goos {
+	case "darwin":
+		// Universal builds and a bare "macos" come last, after every spelling of the running arch
+		switch goarch {
+		case "amd64":
+			return []string{
+				"darwin_amd64", "darwin-amd64", "darwin_x86_64", "darwin-x86_64", "x86_64-apple-darwin",
+				"macos_amd64", "macos-amd64", "macos_x86_64", "macos-x86_64", "darwin_all", "universal-apple-darwin", "macos",
+			}
+		case "arm64":
+			return []string{
+				"darwin_arm64", "darwin-arm64", "darwin_aarch64", "darwin-aarch64", "aarch64-apple-darwin",
+				"macos_arm64", "macos-arm64", "macos_aarch64", "macos-aarch64", "darwin_all", "universal-apple-darwin", "macos",
+			}
+		}
+	case "linux":
+		switch goarch {
+		case "amd64":
+			return []string{
+				"linux_amd64", "linux-amd64", "linux_x86_64", "linux-x86_64", "x86_64-unknown-linux-gnu", "x86_64-unknown-linux-musl",
+			}
+		case "arm64":
+			return []string{
+				"linux_arm64", "linux-arm64", "linux_aarch64", "linux-aarch64", "aarch64-unknown-linux-gnu", "aarch64-unknown-linux-musl",
+			}
+		}
+	}
+	// Anything else: fall back to the plain Go-style names
+	return []string{goos + "_" + goarch, goos + "-" + goarch}
+}
+
+// normalizeRepo reduces the accepted repository spellings to the `owner/name` form used by the API.
+// Accepted forms: `owner/name`, `github.com/owner/name`, and `https://github.com/owner/name[.git]`
+// (with or without a trailing slash). Enterprise hostnames are accepted in place of github.com.
+func normalizeRepo(repo string) (string, error) {
+	r := strings.TrimSpace(repo)
+	for _, prefix := range []string{"https://", "http://", "git@"} {
+		r = strings.TrimPrefix(r, prefix)
+	}
+	r = strings.TrimPrefix(r, "www.")
+	// git@host:owner/name uses a colon before the path
+	if i := strings.Index(r, ":"); i >= 0 && !strings.Contains(r[:i], "/") {
+		r = r[:i] + "/" + r[i+1:]
+	}
+	r = strings.TrimSuffix(strings.TrimSuffix(r, "/"), ".git")
+
+	parts := strings.Split(r, "/")
+	// Drop a leading host such as github.com or a GitHub Enterprise hostname
+	if len(parts) == 3 && strings.Contains(parts[0], ".") {
+		parts = parts[1:]
+	}
+	// Owners can't contain dots, so a remaining one is a host without a repository path
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], ".") {
+		return "", fmt.Errorf("invalid GitHub repo %q: expected owner/name or https://github.com/owner/name", repo)
+	}
+	return parts[0] + "/" + parts[1], nil
+}
+
+// githubAPIBase returns the API base URL for a tool: its own APIBase if set, otherwise
+// the global GitHubAPIBase. Trailing slashes are removed so paths can be appended.
+func githubAPIBase(tool config.Tool) string {
+	base := GitHubAPIBase
+	if tool.APIBase != "" {
+		base = tool.APIBase
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// githubTokenFor returns the token to send with a request to rawURL: GitHubToken, but only
+// for the configured GitHub host, i.e. that of GitHubAPIBase (plus github.com, which serves
+// the release assets of api.github.com). A tool's own api_base may point anywhere, so
+// requests to any other host, or over another scheme, go out without the token.
+func githubTokenFor(rawURL string) string {
+	if GitHubToken == "" {
+		return ""
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(GitHubAPIBase)
+	if err != nil || !strings.EqualFold(target.Scheme, base.Scheme) {
+		return ""
+	}
+	if strings.EqualFold(target.Host, base.Host) ||
+		(strings.EqualFold(base.Host, "api.github.com") && strings.EqualFold(target.Host, "github.com")) {
+		return GitHubToken
+	}
+	return ""
+}
+
+// isRateLimited reports whether a GitHub API response was rejected by rate limiting.
+// GitHub answers 403 (or 429) and reports no remaining requests in that case; any other
+// 403 is a genuine permission problem.
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0"
 }
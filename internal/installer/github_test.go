@@ -0,0 +1,213 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+func TestNormalizeRepo(t *testing.T) {
+	tests := []struct {
+		repo    string
+		want    string
+		wantErr bool
+	}{
+		{repo: "sharkdp/bat", want: "sharkdp/bat"},
+		{repo: " sharkdp/bat ", want: "sharkdp/bat"},
+		{repo: "github.com/sharkdp/bat", want: "sharkdp/bat"},
+		{repo: "www.github.com/sharkdp/bat", want: "sharkdp/bat"},
+		{repo: "https://github.com/sharkdp/bat", want: "sharkdp/bat"},
+		{repo: "https://github.com/sharkdp/bat/", want: "sharkdp/bat"},
+		{repo: "https://github.com/sharkdp/bat.git", want: "sharkdp/bat"},
+		{repo: "http://github.com/sharkdp/bat", want: "sharkdp/bat"},
+		{repo: "git@github.com:sharkdp/bat.git", want: "sharkdp/bat"},
+		{repo: "https://ghe.example.com/tools/cli", want: "tools/cli"},
+		{repo: "bat", wantErr: true},
+		{repo: "sharkdp/", wantErr: true},
+		{repo: "https://github.com/sharkdp", wantErr: true},
+		{repo: "https://github.com/sharkdp/bat/releases", wantErr: true},
+		{repo: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeRepo(tt.repo)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeRepo(%q) = %s, want an error", tt.repo, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeRepo(%q) = %s, %v; want %s", tt.repo, got, err, tt.want)
+		}
+	}
+}
+
+// fakeGitHub serves release metadata for every repository and records the requested paths
+// and Authorization headers.
+func fakeGitHub(t *testing.T) (srv *httptest.Server, paths, auth *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	paths, auth = &[]string{}, &[]string{}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*paths = append(*paths, r.URL.Path)
+		*auth = append(*auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		fmt.Fprintf(w, `{"tag_name": "v1.2.3", "assets": [{"name": "cli_%s_%s.tar.gz", "browser_download_url": "https://downloads.example.com/cli.tar.gz"}]}`, runtime.GOOS, runtime.GOARCH)
+	}))
+	t.Cleanup(srv.Close)
+
+	base, token := GitHubAPIBase, GitHubToken
+	t.Cleanup(func() { GitHubAPIBase, GitHubToken = base, token })
+	return srv, paths, auth
+}
+
+func TestAssetPatternsPreferTheRunningArch(t *testing.T) {
+	assets := []string{
+		"tool_1.0.0_darwin_amd64.tar.gz",
+		"tool_1.0.0_darwin_arm64.tar.gz",
+		"tool-1.0.0-x86_64-apple-darwin.tar.gz",
+		"tool-1.0.0-aarch64-apple-darwin.tar.gz",
+		"tool_1.0.0_linux_amd64.tar.gz",
+		"tool_1.0.0_linux_arm64.tar.gz",
+	}
+	pick := func(goos, goarch string, assets []string) string {
+		for _, pattern := range assetPatterns(goos, goarch) {
+			for _, name := range assets {
+				if strings.Contains(strings.ToLower(name), pattern) {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+
+	tests := []struct {
+		goos, goarch string
+		assets       []string
+		want         string
+	}{
+		{"darwin", "arm64", assets, "tool_1.0.0_darwin_arm64.tar.gz"},
+		{"darwin", "amd64", assets, "tool_1.0.0_darwin_amd64.tar.gz"},
+		{"darwin", "arm64", assets[2:], "tool-1.0.0-aarch64-apple-darwin.tar.gz"},
+		{"darwin", "amd64", assets[2:], "tool-1.0.0-x86_64-apple-darwin.tar.gz"},
+		{"darwin", "arm64", []string{"tool-macOS_arm64.zip", "tool-macOS_amd64.zip"}, "tool-macOS_arm64.zip"},
+		{"darwin", "arm64", []string{"tool-macos-universal.zip", "tool-linux.zip"}, "tool-macos-universal.zip"},
+		{"linux", "arm64", assets, "tool_1.0.0_linux_arm64.tar.gz"},
+		{"linux", "amd64", assets, "tool_1.0.0_linux_amd64.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := pick(tt.goos, tt.goarch, tt.assets); got != tt.want {
+			t.Errorf("%s/%s picked %q, want %q", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubRequestsUseConfiguredAPIBase(t *testing.T) {
+	srv, paths, auth := fakeGitHub(t)
+	GitHubAPIBase = srv.URL + "/api/v3/"
+	GitHubToken = "secret"
+
+	tool, err := ResolveVersion(config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "latest"}, &logger.Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool.Version != "1.2.3" {
+		t.Errorf("resolved version = %s, want 1.2.3", tool.Version)
+	}
+	// A tool's own api_base wins over the global one
+	tool.APIBase = srv.URL + "/enterprise/api/v3"
+	if _, assetURL, _, err := resolveGitHubAsset(context.Background(), tool, &logger.Logger{}); err != nil || assetURL != "https://downloads.example.com/cli.tar.gz" {
+		t.Errorf("resolveGitHubAsset = %s, %v; want the release's asset", assetURL, err)
+	}
+
+	want := []string{"/api/v3/repos/tools/cli/releases/latest", "/enterprise/api/v3/repos/tools/cli/releases/tags/v1.2.3"}
+	if strings.Join(*paths, "|") != strings.Join(want, "|") {
+		t.Errorf("requested %q, want %q", *paths, want)
+	}
+	for _, header := range *auth {
+		if header != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the configured token", header)
+		}
+	}
+}
+
+func TestGitHubTokenFor(t *testing.T) {
+	base, token := GitHubAPIBase, GitHubToken
+	t.Cleanup(func() { GitHubAPIBase, GitHubToken = base, token })
+	GitHubToken = "secret"
+
+	tests := []struct {
+		base, url string
+		want      bool
+	}{
+		{DefaultGitHubAPIBase, "https://api.github.com/repos/tools/cli/releases/latest", true},
+		{DefaultGitHubAPIBase, "https://github.com/tools/cli/releases/download/v1.0.0/cli.tar.gz", true},
+		{DefaultGitHubAPIBase, "https://objects.githubusercontent.com/cli.tar.gz", false},
+		{DefaultGitHubAPIBase, "https://ghe.example.com/api/v3/repos/tools/cli/releases/latest", false},
+		{DefaultGitHubAPIBase, "http://api.github.com/repos/tools/cli/releases/latest", false},
+		{"https://ghe.example.com/api/v3", "https://ghe.example.com/tools/cli/releases/download/v1.0.0/cli.tar.gz", true},
+		{"https://ghe.example.com/api/v3", "https://GHE.example.com/api/v3/repos/tools/cli/releases/latest", true},
+		{"https://ghe.example.com/api/v3", "https://github.com/tools/cli/releases/download/v1.0.0/cli.tar.gz", false},
+		{"https://ghe.example.com:8443/api/v3", "https://ghe.example.com/api/v3/repos/tools/cli", false},
+		{"https://ghe.example.com/api/v3", "https://downloads.example.com/cli.tar.gz", false},
+	}
+	for _, tt := range tests {
+		GitHubAPIBase = tt.base
+		if got := githubTokenFor(tt.url) != ""; got != tt.want {
+			t.Errorf("with base %s, token sent to %s = %v, want %v", tt.base, tt.url, got, tt.want)
+		}
+	}
+
+	GitHubToken = ""
+	if got := githubTokenFor("https://api.github.com/repos/tools/cli"); got != "" {
+		t.Errorf("githubTokenFor without a token = %q", got)
+	}
+}
+
+func TestGitHubTokenOnlyGoesToTheConfiguredHost(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useBinDirs(t, filepath.Join(t.TempDir(), "bin"))
+	auth := fakeReleases(t)
+	token := GitHubToken
+	t.Cleanup(func() { GitHubToken = token })
+	GitHubToken = "secret"
+	tool := config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "1.0.0"}
+
+	// The configured host gets the token for the release metadata and the asset download alike
+	if _, err := installTool(context.Background(), tool, &logger.Logger{}); err != nil {
+		t.Fatal(err)
+	}
+	requests := auth()
+	if len(requests) != 2 {
+		t.Errorf("requests = %v, want the release and its asset", requests)
+	}
+	for path, header := range requests {
+		if header != "Bearer secret" {
+			t.Errorf("%s was requested with Authorization %q, want the token", path, header)
+		}
+	}
+
+	// A tool's own api_base on another host never sees it
+	auth = fakeReleases(t)
+	tool.APIBase = GitHubAPIBase
+	GitHubAPIBase = "https://ghe.example.com/api/v3"
+	if _, err := installTool(context.Background(), tool, &logger.Logger{}); err != nil {
+		t.Fatal(err)
+	}
+	for path, header := range auth() {
+		if header != "" {
+			t.Errorf("%s on another host was requested with Authorization %q, want none", path, header)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// RunHooks executes each command with `sh -c`, in order, logging its combined output.
+// phase is used only for log messages (e.g. "pre_sync"). It stops at the first failing
+// command and returns an error describing it.
+func RunHooks(phase string, commands []string) error {
+	return runHooks(phase, commands, &logger.Logger{}, nil)
+}
+
+// runToolHooks runs a tool's pre_install or post_install commands through the tool's logger.
+// The commands see the tool as SETUP_MACHINE_TOOL and SETUP_MACHINE_VERSION, and post_install
+// commands also see SETUP_MACHINE_INSTALL_PATH.
+func runToolHooks(phase string, tool config.Tool, installPath string, log *logger.Logger) error {
+	commands := tool.PreInstall
+	if phase == "post_install" {
+		commands = tool.PostInstall
+	}
+	env := []string{
+		"SETUP_MACHINE_TOOL=" + tool.Name,
+		"SETUP_MACHINE_VERSION=" + tool.Version,
+	}
+	if installPath != "" {
+		env = append(env, "SETUP_MACHINE_INSTALL_PATH="+installPath)
+	}
+	return runHooks(phase, commands, log, env)
+}
+
+// runHooks is RunHooks with a scoped logger and extra environment variables for the commands.
+func runHooks(phase string, commands []string, log *logger.Logger, env []string) error {
+	for _, command := range commands {
+		if DryRun {
+			log.Info("[DRY-RUN] Would run %s hook: %s\n", phase, command)
+			continue
+		}
+		log.Info("[INFO] Running %s hook: %s\n", phase, command)
+		cmd := exec.Command("sh", "-c", command)
+		if env != nil {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		log.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+		output, err := cmd.CombinedOutput()
+		if len(output) > 0 {
+			log.Info("%s", output)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", phase, command, err)
+		}
+	}
+	return nil
+}
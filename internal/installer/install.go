@@ -1,75 +1,163 @@
 package installer
 
 import (
-	"os/exec"
+	"context"
+	"fmt"
 	"path"
+	"path/filepath"
+	"runtime"
 	"setup-machine/internal/config"
 	"setup-machine/internal/logger"
 	"strings"
 )
 
-func installTool(tool config.Tool) (bool, string) {
-	logger.Debug("[DEBUG] installTool: Installing tool %s from source %s\n", tool.Name, tool.Source)
+// installResult describes a completed install.
+type installResult struct {
+	Path     string // Where the tool was installed
+	Download string // SHA256 of the downloaded artifact, for url tools
+}
+
+// installTool installs a single tool according to its source and returns where it was
+// installed. The returned error says why an install failed (download, checksum, missing
+// asset, extraction, package manager, ...); progress is logged through log, which carries
+// the tool's name as a prefix. Commands and downloads are stopped once ctx is done.
+func installTool(ctx context.Context, tool config.Tool, log *logger.Logger) (installResult, error) {
+	log.Debug("[DEBUG] installTool: Installing tool %s from source %s\n", tool.Name, tool.Source)
 
 	var installPath string
 	var err error
 
 	switch tool.Source {
 	case "github":
-		logger.Info("[INFO] Installing %s@%s from GitHub...\n", tool.Name, tool.Version)
-		installPath, err = downloadFromGitHub(tool)
+		log.Info("[INFO] Installing %s@%s from GitHub...\n", tool.Name, tool.Version)
+		installPath, err = downloadFromGitHub(ctx, tool, log)
 		if err != nil {
-			logger.Error("[ERROR] Failed to install %s from GitHub: %v\n", tool.Name, err)
-			return false, ""
+			return installResult{}, fmt.Errorf("install from GitHub: %w", err)
 		}
 
 	case "url":
-		logger.Info("[INFO] Installing %s from custom URL...\n", tool.Name)
-		tmp := "/tmp/" + path.Base(tool.URL)
-
-		// Download the file using curl
-		curlCmd := exec.Command("curl", "-L", tool.URL, "-o", tmp)
-		logger.Debug("[DEBUG] Running command: %s\n", strings.Join(curlCmd.Args, " "))
-		output, err := curlCmd.CombinedOutput()
+		log.Info("[INFO] Installing %s from custom URL...\n", tool.Name)
+		tmp, remove, err := downloadPath(tool.URL)
 		if err != nil {
-			logger.Error("[ERROR] Download failed for %s: %v\nOutput: %s\n", tool.Name, err, output)
-			return false, ""
+			return installResult{}, err
+		}
+		defer remove()
+
+		// A custom URL has no release to auto-detect from; only an explicit checksum is verified
+		expected := ""
+		if tool.Checksum != "" && strings.ToLower(tool.Checksum) != checksumSkip {
+			expected = normalizeChecksum(tool.Checksum)
+		}
+
+		// Download the file, or reuse a cached copy when its checksum is known
+		if err := cachedDownload(ctx, tool.URL, tmp, expected, log); err != nil {
+			return installResult{}, fmt.Errorf("download %s: %w", tool.URL, err)
+		}
+
+		// Record what was downloaded, so a changed artifact can be told apart from the installed one
+		download := expected
+		if download == "" {
+			if download, err = fileSHA256(tmp); err != nil {
+				return installResult{}, fmt.Errorf("checksum %s: %w", tmp, err)
+			}
+		}
+
+		// Artifacts that need a launcher are placed as-is and wrapped by a generated script
+		if tool.Launcher != "" {
+			installPath, err = installWithLauncher(tool, tmp, log)
+			if err != nil {
+				return installResult{}, fmt.Errorf("launcher installation: %w", err)
+			}
+			return installResult{Path: installPath, Download: download}, nil
+		}
+
+		// Disk images carry an app bundle that is copied into /Applications
+		if strings.HasSuffix(tool.URL, ".dmg") {
+			log.Info("[INFO] Detected .dmg file for %s. Installing the app it contains...\n", tool.Name)
+			installPath, err = installFromDMG(ctx, tmp, log)
+			if err != nil {
+				return installResult{}, err
+			}
+			return installResult{Path: installPath, Download: download}, nil
 		}
 
 		// If it's a .pkg file, install it using the macOS installer
 		if strings.HasSuffix(tool.URL, ".pkg") {
-			logger.Info("[INFO] Detected .pkg file for %s. Installing via macOS installer...\n", tool.Name)
-			installCmd := exec.Command("sudo", "installer", "-pkg", tmp, "-target", "/")
-			logger.Debug("[DEBUG] Running command: %s\n", strings.Join(installCmd.Args, " "))
-			output, err = installCmd.CombinedOutput()
+			log.Info("[INFO] Detected .pkg file for %s. Installing via macOS installer...\n", tool.Name)
+			installCmd := commandContext(ctx, "sudo", "installer", "-pkg", tmp, "-target", "/")
+			log.Trace("[TRACE] Running command: %s\n", strings.Join(installCmd.Args, " "))
+			output, err := installCmd.CombinedOutput()
 			if err != nil {
-				logger.Error("[ERROR] .pkg installation failed for %s: %v\nOutput: %s\n", tool.Name, err, output)
-				return false, ""
+				return installResult{}, fmt.Errorf(".pkg installation failed: %v\nOutput: %s", err, output)
 			}
-			return true, "/Applications" // general location for GUI apps (may vary by .pkg)
+			// General location for GUI apps (may vary by .pkg)
+			return installResult{Path: "/Applications", Download: download}, nil
 
 		} else {
 			// Otherwise, treat as archive
-			asset, err := ExtractAndInstall(tmp, "/tmp/")
+			asset, err := ExtractAndInstall(tmp, filepath.Dir(tmp), tool, log)
 			if err != nil {
-				return false, ""
+				return installResult{}, fmt.Errorf("extract %s: %w", path.Base(tmp), err)
 			}
-			logger.Debug("[DEBUG] Extracted asset to %s\n", asset)
+			log.Debug("[DEBUG] Extracted asset to %s\n", asset)
 
-			chmodCmd := exec.Command("chmod", "+x", asset)
-			logger.Debug("[DEBUG] Running command: %s\n", strings.Join(chmodCmd.Args, " "))
-			output, err = chmodCmd.CombinedOutput()
+			chmodCmd := commandContext(ctx, "chmod", "+x", asset)
+			log.Trace("[TRACE] Running command: %s\n", strings.Join(chmodCmd.Args, " "))
+			output, err := chmodCmd.CombinedOutput()
 			if err != nil {
-				logger.Error("[ERROR] chmod failed for %s: %v\nOutput: %s\n", tool.Name, err, output)
-				return false, ""
+				return installResult{}, fmt.Errorf("chmod %s failed: %v\nOutput: %s", asset, err, output)
 			}
-			installPath = asset
+			return installResult{Path: asset, Download: download}, nil
+		}
+
+	case "brew":
+		log.Info("[INFO] Installing %s via Homebrew...\n", tool.Name)
+		installPath, err = installFromBrew(ctx, tool)
+		if err != nil {
+			return installResult{}, err
+		}
+
+	case "npm":
+		log.Info("[INFO] Installing %s via npm...\n", tool.Name)
+		installPath, err = installFromNpm(ctx, tool)
+		if err != nil {
+			return installResult{}, err
+		}
+
+	case "pipx":
+		log.Info("[INFO] Installing %s via pipx...\n", tool.Name)
+		installPath, err = installFromPipx(ctx, tool)
+		if err != nil {
+			return installResult{}, err
+		}
+
+	case "pip":
+		log.Info("[INFO] Installing %s via pip...\n", tool.Name)
+		installPath, err = installFromPip(ctx, tool)
+		if err != nil {
+			return installResult{}, err
+		}
+
+	case "apt", "dnf":
+		if runtime.GOOS != "linux" {
+			return installResult{}, fmt.Errorf("the %s source is only supported on Linux (this is %s)", tool.Source, runtime.GOOS)
+		}
+		log.Info("[INFO] Installing %s via %s...\n", tool.Name, tool.Source)
+		installPath, err = installFromSystemPackage(ctx, tool, log)
+		if err != nil {
+			return installResult{}, err
+		}
+
+	case "script":
+		log.Info("[INFO] Installing %s with its install script...\n", tool.Name)
+		installPath, err = installFromScript(ctx, tool, log)
+		if err != nil {
+			return installResult{}, err
 		}
 
 	default:
-		logger.Warn("[WARN] Unknown tool source for %s. Skipping.\n", tool.Name)
-		return false, ""
+		return installResult{}, fmt.Errorf("unknown tool source %q", tool.Source)
 	}
 
-	return true, installPath
+	return installResult{Path: installPath}, nil
 }
@@ -0,0 +1,100 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+func TestInstallToolReportsWhyItFailed(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useBinDirs(t, filepath.Join(t.TempDir(), "bin"))
+	fastRetries(t)
+	fakeRelease(t, func(actual string) string { return actual })
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	brew := runBrew
+	t.Cleanup(func() { runBrew = brew })
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte("Error: No available formula with the name \"nope\"."), errors.New("exit status 1")
+	}
+
+	tests := []struct {
+		name string
+		tool config.Tool
+		want []string
+	}{
+		{
+			name: "release not found",
+			tool: config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "9.9.9"},
+			want: []string{"install from GitHub", "GitHub release v9.9.9 not found in tools/cli", "HTTP 404"},
+		},
+		{
+			name: "no matching asset",
+			tool: config.Tool{Name: "cli", Source: "github", Repo: "tools/cli", Version: "1.0.0", AssetPattern: "*.msi"},
+			want: []string{"install from GitHub", `no asset matching pattern "*.msi" in release v1.0.0`},
+		},
+		{
+			name: "network failure",
+			tool: config.Tool{Name: "cli", Source: "url", URL: closed.URL + "/cli.tar.gz"},
+			want: []string{"download " + closed.URL + "/cli.tar.gz", "connection refused"},
+		},
+		{
+			name: "package manager failure",
+			tool: config.Tool{Name: "nope", Source: "brew"},
+			want: []string{"brew install nope failed: exit status 1", "No available formula"},
+		},
+		{
+			name: "untrusted script",
+			tool: config.Tool{Name: "rustup", Source: "script", URL: closed.URL + "/install.sh"},
+			want: []string{"set trusted: true on rustup"},
+		},
+		{
+			name: "unknown source",
+			tool: config.Tool{Name: "cli", Source: "cargo"},
+			want: []string{`unknown tool source "cargo"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := installTool(context.Background(), tt.tool, &logger.Logger{})
+			if err == nil {
+				t.Fatalf("installTool succeeded with %+v, want an error", result)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("installTool error = %q, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncToolsDoesNotRecordFailedInstalls(t *testing.T) {
+	brew := runBrew
+	t.Cleanup(func() { runBrew = brew })
+	runBrew = func(ctx context.Context, args ...string) ([]byte, error) {
+		return nil, errors.New("exit status 1")
+	}
+	installed := state.ToolState{Version: "1.6", InstallPath: "/opt/homebrew/bin/jq", InstalledByDevSetup: true, Source: "brew"}
+	st := &state.State{Tools: map[string]state.ToolState{"jq": installed}}
+
+	SyncTools([]config.Tool{{Name: "jq", Source: "brew", Version: "1.7"}, {Name: "fd", Source: "brew", Version: "9.0"}}, st)
+
+	if got := st.Tools["jq"]; got.Version != "1.6" {
+		t.Errorf("jq state = %+v after a failed upgrade, want the installed 1.6 kept", got)
+	}
+	if _, ok := st.Tools["fd"]; ok {
+		t.Error("fd was recorded although its install failed")
+	}
+}
@@ -0,0 +1,74 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"sort"
+	"strings"
+)
+
+// installFromScript downloads a vendor install script (the `curl ... | sh` kind published by
+// rustup, nvm, starship, ...) and runs it with sh, passing tool.Args and tool.Env. The script
+// runs arbitrary code as the current user, so the tool must be marked trusted; a pinned
+// checksum makes sure it is the script that was reviewed. It returns the path of the
+// executable named after the tool as found on PATH afterwards, if any.
+func installFromScript(ctx context.Context, tool config.Tool, log *logger.Logger) (string, error) {
+	if !tool.Trusted {
+		return "", fmt.Errorf("script installs run arbitrary code; set trusted: true on %s to allow it", tool.Name)
+	}
+
+	expected := ""
+	if tool.Checksum != "" && strings.ToLower(tool.Checksum) != checksumSkip {
+		expected = normalizeChecksum(tool.Checksum)
+	}
+	log.Warn("[WARN] Running the install script %s for %s with your user's privileges\n", tool.URL, tool.Name)
+	if expected == "" {
+		log.Warn("[WARN] The install script for %s is not pinned with a checksum; whatever the URL serves today will run\n", tool.Name)
+	}
+
+	script, err := os.CreateTemp("", "setup-machine-"+filepath.Base(tool.Name)+"-*.sh")
+	if err != nil {
+		return "", err
+	}
+	script.Close()
+	defer os.Remove(script.Name())
+
+	if err := cachedDownload(ctx, tool.URL, script.Name(), expected, log); err != nil {
+		return "", fmt.Errorf("download install script: %w", err)
+	}
+
+	cmd := commandContext(ctx, "sh", append([]string{script.Name()}, tool.Args...)...)
+	cmd.Env = append(os.Environ(), scriptEnv(tool)...)
+	log.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		log.Info("%s", output)
+	}
+	if err != nil {
+		return "", fmt.Errorf("install script failed: %w", err)
+	}
+
+	// Where the script put the tool is up to the script; the equivalent of `command -v <name>`
+	installPath, err := exec.LookPath(filepath.Base(tool.Name))
+	if err != nil {
+		log.Warn("[WARN] The install script for %s finished, but no %s executable is on PATH; its install path is not tracked\n", tool.Name, filepath.Base(tool.Name))
+		return "", nil
+	}
+	return installPath, nil
+}
+
+// scriptEnv returns the configured environment of an install script as KEY=value pairs,
+// sorted so the command is the same on every run.
+func scriptEnv(tool config.Tool) []string {
+	env := make([]string, 0, len(tool.Env))
+	for k, v := range tool.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// toolDataDir returns the directory where non-executable artifacts for a tool (such as a
+// jar used through a launcher) are kept: ~/.local/share/setup-machine/tools/<name>.
+func toolDataDir(name string) string {
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", "setup-machine", "tools", name)
+}
+
+// installWithLauncher places a downloaded artifact into the tool's data directory and
+// writes a generated wrapper script named after the tool into the bin directory.
+// The tool's Launcher template may reference {{install_dir}} and {{asset}}, e.g.
+//
+//	launcher: exec java -jar {{asset}} "$@"
+//
+// It returns the path of the launcher, which is what ends up on PATH.
+func installWithLauncher(tool config.Tool, downloaded string, log *logger.Logger) (string, error) {
+	installDir := toolDataDir(tool.Name)
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create install directory %s: %w", installDir, err)
+	}
+
+	// Move the artifact into place; copy if rename crosses filesystems
+	asset := filepath.Join(installDir, filepath.Base(downloaded))
+	if err := os.Rename(downloaded, asset); err != nil {
+		if err := copyBinary(downloaded, installDir); err != nil {
+			return "", fmt.Errorf("cannot place artifact in %s: %w", installDir, err)
+		}
+	}
+	log.Debug("[DEBUG] Placed artifact at %s\n", asset)
+
+	script := renderLauncher(tool, installDir, asset)
+
+	// Write the launcher to a temp file and install it like any other binary
+	tmpDir, err := os.MkdirTemp("", "setup-machine-launcher-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpLauncher := filepath.Join(tmpDir, tool.Name)
+	if err := os.WriteFile(tmpLauncher, []byte(script), 0755); err != nil {
+		return "", fmt.Errorf("cannot write launcher: %w", err)
+	}
+
+	destination, err := installToBinDir([]string{tmpLauncher}, log)
+	if err != nil {
+		return "", fmt.Errorf("failed to install launcher: %w", err)
+	}
+
+	launcherPath := filepath.Join(destination, tool.Name)
+	log.Info("[INFO] Installed launcher %s for %s\n", launcherPath, asset)
+	return launcherPath, nil
+}
+
+// renderLauncher expands the launcher template into a complete POSIX shell script.
+func renderLauncher(tool config.Tool, installDir, asset string) string {
+	body := strings.NewReplacer(
+		"{{install_dir}}", installDir,
+		"{{asset}}", asset,
+	).Replace(tool.Launcher)
+
+	return fmt.Sprintf("#!/bin/sh\n# Launcher for %s generated by setup-machine. Do not edit.\n%s\n", tool.Name, strings.TrimSpace(body))
+}
@@ -0,0 +1,69 @@
+package installer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+)
+
+// useBinDirs points BinDirs at dirs for the duration of a test.
+func useBinDirs(t *testing.T, dirs ...string) {
+	t.Helper()
+	orig := BinDirs
+	BinDirs = dirs
+	t.Cleanup(func() { BinDirs = orig })
+}
+
+func TestInstallWithLauncher(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	bin := filepath.Join(t.TempDir(), "bin")
+	useBinDirs(t, bin)
+
+	downloaded := filepath.Join(t.TempDir(), "tool.jar")
+	if err := os.WriteFile(downloaded, []byte("jar contents\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := config.Tool{Name: "tool", Source: "url", Launcher: `cd {{install_dir}} && exec cat {{asset}} "$@"`}
+
+	launcher, err := installWithLauncher(tool, downloaded, &logger.Logger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(bin, "tool"); launcher != want {
+		t.Errorf("launcher = %s, want %s", launcher, want)
+	}
+	asset := filepath.Join(toolDataDir("tool"), "tool.jar")
+	if _, err := os.Stat(asset); err != nil {
+		t.Errorf("artifact was not placed in the data directory: %v", err)
+	}
+
+	script, err := os.ReadFile(launcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(script), "#!/bin/sh\n") || !strings.Contains(string(script), "exec cat "+asset) {
+		t.Errorf("launcher script = %q, want a sh script running the placed artifact", script)
+	}
+	output, err := exec.Command(launcher).CombinedOutput()
+	if err != nil || string(output) != "jar contents\n" {
+		t.Errorf("running the launcher = %q, %v; want the artifact's contents", output, err)
+	}
+
+	// Uninstalling removes both the launcher and the artifact directory
+	ts := state.ToolState{InstallPath: launcher, InstalledByDevSetup: true, Source: "url", ArtifactDir: toolDataDir("tool")}
+	if !uninstallTool("tool", ts) {
+		t.Fatal("uninstallTool failed")
+	}
+	for _, path := range []string{launcher, toolDataDir("tool")} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s is left after uninstalling", path)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// maxArchiveNesting is how many layers of archive-within-archive are unpacked.
+// Real releases rarely wrap more than twice; the cap also stops self-referencing archives.
+const maxArchiveNesting = 2
+
+// maxExtractedBytes caps the total size of an extraction tree, as a guard against archive bombs.
+const maxExtractedBytes = 2 << 30
+
+// archiveExtensions lists the archive formats ExtractArchive can unpack.
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar.bz2", ".tar.xz", ".tar", ".zip", ".7z"}
+
+// isSupportedArchive reports whether name has an extension ExtractArchive understands.
+func isSupportedArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandNestedArchives handles release artifacts that wrap the real archive in another one
+// (e.g. a .zip inside a .tar.gz). When path is itself an archive, or is a directory that holds
+// archives but no executables, those inner archives are extracted in place and removed, up to
+// maxArchiveNesting levels deep. It returns the path that should be searched for binaries.
+//
+// All layers together may not expand to more than maxExtractedBytes: each inner archive is
+// extracted with whatever is left of that limit after what is already on disk.
+func expandNestedArchives(path string, log *logger.Logger) (string, error) {
+	root := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		root = filepath.Dir(path)
+	}
+	for depth := 1; depth <= maxArchiveNesting; depth++ {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		var inner []string
+		if !info.IsDir() {
+			if !isSupportedArchive(path) {
+				return path, nil
+			}
+			inner = []string{path}
+		} else {
+			if hasExecutable(path) {
+				return path, nil
+			}
+			inner = findArchives(path)
+		}
+		if len(inner) == 0 {
+			return path, nil
+		}
+
+		for _, archive := range inner {
+			out := archive + ".extracted"
+			log.Debug("[DEBUG] Extracting nested archive (level %d): %s -> %s\n", depth, archive, out)
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return "", err
+			}
+			extracted, err := extractArchive(archive, out, maxExtractedBytes-treeSize(root))
+			if err != nil {
+				return "", fmt.Errorf("failed to extract nested archive %s: %w", filepath.Base(archive), err)
+			}
+			_ = os.Remove(archive)
+			if !info.IsDir() {
+				path = extracted
+			}
+		}
+
+	}
+	return path, nil
+}
+
+// findArchives returns every supported archive file under root.
+func findArchives(root string) []string {
+	var archives []string
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && isSupportedArchive(d.Name()) {
+			archives = append(archives, p)
+		}
+		return nil
+	})
+	return archives
+}
+
+// hasExecutable reports whether any regular file under root has an executable permission bit.
+func hasExecutable(root string) bool {
+	found := false
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || found {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// treeSize returns the total size in bytes of all regular files under root.
+func treeSize(root string) int64 {
+	var total int64
+	_ = filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
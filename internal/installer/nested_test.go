@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/logger"
+)
+
+func TestExtractArchiveStopsAtLimit(t *testing.T) {
+	big := strings.Repeat("x", 4096)
+	tests := map[string]string{
+		"tar": writeTar(t, []tarEntry{
+			{name: "small", typeflag: tar.TypeReg, body: "ok"},
+			{name: "bomb", typeflag: tar.TypeReg, body: big},
+		}),
+		"zip": writeZipFiles(t, map[string]string{"bomb": big}),
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			dest, _ := extractDest(t)
+			if _, err := extractArchive(src, dest, 1000); err == nil {
+				t.Fatal("extractArchive succeeded past its limit")
+			}
+			// The copy stops one byte past the limit instead of writing the whole entry
+			if size := treeSize(dest); size > 1001 {
+				t.Errorf("extracted %d bytes with a 1000 byte limit", size)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveWithinLimit(t *testing.T) {
+	src := writeTar(t, []tarEntry{{name: "exact", typeflag: tar.TypeReg, body: strings.Repeat("x", 1000)}})
+	dest, _ := extractDest(t)
+	if _, err := extractArchive(src, dest, 1000); err != nil {
+		t.Errorf("extractArchive of exactly the limit: %v", err)
+	}
+}
+
+func TestExpandNestedArchives(t *testing.T) {
+	dir := t.TempDir()
+	inner := writeZipFiles(t, map[string]string{"tool/tool": "#!/bin/sh\n"})
+	if err := os.Rename(inner, filepath.Join(dir, "tool.zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := expandNestedArchives(dir, &logger.Logger{})
+	if err != nil {
+		t.Fatalf("expandNestedArchives: %v", err)
+	}
+	binary := filepath.Join(path, "tool.zip.extracted", "tool", "tool")
+	info, err := os.Stat(binary)
+	if err != nil {
+		t.Fatalf("inner binary not extracted: %v", err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("inner binary mode = %v, want it executable", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tool.zip")); err == nil {
+		t.Error("inner archive was not removed after extracting it")
+	}
+}
+
+// writeZipFiles writes executable files (name to body) to a .zip file in a temp directory and
+// returns its path.
+func writeZipFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "files.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, body := range files {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(0755)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,53 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// runNpm executes npm with the given arguments and returns its combined output.
+// Replaced in tests, which check the npm arguments without npm installed.
+var runNpm = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "npm", args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// installFromNpm installs a package globally with `npm install -g`, pinned to tool.Version
+// when set, and returns the path of the executable npm linked into the global prefix.
+func installFromNpm(ctx context.Context, tool config.Tool) (string, error) {
+	args := npmInstallArgs(tool)
+	output, err := runNpm(ctx, args...)
+	if err != nil {
+		return "", fmt.Errorf("npm %s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+
+	prefix, err := runNpm(ctx, "prefix", "-g")
+	if err != nil {
+		return "", fmt.Errorf("cannot determine npm global prefix: %v\nOutput: %s", err, prefix)
+	}
+	// Scoped packages (@scope/name) link their executable as name
+	return filepath.Join(strings.TrimSpace(string(prefix)), "bin", filepath.Base(tool.Name)), nil
+}
+
+// npmInstallArgs returns the npm arguments that install a tool, pinned when a version is set.
+func npmInstallArgs(tool config.Tool) []string {
+	pkg := tool.Name
+	if tool.Version != "" {
+		pkg += "@" + tool.Version
+	}
+	return []string{"install", "-g", pkg}
+}
+
+// uninstallFromNpm removes a package previously installed with the npm source.
+func uninstallFromNpm(name string) error {
+	output, err := runNpm(context.Background(), "uninstall", "-g", name)
+	if err != nil {
+		return fmt.Errorf("npm uninstall -g %s failed: %v\nOutput: %s", name, err, output)
+	}
+	return nil
+}
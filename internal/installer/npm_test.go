@@ -0,0 +1,45 @@
+package installer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+)
+
+func TestInstallFromNpm(t *testing.T) {
+	tests := []struct {
+		tool     config.Tool
+		wantArgs string
+		wantPath string
+	}{
+		{config.Tool{Name: "prettier", Version: "3.3.3"}, "install -g prettier@3.3.3", "/usr/local/bin/prettier"},
+		{config.Tool{Name: "prettier"}, "install -g prettier", "/usr/local/bin/prettier"},
+		{config.Tool{Name: "@scope/pkg", Version: "1.2.0"}, "install -g @scope/pkg@1.2.0", "/usr/local/bin/pkg"},
+	}
+
+	orig := runNpm
+	t.Cleanup(func() { runNpm = orig })
+	for _, tt := range tests {
+		var calls []string
+		runNpm = func(ctx context.Context, args ...string) ([]byte, error) {
+			calls = append(calls, strings.Join(args, " "))
+			if args[0] == "prefix" {
+				return []byte("/usr/local\n"), nil
+			}
+			return nil, nil
+		}
+
+		path, err := installFromNpm(context.Background(), tt.tool)
+		if err != nil {
+			t.Fatalf("installFromNpm(%s): %v", tt.tool.Name, err)
+		}
+		if len(calls) != 2 || calls[0] != tt.wantArgs || calls[1] != "prefix -g" {
+			t.Errorf("npm calls for %s@%s = %q, want [%q \"prefix -g\"]", tt.tool.Name, tt.tool.Version, calls, tt.wantArgs)
+		}
+		if path != tt.wantPath {
+			t.Errorf("installFromNpm(%s) = %s, want %s", tt.tool.Name, path, tt.wantPath)
+		}
+	}
+}
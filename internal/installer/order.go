@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"fmt"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// orderSettings returns the settings in the order they must be applied.
+// Config order is preserved, except that a setting declaring `after: domain:key` is moved
+// behind the setting it depends on. Dependencies on keys that aren't in the config are
+// treated as already satisfied. A dependency cycle is reported as an error.
+func orderSettings(settings []config.Setting) ([]config.Setting, error) {
+	declared := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		declared[settingKey(s)] = true
+	}
+	for _, s := range settings {
+		if s.After != "" && !declared[s.After] {
+			logger.Warn("[WARN] Setting %s depends on %s, which is not in the config; ignoring dependency\n", settingKey(s), s.After)
+		}
+	}
+
+	ordered := make([]config.Setting, 0, len(settings))
+	applied := make(map[string]bool, len(settings))
+	remaining := settings
+
+	// Each pass emits, in config order, every setting whose dependency has already been emitted.
+	// A pass that makes no progress means the remaining settings depend on each other.
+	for len(remaining) > 0 {
+		var deferred []config.Setting
+		for _, s := range remaining {
+			if s.After == "" || !declared[s.After] || applied[s.After] {
+				ordered = append(ordered, s)
+				applied[settingKey(s)] = true
+			} else {
+				deferred = append(deferred, s)
+			}
+		}
+		if len(deferred) == len(remaining) {
+			var cycle []string
+			for _, s := range deferred {
+				cycle = append(cycle, settingKey(s)+" -> "+s.After)
+			}
+			return nil, fmt.Errorf("settings dependency cycle: %s", strings.Join(cycle, ", "))
+		}
+		remaining = deferred
+	}
+	return ordered, nil
+}
+
+// settingKey returns the unique identifier of a setting, as used in state (see Setting.ID).
+func settingKey(s config.Setting) string {
+	return s.ID()
+}
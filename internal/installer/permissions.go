@@ -0,0 +1,169 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// CheckSettingsWritable verifies that the preference files backing every configured
+// setting can be written. It returns one error per preference file that would fail, so all
+// problems can be reported before any `defaults write` runs.
+//   - currentHost settings live in ~/Library/Preferences/ByHost, under a name carrying the
+//     hardware UUID, so the ByHost directory is probed instead of the file.
+//   - sudo settings are written by root to /Library/Preferences; the current user can't be
+//     expected to write there, so only that the directory exists is checked.
+func CheckSettingsWritable(settings []config.Setting) []error {
+	var problems []error
+	seen := map[string]bool{}
+
+	for _, s := range settings {
+		plist := settingPlistPath(s)
+		if seen[plist] {
+			continue
+		}
+		seen[plist] = true
+
+		logger.Debug("[DEBUG] Checking write access for %s (%s)\n", s.ID(), plist)
+		var err error
+		if s.Sudo && !filepath.IsAbs(s.Domain) {
+			_, err = os.Stat(filepath.Dir(plist))
+		} else {
+			err = checkWritable(plist)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("settings domain %s is not writable: %w", settingDomain(s), err))
+		}
+	}
+	return problems
+}
+
+// CheckAliasesWritable verifies that the shell rc file used for aliases can be appended to,
+// or created if it does not exist yet.
+func CheckAliasesWritable(aliases config.Aliases) []error {
+	rcPath, err := rcFilePath(aliases)
+	if err != nil {
+		return []error{fmt.Errorf("cannot resolve shell rc file: %w", err)}
+	}
+
+	logger.Debug("[DEBUG] Checking write access for rc file %s\n", rcPath)
+	if err := checkWritable(rcPath); err != nil {
+		return []error{fmt.Errorf("shell rc file %s is not writable: %w", rcPath, err)}
+	}
+	return nil
+}
+
+// settingPlistPath maps a setting to the preference plist that stores it: the domain as
+// addressed by settingDomain (so sudo settings resolve under /Library/Preferences), in
+// ~/Library/Preferences, or its ByHost directory for currentHost settings. NSGlobalDomain
+// lives in .GlobalPreferences.plist; absolute paths are used as-is.
+func settingPlistPath(s config.Setting) string {
+	domain := settingDomain(s)
+	if filepath.IsAbs(domain) {
+		if strings.HasSuffix(domain, ".plist") {
+			return domain
+		}
+		return domain + ".plist"
+	}
+	if domain == "NSGlobalDomain" || domain == "-g" || domain == "-globalDomain" {
+		domain = ".GlobalPreferences"
+	}
+	dir := filepath.Join(os.Getenv("HOME"), "Library", "Preferences")
+	if s.CurrentHost {
+		// The real file is <domain>.<hardware UUID>.plist; this name never exists, so
+		// checkWritable probes the ByHost directory (or its nearest existing ancestor)
+		dir = filepath.Join(dir, "ByHost")
+	}
+	return filepath.Join(dir, domain+".plist")
+}
+
+// checkWritable reports whether path can be written. Existing files are opened for append
+// without modifying them; for missing files the nearest existing ancestor directory must allow
+// creating entries, since missing directories are created on write.
+func checkWritable(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	// File does not exist yet: probe the nearest existing ancestor with a throwaway file
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	probe, err := os.CreateTemp(dir, ".setup-machine-probe-*")
+	if err != nil {
+		return err
+	}
+	name := probe.Name()
+	_ = probe.Close()
+	return os.Remove(name)
+}
+
+// CheckSettingsDomains validates every setting's domain against the domains that currently
+// exist on the system (as listed by `defaults domains`, or `defaults -currentHost domains`
+// for currentHost settings). Unknown domains are usually typos. In normal mode they are only
+// warned about; in strict mode the offending settings are dropped. Sudo settings are never
+// checked: system-wide domains in /Library/Preferences aren't listed by either command.
+// It returns the settings that should be applied.
+func CheckSettingsDomains(settings []config.Setting, strict bool) []config.Setting {
+	domains := map[bool]map[string]bool{} // Known domains, keyed by currentHost
+	var allowed []config.Setting
+	for _, s := range settings {
+		if s.Sudo || filepath.IsAbs(s.Domain) {
+			allowed = append(allowed, s)
+			continue
+		}
+
+		known, listed := domains[s.CurrentHost]
+		if !listed {
+			var err error
+			if known, err = knownDomains(s.CurrentHost); err != nil {
+				logger.Warn("[WARN] Unable to list defaults domains, skipping domain validation: %v\n", err)
+			}
+			domains[s.CurrentHost] = known
+		}
+		if known == nil || known[s.Domain] {
+			allowed = append(allowed, s)
+			continue
+		}
+		if strict {
+			logger.Error("[ERROR] Refusing to apply %s: domain %s does not exist (--strict-settings)\n", s.ID(), s.Domain)
+			continue
+		}
+		logger.Warn("[WARN] Settings domain %s does not exist yet; check %s for typos\n", s.Domain, s.ID())
+		allowed = append(allowed, s)
+	}
+	return allowed
+}
+
+// knownDomains returns the set of preference domains reported by `defaults domains` (the
+// per-host ones with currentHost), plus the global domain aliases which are never listed
+// but always exist.
+func knownDomains(currentHost bool) (map[string]bool, error) {
+	args := []string{"domains"}
+	if currentHost {
+		args = append([]string{"-currentHost"}, args...)
+	}
+	output, err := runDefaults(false, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{"NSGlobalDomain": true, "-g": true, "-globalDomain": true}
+	for _, domain := range strings.Split(string(output), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			known[domain] = true
+		}
+	}
+	return known, nil
+}
@@ -0,0 +1,156 @@
+package installer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+)
+
+// fakeDefaults replaces runDefaults for the duration of a test. run receives the sudo flag
+// and arguments of each call.
+func fakeDefaults(t *testing.T, run func(sudo bool, args ...string) ([]byte, error)) {
+	t.Helper()
+	orig := runDefaults
+	runDefaults = run
+	t.Cleanup(func() { runDefaults = orig })
+}
+
+func TestSettingPlistPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	prefs := filepath.Join(home, "Library", "Preferences")
+
+	tests := []struct {
+		setting config.Setting
+		want    string
+	}{
+		{config.Setting{Domain: "com.apple.dock"}, filepath.Join(prefs, "com.apple.dock.plist")},
+		{config.Setting{Domain: "NSGlobalDomain"}, filepath.Join(prefs, ".GlobalPreferences.plist")},
+		{config.Setting{Domain: "com.apple.screensaver", CurrentHost: true}, filepath.Join(prefs, "ByHost", "com.apple.screensaver.plist")},
+		{config.Setting{Domain: "com.apple.loginwindow", Sudo: true}, "/Library/Preferences/com.apple.loginwindow.plist"},
+		{config.Setting{Domain: "NSGlobalDomain", Sudo: true}, "/Library/Preferences/.GlobalPreferences.plist"},
+		{config.Setting{Domain: "/tmp/custom"}, "/tmp/custom.plist"},
+	}
+	for _, tt := range tests {
+		if got := settingPlistPath(tt.setting); got != tt.want {
+			t.Errorf("settingPlistPath(%s) = %s, want %s", tt.setting.ID(), got, tt.want)
+		}
+	}
+}
+
+func TestCheckSettingsWritableProbesByHost(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	byHost := filepath.Join(home, "Library", "Preferences", "ByHost")
+	if err := os.MkdirAll(byHost, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(byHost, 0755) })
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	problems := CheckSettingsWritable([]config.Setting{
+		{Domain: "com.apple.dock", Key: "autohide"},
+		{Domain: "com.apple.screensaver", Key: "idleTime", CurrentHost: true},
+	})
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "com.apple.screensaver") {
+		t.Errorf("CheckSettingsWritable() = %v, want one problem for the currentHost setting", problems)
+	}
+}
+
+func TestCheckSettingsDomainsStrict(t *testing.T) {
+	var calls []string
+	fakeDefaults(t, func(sudo bool, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if args[0] == "-currentHost" {
+			return []byte("com.apple.screensaver\n"), nil
+		}
+		return []byte("com.apple.dock, com.apple.finder\n"), nil
+	})
+
+	settings := []config.Setting{
+		{Domain: "com.apple.dock", Key: "autohide"},
+		{Domain: "com.apple.dokc", Key: "tilesize"},
+		{Domain: "com.apple.screensaver", Key: "idleTime", CurrentHost: true},
+		{Domain: "com.apple.loginwindow", Key: "GuestEnabled", Sudo: true},
+		{Domain: "NSGlobalDomain", Key: "AppleShowAllExtensions"},
+	}
+	allowed := CheckSettingsDomains(settings, true)
+
+	var got []string
+	for _, s := range allowed {
+		got = append(got, s.ID())
+	}
+	want := "com.apple.dock:autohide com.apple.screensaver:idleTime@currentHost com.apple.loginwindow:GuestEnabled@sudo NSGlobalDomain:AppleShowAllExtensions"
+	if strings.Join(got, " ") != want {
+		t.Errorf("CheckSettingsDomains() kept %v, want %s", got, want)
+	}
+	if strings.Join(calls, "; ") != "domains; -currentHost domains" {
+		t.Errorf("defaults calls = %v, want one domains listing per kind", calls)
+	}
+}
+
+func TestCheckSettingsDomainsWithoutListing(t *testing.T) {
+	fakeDefaults(t, func(sudo bool, args ...string) ([]byte, error) {
+		return nil, errors.New("defaults: not found")
+	})
+
+	settings := []config.Setting{{Domain: "com.apple.dokc", Key: "tilesize"}}
+	if allowed := CheckSettingsDomains(settings, true); len(allowed) != 1 {
+		t.Errorf("CheckSettingsDomains() = %v, want settings kept when domains can't be listed", allowed)
+	}
+}
+
+func TestCheckAliasesWritableAllowsMissingRCFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// fish's rc file lives in a directory that doesn't exist yet; it is created on write
+	if problems := CheckAliasesWritable(config.Aliases{Shell: "fish"}); len(problems) != 0 {
+		t.Errorf("CheckAliasesWritable = %v, want no problems", problems)
+	}
+	entries, err := os.ReadDir(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("the check left %d entries in the home directory", len(entries))
+	}
+}
+
+func TestCheckAliasesWritableLeavesRCFileAlone(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	rc := filepath.Join(home, ".bashrc")
+	if err := os.WriteFile(rc, []byte("export EDITOR=vim\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if problems := CheckAliasesWritable(config.Aliases{Shell: "bash"}); len(problems) != 0 {
+		t.Errorf("CheckAliasesWritable = %v, want no problems", problems)
+	}
+	if data, _ := os.ReadFile(rc); string(data) != "export EDITOR=vim\n" {
+		t.Errorf(".bashrc changed to %q", data)
+	}
+}
+
+func TestCheckAliasesWritableReportsReadOnlyHome(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Chmod(home, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(home, 0755) })
+
+	if problems := CheckAliasesWritable(config.Aliases{Shell: "zsh"}); len(problems) != 1 {
+		t.Errorf("CheckAliasesWritable = %v, want one problem", problems)
+	}
+}
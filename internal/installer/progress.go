@@ -0,0 +1,112 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"setup-machine/internal/logger"
+	"sync/atomic"
+	"time"
+)
+
+// progressMinSize is the smallest download that reports progress; smaller ones finish too
+// quickly for it to be useful. Downloads of unknown size always report.
+const progressMinSize = 1 << 20
+
+// Progress is redrawn in place this often on a terminal, and logged as a regular line this
+// often otherwise (CI logs and pipes can't rewrite lines).
+const (
+	progressRedrawInterval = 200 * time.Millisecond
+	progressLogInterval    = 5 * time.Second
+)
+
+// activeDownloads counts downloads in flight. Only a download that runs alone redraws its
+// progress in place; concurrent downloads would overwrite each other's line.
+var activeDownloads atomic.Int32
+
+// isTerminal reports whether stdout is a terminal that can redraw a progress line.
+var isTerminal = func() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressWriter counts the bytes written through it and periodically reports them.
+type progressWriter struct {
+	log      *logger.Logger
+	name     string        // What is being downloaded, for the progress message
+	total    int64         // Expected size in bytes, or -1 if unknown
+	written  int64         // Bytes received so far
+	inPlace  bool          // Redraw a single line instead of logging new ones
+	interval time.Duration // Minimum time between reports
+	last     time.Time     // When progress was last reported
+	reported bool          // Whether any progress was reported yet
+}
+
+// trackProgress returns a writer to tee a download of total bytes (-1 if unknown) through,
+// and a function to call when the download ends. Small downloads get a writer that does
+// nothing, so callers don't need to special-case them.
+func trackProgress(log *logger.Logger, name string, total int64) (io.Writer, func()) {
+	if total >= 0 && total < progressMinSize {
+		return io.Discard, func() {}
+	}
+
+	p := &progressWriter{log: log, name: name, total: total, interval: progressLogInterval, last: time.Now()}
+	if activeDownloads.Add(1) == 1 && isTerminal() {
+		p.inPlace = true
+		p.interval = progressRedrawInterval
+	}
+	return p, func() {
+		activeDownloads.Add(-1)
+		p.finish()
+	}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.report()
+	}
+	return len(b), nil
+}
+
+// report prints the current progress; on a terminal the line ends in \r so the next
+// report overwrites it.
+func (p *progressWriter) report() {
+	p.reported = true
+	end := "\n"
+	if p.inPlace {
+		end = "   \r"
+	}
+	p.log.Info("[INFO] Downloading %s: %s%s", p.name, formatProgress(p.written, p.total), end)
+}
+
+// finish terminates an in-place progress line with the final byte count.
+func (p *progressWriter) finish() {
+	if p.inPlace && p.reported {
+		p.log.Info("[INFO] Downloading %s: %s   \n", p.name, formatProgress(p.written, p.total))
+	}
+}
+
+// formatProgress renders e.g. "42% (12.5 MiB of 29.8 MiB)", or just the bytes received when
+// the total size is unknown.
+func formatProgress(written, total int64) string {
+	if total <= 0 {
+		return formatBytes(written)
+	}
+	return fmt.Sprintf("%d%% (%s of %s)", written*100/total, formatBytes(written), formatBytes(total))
+}
+
+// formatBytes renders a byte count in binary units, e.g. "12.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,80 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// runPython executes a Python packaging command (pipx, python3) and returns its combined output.
+// Tests replace it to check the pip and pipx invocations.
+var runPython = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, name, args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// pythonRequirement returns the pip requirement for a tool, pinned when a version is set.
+func pythonRequirement(tool config.Tool) string {
+	if tool.Version == "" {
+		return tool.Name
+	}
+	return tool.Name + "==" + tool.Version
+}
+
+// pipxInstallArgs returns the pipx arguments that install a tool.
+// --force lets a pinned version replace an installed one on upgrade.
+func pipxInstallArgs(tool config.Tool) []string {
+	return []string{"install", "--force", pythonRequirement(tool)}
+}
+
+// pipInstallArgs returns the python3 arguments that install a tool with pip into the user base.
+func pipInstallArgs(tool config.Tool) []string {
+	return []string{"-m", "pip", "install", "--user", pythonRequirement(tool)}
+}
+
+// installFromPipx installs a Python CLI into its own virtualenv with pipx and returns the
+// script path pipx exposes in ~/.local/bin.
+func installFromPipx(ctx context.Context, tool config.Tool) (string, error) {
+	args := pipxInstallArgs(tool)
+	output, err := runPython(ctx, "pipx", args...)
+	if err != nil {
+		return "", fmt.Errorf("pipx %s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "bin", tool.Name), nil
+}
+
+// installFromPip installs a Python CLI with `pip install --user` and returns the script path
+// under the user base (~/.local/bin on Linux, ~/Library/Python/<version>/bin on macOS).
+func installFromPip(ctx context.Context, tool config.Tool) (string, error) {
+	args := pipInstallArgs(tool)
+	output, err := runPython(ctx, "python3", args...)
+	if err != nil {
+		return "", fmt.Errorf("python3 %s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+
+	base, err := runPython(ctx, "python3", "-m", "site", "--user-base")
+	if err != nil {
+		return "", fmt.Errorf("cannot determine Python user base: %v\nOutput: %s", err, base)
+	}
+	return filepath.Join(strings.TrimSpace(string(base)), "bin", tool.Name), nil
+}
+
+// uninstallFromPython removes a tool installed with the pip or pipx source.
+func uninstallFromPython(source, name string) error {
+	var output []byte
+	var err error
+	if source == "pipx" {
+		output, err = runPython(context.Background(), "pipx", "uninstall", name)
+	} else {
+		output, err = runPython(context.Background(), "python3", "-m", "pip", "uninstall", "-y", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s uninstall %s failed: %v\nOutput: %s", source, name, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package installer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+)
+
+// fakePython replaces runPython with a runner that records each call as "name args...".
+// `python3 -m site --user-base` prints userBase.
+func fakePython(t *testing.T, userBase string) *[]string {
+	t.Helper()
+	orig := runPython
+	t.Cleanup(func() { runPython = orig })
+
+	var calls []string
+	runPython = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		call := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, call)
+		if call == "python3 -m site --user-base" {
+			return []byte(userBase + "\n"), nil
+		}
+		return nil, nil
+	}
+	return &calls
+}
+
+func TestInstallFromPipx(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		tool config.Tool
+		want string
+	}{
+		{config.Tool{Name: "httpie", Version: "3.2.2"}, "pipx install --force httpie==3.2.2"},
+		{config.Tool{Name: "httpie"}, "pipx install --force httpie"},
+	}
+	for _, tt := range tests {
+		calls := fakePython(t, "")
+		path, err := installFromPipx(context.Background(), tt.tool)
+		if err != nil {
+			t.Fatalf("installFromPipx(%s): %v", tt.tool.Name, err)
+		}
+		if len(*calls) != 1 || (*calls)[0] != tt.want {
+			t.Errorf("calls = %q, want [%q]", *calls, tt.want)
+		}
+		if want := filepath.Join(home, ".local", "bin", "httpie"); path != want {
+			t.Errorf("installFromPipx(%s) = %s, want %s", tt.tool.Name, path, want)
+		}
+	}
+}
+
+func TestInstallFromPip(t *testing.T) {
+	tests := []struct {
+		tool config.Tool
+		want string
+	}{
+		{config.Tool{Name: "black", Version: "24.4.2"}, "python3 -m pip install --user black==24.4.2"},
+		{config.Tool{Name: "black"}, "python3 -m pip install --user black"},
+	}
+	for _, tt := range tests {
+		calls := fakePython(t, "/Users/me/Library/Python/3.12")
+		path, err := installFromPip(context.Background(), tt.tool)
+		if err != nil {
+			t.Fatalf("installFromPip(%s): %v", tt.tool.Name, err)
+		}
+		want := []string{tt.want, "python3 -m site --user-base"}
+		if strings.Join(*calls, "|") != strings.Join(want, "|") {
+			t.Errorf("calls = %q, want %q", *calls, want)
+		}
+		if path != "/Users/me/Library/Python/3.12/bin/black" {
+			t.Errorf("installFromPip(%s) = %s, want the user base's bin/black", tt.tool.Name, path)
+		}
+	}
+}
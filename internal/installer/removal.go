@@ -0,0 +1,177 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"sort"
+	"strings"
+)
+
+// Uninstall strategies, named as they're shown in removal previews.
+const (
+	strategyBrew       = "brew uninstall"
+	strategyNpm        = "npm uninstall"
+	strategyPython     = "pip uninstall"
+	strategySystemPkg  = "package removal"
+	strategyManual     = "manual removal"
+	strategyRemovePath = "file removal"
+	strategyPkgutil    = "pkgutil forget"
+	strategySudoRemove = "sudo removal"
+)
+
+// SudoRemoval enables the last-resort strategy that deletes /usr/local/bin/<tool> with
+// `sudo rm -f` when nothing else removed the tool. It's off unless set via `--sudo-removal`,
+// and every removal is still confirmed.
+var SudoRemoval bool
+
+// uninstallStrategies returns the strategies uninstallTool tries for a tool, in order.
+// uninstallTool stops at the first one that succeeds.
+func uninstallStrategies(ts state.ToolState) []string {
+	var strategies []string
+	switch ts.Source {
+	case "brew":
+		if ts.Cask {
+			// A cask's install path is its Caskroom directory, and the app lives elsewhere;
+			// only Homebrew knows how to remove it completely
+			return []string{strategyBrew}
+		}
+		strategies = append(strategies, strategyBrew)
+	case "npm":
+		strategies = append(strategies, strategyNpm)
+	case "pip", "pipx":
+		strategies = append(strategies, strategyPython)
+	case "script":
+		// Only the vendor's script knows what it installed (toolchains, shell profile edits, ...);
+		// deleting the binary alone would leave a half-removed tool behind
+		return []string{strategyManual}
+	case "apt", "dnf":
+		// The package manager owns the executable (often under /usr/bin); never delete it directly
+		return []string{strategySystemPkg}
+	}
+	if ts.InstallPath != "" {
+		strategies = append(strategies, strategyRemovePath)
+	}
+	strategies = append(strategies, strategyPkgutil)
+	if SudoRemoval {
+		strategies = append(strategies, strategySudoRemove)
+	}
+	return strategies
+}
+
+// sudoRemovalPath is the file the sudo removal fallback deletes for a tool. It is the exact
+// name: a prefix match would also take unrelated binaries, e.g. gofmt along with go.
+func sudoRemovalPath(name string) string {
+	return filepath.Join(sudoRemovalDir, filepath.Base(name))
+}
+
+// sudoRemovalDir is where the sudo removal fallback looks for a tool. Tests point it at a
+// temp directory.
+var sudoRemovalDir = "/usr/local/bin"
+
+// matchingPackages returns the installed macOS packages whose identifier contains name.
+func matchingPackages(name string) ([]string, error) {
+	logger.Trace("[TRACE] Running command: pkgutil --pkgs\n")
+	output, err := exec.Command("pkgutil", "--pkgs").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pkgutil: %v\nOutput: %s", err, output)
+	}
+	var packages []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" && strings.Contains(line, name) {
+			packages = append(packages, line)
+		}
+	}
+	return packages, nil
+}
+
+// Removal describes what uninstalling a tool would do, without doing it.
+// Steps are always performed; Strategies are tried in order until one succeeds.
+type Removal struct {
+	Name       string
+	Version    string
+	Steps      []string
+	Strategies []string
+}
+
+// PreviewRemovals returns the tools a sync would uninstall (recorded in state but no longer
+// in the config) along with the uninstall strategies that would be tried. Nothing is changed;
+// pkgutil and the sudo removal fallback are queried read-only so the preview shows what they'd match.
+func PreviewRemovals(tools []config.Tool, st *state.State) []Removal {
+	existing := map[string]bool{}
+	for _, tool := range tools {
+		existing[tool.Name] = true
+	}
+
+	var removals []Removal
+	for name, ts := range st.Tools {
+		// Tools setup-machine did not install are never removed, only dropped from state, and
+		// tools added with `install` are kept by syncs
+		if existing[name] || !ts.InstalledByDevSetup || ts.Spec != "" {
+			continue
+		}
+
+		r := Removal{Name: name, Version: ts.Version}
+		var files []string
+		for path := range ts.Files {
+			files = append(files, path)
+		}
+		sort.Strings(files)
+		for _, path := range files {
+			r.Steps = append(r.Steps, "remove managed file "+path)
+		}
+		if ts.ArtifactDir != "" {
+			r.Steps = append(r.Steps, "remove artifact directory "+ts.ArtifactDir)
+		}
+
+		for _, strategy := range uninstallStrategies(ts) {
+			r.Strategies = append(r.Strategies, describeStrategy(strategy, name, ts))
+		}
+		removals = append(removals, r)
+	}
+
+	sort.Slice(removals, func(i, j int) bool { return removals[i].Name < removals[j].Name })
+	return removals
+}
+
+// describeStrategy renders a strategy with the concrete target it would act on.
+func describeStrategy(strategy, name string, ts state.ToolState) string {
+	switch strategy {
+	case strategyBrew:
+		return fmt.Sprintf("%s: brew %s", strategy, strings.Join(brewUninstallArgs(name, ts.Cask), " "))
+	case strategyNpm:
+		return fmt.Sprintf("%s: npm uninstall -g %s", strategy, name)
+	case strategyPython:
+		if ts.Source == "pipx" {
+			return fmt.Sprintf("pipx uninstall: pipx uninstall %s", name)
+		}
+		return fmt.Sprintf("%s: python3 -m pip uninstall -y %s", strategy, name)
+	case strategyManual:
+		return fmt.Sprintf("%s: installed by a script; remove it with the tool's own uninstaller", strategy)
+	case strategySystemPkg:
+		return fmt.Sprintf("%s: sudo %s", strategy, strings.Join(systemUninstallArgs(ts.Source, name), " "))
+	case strategyRemovePath:
+		return fmt.Sprintf("%s: %s", strategy, ts.InstallPath)
+	case strategyPkgutil:
+		packages, err := matchingPackages(name)
+		switch {
+		case err != nil:
+			return fmt.Sprintf("%s: packages matching %q (pkgutil unavailable)", strategy, name)
+		case len(packages) == 0:
+			return fmt.Sprintf("%s: no packages match %q", strategy, name)
+		default:
+			return fmt.Sprintf("%s: %s", strategy, strings.Join(packages, ", "))
+		}
+	case strategySudoRemove:
+		path := sudoRemovalPath(name)
+		if _, err := os.Lstat(path); err != nil {
+			return fmt.Sprintf("%s: %s does not exist", strategy, path)
+		}
+		return fmt.Sprintf("%s: sudo rm -f %s", strategy, path)
+	}
+	return strategy
+}
@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/state"
+)
+
+// sudoRemovalSetup fills a temp sudoRemovalDir with go and binaries sharing its prefix, and
+// makes runSudo actually delete what `sudo rm -f` is asked to. It returns the directory and
+// the recorded sudo calls.
+func sudoRemovalSetup(t *testing.T) (string, *[]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"go", "gofmt", "golangci-lint", "gopls"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sudo, removal, interactive, assumed := runSudo, sudoRemovalDir, isInteractive, AssumeYesFor
+	t.Cleanup(func() { runSudo, sudoRemovalDir, isInteractive, AssumeYesFor = sudo, removal, interactive, assumed })
+	sudoRemovalDir = dir
+	isInteractive = func() bool { return false }
+	AssumeYesFor = map[string]bool{}
+
+	var calls []string
+	runSudo = func(ctx context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if len(args) == 3 && args[0] == "rm" && args[1] == "-f" {
+			os.Remove(args[2])
+		}
+		return nil, nil
+	}
+	return dir, &calls
+}
+
+// useSudoRemoval sets SudoRemoval for the duration of a test.
+func useSudoRemoval(t *testing.T, enabled bool) {
+	t.Helper()
+	orig := SudoRemoval
+	SudoRemoval = enabled
+	t.Cleanup(func() { SudoRemoval = orig })
+}
+
+// remaining lists the files left in dir.
+func remaining(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSudoRemovalOnlyTakesTheExactName(t *testing.T) {
+	dir, calls := sudoRemovalSetup(t)
+	useSudoRemoval(t, true)
+	AssumeYesFor[ConfirmUninstall] = true
+
+	// No install path and no package to forget: only the sudo fallback can remove go
+	if !uninstallTool("go", state.ToolState{Version: "1.22", InstalledByDevSetup: true, Source: "url"}) {
+		t.Fatal("uninstallTool failed")
+	}
+	if want := "rm -f " + filepath.Join(dir, "go"); strings.Join(*calls, "|") != want {
+		t.Errorf("sudo calls = %q, want %q", *calls, want)
+	}
+	if got := remaining(t, dir); got != "gofmt,golangci-lint,gopls" {
+		t.Errorf("left in %s: %s, want every binary sharing the go prefix", dir, got)
+	}
+}
+
+func TestSudoRemovalNeedsFlagAndConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "without --sudo-removal", enabled: false},
+		{name: "unconfirmed", enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, calls := sudoRemovalSetup(t)
+			useSudoRemoval(t, tt.enabled)
+
+			if uninstallTool("go", state.ToolState{Version: "1.22", InstalledByDevSetup: true, Source: "url"}) {
+				t.Error("uninstallTool reported success without removing anything")
+			}
+			if len(*calls) != 0 {
+				t.Errorf("sudo calls = %q, want none", *calls)
+			}
+			if got := remaining(t, dir); got != "go,gofmt,golangci-lint,gopls" {
+				t.Errorf("left in %s: %s, want everything", dir, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package installer
+
+import (
+	"os/exec"
+	"setup-machine/internal/logger"
+	"sort"
+	"strings"
+)
+
+// NoRestart skips restarting apps after their settings change, for users who don't want
+// windows closing mid-session. It's set via the `--no-restart` flag.
+var NoRestart bool
+
+// restartAppsByDomain maps settings domains to the app that only picks up changes to them
+// after a restart. A setting's own Restart field takes precedence.
+var restartAppsByDomain = map[string]string{
+	"com.apple.finder":          "Finder",
+	"com.apple.dock":            "Dock",
+	"com.apple.SystemUIServer":  "SystemUIServer",
+	"com.apple.menuextra.clock": "SystemUIServer",
+	"com.apple.screencapture":   "SystemUIServer",
+}
+
+// runKillall runs `killall <app>`. Tests swap it out so they never restart the real Dock or
+// Finder.
+var runKillall = func(app string) ([]byte, error) {
+	cmd := exec.Command("killall", app)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// addRestart records the app that must restart for a changed setting: the configured one,
+// or the one inferred from the domain. "none" opts a setting out.
+func addRestart(apps map[string]bool, configured, domain string) {
+	app := configured
+	if app == "" {
+		app = restartAppsByDomain[domain]
+	}
+	if app != "" && !strings.EqualFold(app, "none") {
+		apps[app] = true
+	}
+}
+
+// restartApps restarts each app once, so settings written during the run take effect.
+// macOS relaunches Finder, Dock, and SystemUIServer automatically. Failures are warnings:
+// the settings are written either way, and an app that isn't running needs no restart.
+func restartApps(apps map[string]bool) {
+	names := make([]string, 0, len(apps))
+	for app := range apps {
+		names = append(names, app)
+	}
+	sort.Strings(names)
+
+	for _, app := range names {
+		switch {
+		case NoRestart:
+			logger.Info("[INFO] Restart %s for the new settings to take effect (skipped: --no-restart)\n", app)
+		case DryRun:
+			logger.Info("[DRY-RUN] Would restart %s\n", app)
+		default:
+			if output, err := runKillall(app); err != nil {
+				logger.Warn("[WARN] Failed to restart %s: %v %s\n", app, err, strings.TrimSpace(string(output)))
+				continue
+			}
+			logger.Info("[INFO] Restarted %s to apply the new settings\n", app)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+// fakeKillall replaces runKillall with a runner that records the apps it was asked to restart.
+func fakeKillall(t *testing.T) *[]string {
+	t.Helper()
+	orig := runKillall
+	t.Cleanup(func() { runKillall = orig })
+
+	var apps []string
+	runKillall = func(app string) ([]byte, error) {
+		apps = append(apps, app)
+		return nil, nil
+	}
+	return &apps
+}
+
+// restartSettings changes two Dock settings, one opted-out Finder setting, one setting naming
+// its own app, and one that already has its value.
+var restartSettings = []config.Setting{
+	{Domain: "com.apple.dock", Key: "autohide", Type: "bool", Value: "true"},
+	{Domain: "com.apple.dock", Key: "tilesize", Type: "int", Value: "48"},
+	{Domain: "com.apple.finder", Key: "ShowPathbar", Type: "bool", Value: "true", Restart: "none"},
+	{Domain: "com.example.editor", Key: "Theme", Value: "dark", Restart: "Editor"},
+	{Domain: "com.apple.screencapture", Key: "type", Value: "png"},
+}
+
+func TestSyncSettingsRestartsChangedAppsOnce(t *testing.T) {
+	newFakePrefs(t, map[string]string{"com.apple.screencapture:type": "png"})
+	apps := fakeKillall(t)
+
+	SyncSettings(restartSettings, &state.State{Settings: map[string]state.SettingState{}})
+
+	if got := strings.Join(*apps, ","); got != "Dock,Editor" {
+		t.Errorf("restarted %s, want Dock,Editor", got)
+	}
+}
+
+func TestSyncSettingsNoRestart(t *testing.T) {
+	newFakePrefs(t, map[string]string{})
+	apps := fakeKillall(t)
+	NoRestart = true
+	t.Cleanup(func() { NoRestart = false })
+
+	SyncSettings(restartSettings, &state.State{Settings: map[string]state.SettingState{}})
+
+	if len(*apps) != 0 {
+		t.Errorf("restarted %v with NoRestart set", *apps)
+	}
+}
@@ -0,0 +1,81 @@
+package installer
+
+import (
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"sort"
+)
+
+// RestoreSettings reverts every setting recorded in st to the value it had before
+// setup-machine first changed it: the previous value is written back, or the key is deleted
+// if it did not exist. Restored settings are removed from st. A failure is logged and the
+// setting stays recorded, so the rest are still restored and the failed ones can be retried.
+func RestoreSettings(st *state.State) {
+	keys := make([]string, 0, len(st.Settings))
+	for key := range st.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	restart := map[string]bool{}
+	defer func() { restartApps(restart) }()
+
+	for _, key := range keys {
+		ss := st.Settings[key]
+		if ss.Previous == "" && !ss.PreviousUnset {
+			logger.Warn("[WARN] No previous value recorded for %s (applied by an older version); leaving it as is\n", key)
+			continue
+		}
+
+		args := restoreArgs(ss)
+		if DryRun {
+			logger.Info("[DRY-RUN] Would run: %s\n", defaultsCommandLine(ss.Sudo, args))
+			addRestart(restart, "", ss.Domain)
+			continue
+		}
+		if output, err := runDefaults(ss.Sudo, args...); err != nil {
+			logger.Error("[ERROR] Failed to restore %s: %v\nOutput: %s\n", key, err, output)
+			continue
+		}
+
+		if ss.PreviousUnset {
+			logger.Info("[INFO] Restored %s by deleting it (it was not set before)\n", key)
+		} else {
+			logger.Info("[INFO] Restored %s = %s\n", key, ss.Previous)
+		}
+		delete(st.Settings, key)
+		addRestart(restart, "", ss.Domain)
+	}
+}
+
+// restoreArgs returns the `defaults` arguments that put a setting back to its previous value.
+// The previous value is written with the type the setting was applied with; older state
+// without a recorded type falls back to one inferred from the value.
+func restoreArgs(ss state.SettingState) []string {
+	s := config.Setting{Domain: ss.Domain, Key: ss.Key, Value: ss.Previous, Type: ss.Type, CurrentHost: ss.CurrentHost, Sudo: ss.Sudo}
+	if ss.PreviousUnset {
+		return defaultsArgs(s, "delete")
+	}
+	if s.Type == "" {
+		s.Type = guessSettingType(ss.Previous)
+	}
+	typ := s.Type
+	switch typ {
+	case "array", "array-add":
+		// The previous value is the whole array as `defaults read` printed it, so it is
+		// written back in full rather than appended to
+		if items, ok := parsePlistArray(ss.Previous); ok {
+			s.Type, s.Items = "array", items
+		} else {
+			s.Type = "string"
+		}
+	case "dict":
+		if dict, ok := parsePlistDict(ss.Previous); ok {
+			s.Dict = dict
+		} else {
+			s.Type = "string"
+		}
+	}
+	return defaultsWriteArgs(s)
+}
@@ -0,0 +1,56 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"setup-machine/internal/logger"
+	"time"
+)
+
+// MaxRetries is how many times a failed HTTP request is retried after the first attempt.
+// The wait doubles after every attempt, starting at RetryDelay (1s, 2s, 4s by default).
+var MaxRetries = 3
+
+// RetryDelay is the wait before the first retry.
+var RetryDelay = time.Second
+
+// retryableError marks a failure worth retrying: network errors, truncated downloads, and
+// 5xx responses. Anything else (a 404, a rate limit, a local write error) fails immediately.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// retryable wraps err so withRetry tries the operation again.
+func retryable(err error) error {
+	return retryableError{err: err}
+}
+
+// isServerError reports whether an HTTP status is a 5xx, i.e. a likely transient server fault.
+func isServerError(status int) bool {
+	return status >= 500 && status <= 599
+}
+
+// withRetry runs fn until it succeeds, returns an error not marked retryable, or has been
+// retried MaxRetries times, backing off exponentially between attempts. what describes the
+// operation for the warning logged before each retry. Once ctx is done no further attempt is
+// made, and the last error is returned without waiting out the backoff.
+func withRetry(ctx context.Context, what string, log *logger.Logger, fn func() error) error {
+	delay := RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var r retryableError
+		if err == nil || !errors.As(err, &r) || attempt > MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("[WARN] %s failed (attempt %d of %d): %v; retrying in %s\n", what, attempt, MaxRetries+1, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,304 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"strings"
+	"time"
+)
+
+// GenerateScript renders the changes a sync would make on this machine as a standalone bash
+// script: tool installs, settings writes, and alias edits, each preceded by a comment. It is
+// read-only: GitHub release metadata is looked up to pin asset URLs, but nothing is installed,
+// written, or saved. Uninstalls are heuristic, so they are only listed as comments.
+//
+// Steps that can't be planned (e.g. a release that can't be looked up) are left in the script
+// as "# ERROR:" comments, and an error counting them is returned along with the script.
+func GenerateScript(cfg config.Config, st *state.State) (string, error) {
+	var b scriptBuilder
+	fmt.Fprintf(&b, "#!/usr/bin/env bash\n")
+	fmt.Fprintf(&b, "# Generated by setup-machine generate-script on %s.\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# Reproduces the changes `setup-machine sync` would make on this machine.\n")
+	fmt.Fprintf(&b, "set -euo pipefail\n")
+
+	writeToolsScript(&b, cfg.Tools, st)
+	writeSettingsScript(&b, cfg.Settings, st)
+	writeAliasesScript(&b, cfg.Aliases)
+	if b.failed > 0 {
+		return b.String(), fmt.Errorf("%d step(s) could not be planned; see the # ERROR comments in the script", b.failed)
+	}
+	return b.String(), nil
+}
+
+// scriptBuilder accumulates a generated script and counts the steps that could not be planned.
+type scriptBuilder struct {
+	strings.Builder
+	failed int
+}
+
+// writeError records a step that could not be planned as an "# ERROR:" comment in its place.
+func (b *scriptBuilder) writeError(err error) {
+	fmt.Fprintf(b, "# ERROR: %s\n", commentLine(err.Error()))
+	b.failed++
+}
+
+// writeToolsScript emits the install commands for tools that are missing or at another version.
+func writeToolsScript(b *scriptBuilder, tools []config.Tool, st *state.State) {
+	fmt.Fprintf(b, "\n# ----- Tools -----\n")
+	binDir := resolveBinDir(BinDirs[0])
+	existing := map[string]bool{}
+
+	for _, tool := range tools {
+		existing[tool.Name] = true
+		if !tool.IsEnabled() {
+			continue
+		}
+		tool, err := ResolveVersion(tool, logger.WithPrefix(tool.Name))
+		if err != nil {
+			fmt.Fprintf(b, "\n# %s (%s)\n", tool.Name, tool.Source)
+			b.writeError(err)
+			continue
+		}
+		if cur, ok := st.Tools[tool.Name]; ok && cur.Version == tool.Version {
+			continue
+		}
+
+		fmt.Fprintf(b, "\n# %s@%s (%s)\n", tool.Name, tool.Version, tool.Source)
+		// Refused the same way installFromScript refuses it; none of its commands are emitted
+		if tool.Source == "script" && !tool.Trusted {
+			fmt.Fprintf(b, "# Skipped: script installs run arbitrary code; set trusted: true on %s to allow it\n", tool.Name)
+			continue
+		}
+		if tool.RequireApproval {
+			fmt.Fprintf(b, "# Requires accepting the license: %s\n", tool.LicenseURL)
+		}
+		if len(tool.PreInstall) > 0 || len(tool.PostInstall) > 0 {
+			fmt.Fprintf(b, "export SETUP_MACHINE_TOOL=%s SETUP_MACHINE_VERSION=%s\n", shellQuote(tool.Name), shellQuote(tool.Version))
+		}
+		for _, command := range tool.PreInstall {
+			fmt.Fprintf(b, "%s\n", command)
+		}
+
+		switch tool.Source {
+		case "github":
+			log := logger.WithPrefix(tool.Name)
+			release, assetURL, assetName, err := resolveGitHubAsset(context.Background(), tool, log)
+			if err != nil {
+				b.writeError(err)
+				continue
+			}
+			expected, err := expectedChecksum(context.Background(), tool, release, assetName, log)
+			if err != nil {
+				fmt.Fprintf(b, "# WARNING: checksum unavailable: %s\n", commentLine(err.Error()))
+			}
+			writeDownloadScript(b, tool, assetURL, expected, binDir)
+		case "url":
+			expected := ""
+			if tool.Checksum != "" && strings.ToLower(tool.Checksum) != checksumSkip {
+				expected = normalizeChecksum(tool.Checksum)
+			}
+			writeDownloadScript(b, tool, tool.URL, expected, binDir)
+		case "brew":
+			for _, tap := range tool.Taps {
+				writeCommand(b, "brew", "tap", tap)
+			}
+			writeCommand(b, "brew", brewInstallArgs(tool)...)
+		case "npm":
+			writeCommand(b, "npm", npmInstallArgs(tool)...)
+		case "pipx":
+			writeCommand(b, "pipx", pipxInstallArgs(tool)...)
+		case "pip":
+			writeCommand(b, "python3", pipInstallArgs(tool)...)
+		case "apt", "dnf":
+			writeCommand(b, "sudo", systemInstallArgs(tool)...)
+		case "script":
+			file := "/tmp/" + filepath.Base(tool.Name) + "-install.sh"
+			writeCommand(b, "curl", "-fsSL", "-o", file, tool.URL)
+			if tool.Checksum != "" && strings.ToLower(tool.Checksum) != checksumSkip {
+				fmt.Fprintf(b, "echo %s | shasum -a 256 -c -\n", shellQuote(normalizeChecksum(tool.Checksum)+"  "+file))
+			}
+			writeCommand(b, "env", append(scriptEnv(tool), append([]string{"sh", file}, tool.Args...)...)...)
+		default:
+			fmt.Fprintf(b, "# Unknown source %q; skipped\n", tool.Source)
+			continue
+		}
+
+		for _, command := range tool.PostInstall {
+			fmt.Fprintf(b, "%s\n", command)
+		}
+		for _, spec := range tool.Files {
+			writeManagedFileScript(b, tool, spec)
+		}
+	}
+
+	for name, ts := range st.Tools {
+		if !existing[name] {
+			fmt.Fprintf(b, "\n# %s@%s was removed from config; sync would uninstall it (%s)\n",
+				name, ts.Version, strings.Join(uninstallStrategies(ts), ", then "))
+		}
+	}
+}
+
+// writeDownloadScript emits the download, verification, and install steps for an artifact URL.
+func writeDownloadScript(b *scriptBuilder, tool config.Tool, url, checksum, binDir string) {
+	if tool.Launcher != "" {
+		fmt.Fprintf(b, "# Launcher-based installs are not supported in scripts; install %s manually\n", url)
+		return
+	}
+
+	file := "/tmp/" + path.Base(url)
+	writeCommand(b, "curl", "-fL", "-o", file, url)
+	if checksum != "" {
+		fmt.Fprintf(b, "echo %s | shasum -a 256 -c -\n", shellQuote(checksum+"  "+file))
+	}
+
+	lower := strings.ToLower(file)
+	switch {
+	case strings.HasSuffix(lower, ".pkg"):
+		writeCommand(b, "sudo", "installer", "-pkg", file, "-target", "/")
+	case strings.HasSuffix(lower, ".dmg"):
+		fmt.Fprintf(b, "mnt=\"$(mktemp -d)\"\n")
+		fmt.Fprintf(b, "hdiutil attach %s -nobrowse -readonly -noautoopen -mountpoint \"$mnt\"\n", shellQuote(file))
+		fmt.Fprintf(b, "for app in \"$mnt\"/*.app; do rm -rf \"%s/$(basename \"$app\")\"; ditto \"$app\" \"%s/$(basename \"$app\")\"; done\n", applicationsDir, applicationsDir)
+		fmt.Fprintf(b, "hdiutil detach \"$mnt\"\n")
+	case isSupportedArchive(lower):
+		dir := "/tmp/setup-machine-" + tool.Name
+		writeCommand(b, "mkdir", "-p", dir)
+		if strings.HasSuffix(lower, ".zip") {
+			writeCommand(b, "unzip", "-o", "-q", file, "-d", dir)
+		} else {
+			writeCommand(b, "tar", "-xf", file, "-C", dir)
+		}
+		writeCommand(b, "mkdir", "-p", binDir)
+		patterns := tool.Binaries
+		if len(patterns) == 0 {
+			patterns = []string{filepath.Base(tool.Name) + "*"}
+		}
+		for _, pattern := range patterns {
+			fmt.Fprintf(b, "install -m 0755 \"$(find %s -type f -name %s -perm -u+x | head -n 1)\" %s\n",
+				shellQuote(dir), shellQuote(pattern), shellQuote(binDir+"/"))
+		}
+	default:
+		writeCommand(b, "mkdir", "-p", binDir)
+		writeCommand(b, "install", "-m", "0755", file, filepath.Join(binDir, tool.Name))
+	}
+}
+
+// writeManagedFileScript emits the commands that place one of a tool's managed files.
+func writeManagedFileScript(b *scriptBuilder, tool config.Tool, spec config.FileSpec) {
+	dest := expandHome(spec.Dest)
+	writeCommand(b, "mkdir", "-p", filepath.Dir(dest))
+	if spec.Content == "" {
+		writeCommand(b, "curl", "-fL", "-o", dest, spec.Source)
+		return
+	}
+	content, err := managedFileContent(tool, state.ToolState{}, spec, logger.WithPrefix(tool.Name))
+	if err != nil {
+		b.writeError(err)
+		return
+	}
+	fmt.Fprintf(b, "cat > %s <<'SETUP_MACHINE_EOF'\n%s\nSETUP_MACHINE_EOF\n", shellQuote(dest), strings.TrimSuffix(string(content), "\n"))
+}
+
+// writeSettingsScript emits `defaults write` commands for settings a sync would apply.
+func writeSettingsScript(b *scriptBuilder, settings []config.Setting, st *state.State) {
+	fmt.Fprintf(b, "\n# ----- Settings -----\n")
+	ordered, err := orderSettings(settings)
+	if err != nil {
+		b.writeError(err)
+		return
+	}
+	for _, s := range ordered {
+		key := settingKey(s)
+		prev, ok := st.Settings[key]
+		if !s.IsEnabled() || (ok && s.ApplyOnce && !Force) || (ok && prev.Value == s.Value && !(s.ApplyOnce && Force)) {
+			continue
+		}
+		if s.Sudo {
+			writeCommand(b, "sudo", append([]string{"defaults"}, defaultsWriteArgs(s)...)...)
+		} else {
+			writeCommand(b, "defaults", defaultsWriteArgs(s)...)
+		}
+	}
+}
+
+// writeAliasesScript emits commands that rewrite the managed alias block of the rc file, the
+// way SyncAliases does: any existing block is replaced, so aliases dropped from the config are
+// pruned, and lines outside it are kept. When the file has no block yet, the alias lines older
+// versions appended are moved into it. What goes in the block is planned against the rc file
+// on this machine.
+func writeAliasesScript(b *scriptBuilder, aliases config.Aliases) {
+	fmt.Fprintf(b, "\n# ----- Aliases -----\n")
+	rcPath, err := rcFilePath(aliases)
+	if err != nil {
+		b.writeError(err)
+		return
+	}
+	writeAliasBlockScript(b, rcPath, aliases)
+}
+
+// writeAliasBlockScript emits the commands rewriting the managed alias block of rcPath.
+func writeAliasBlockScript(b *scriptBuilder, rcPath string, aliases config.Aliases) {
+	rc, err := readRCFile(rcPath)
+	if err != nil {
+		b.writeError(err)
+		return
+	}
+	block, _, adopted := planAliasBlock(aliases, aliasShell(aliases), &rc)
+	if !rc.exists && len(block) == 0 {
+		return
+	}
+
+	// The new block goes where the old one was, or at the end of the file when there is none
+	fmt.Fprintf(b, "rc=%s\n", shellQuote(rcPath))
+	fmt.Fprintf(b, "mkdir -p \"$(dirname \"$rc\")\" && touch \"$rc\"\n")
+	fmt.Fprintf(b, "block=$(mktemp) tmp=$(mktemp)\n")
+	if len(block) > 0 {
+		lines := append(append([]string{aliasBlockBegin}, block...), aliasBlockEnd)
+		fmt.Fprintf(b, "cat > \"$block\" <<'SETUP_MACHINE_ALIASES'\n%s\nSETUP_MACHINE_ALIASES\n", strings.Join(lines, "\n"))
+	}
+	fmt.Fprintf(b, "if grep -qxF -e %s \"$rc\"; then\n", shellQuote(aliasBlockBegin))
+	fmt.Fprintf(b, "  awk -v b=%s -v e=%s -v block=\"$block\" '$0 == b { skip = 1; while ((getline line < block) > 0) print line; next } $0 == e { skip = 0; next } !skip' \"$rc\" > \"$tmp\"\n",
+		shellQuote(aliasBlockBegin), shellQuote(aliasBlockEnd))
+	fmt.Fprintf(b, "else\n")
+	if len(adopted) > 0 {
+		patterns := make([]string, 0, len(adopted))
+		for _, line := range adopted {
+			patterns = append(patterns, "-e "+shellQuote(line))
+		}
+		fmt.Fprintf(b, "  grep -vxF %s \"$rc\" > \"$tmp\" || true\n", strings.Join(patterns, " "))
+	} else {
+		fmt.Fprintf(b, "  cat \"$rc\" > \"$tmp\"\n")
+	}
+	fmt.Fprintf(b, "  cat \"$block\" >> \"$tmp\"\n")
+	fmt.Fprintf(b, "fi\n")
+	fmt.Fprintf(b, "cat \"$tmp\" > \"$rc\" && rm -f \"$tmp\" \"$block\"\n")
+}
+
+// writeCommand emits a single command with each argument shell-quoted.
+func writeCommand(b *scriptBuilder, name string, args ...string) {
+	quoted := []string{name}
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
+	}
+	fmt.Fprintf(b, "%s\n", strings.Join(quoted, " "))
+}
+
+// shellQuote wraps s in single quotes for POSIX shells, escaping embedded single quotes.
+// Words made only of characters the shell treats literally are left as they are.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-+=/.,:@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commentLine flattens a message so it fits on a single comment line.
+func commentLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,57 @@
+package installer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+func TestWriteToolsScriptSkipsUntrustedScripts(t *testing.T) {
+	tools := []config.Tool{
+		{Name: "rustup", Source: "script", URL: "https://sh.rustup.rs", PreInstall: []string{"echo preparing rustup"}, PostInstall: []string{"rustup default stable"}},
+		{Name: "uv", Source: "script", URL: "https://astral.sh/uv/install.sh", Trusted: true},
+	}
+	var b scriptBuilder
+	writeToolsScript(&b, tools, &state.State{Tools: map[string]state.ToolState{}})
+	script := b.String()
+
+	if !strings.Contains(script, "# Skipped: script installs run arbitrary code; set trusted: true on rustup") {
+		t.Errorf("script does not explain why rustup was skipped:\n%s", script)
+	}
+	for _, command := range []string{"sh.rustup.rs", "echo preparing rustup", "rustup default stable"} {
+		if strings.Contains(script, command) {
+			t.Errorf("script runs %q for the untrusted rustup:\n%s", command, script)
+		}
+	}
+	if !strings.Contains(script, "curl -fsSL -o /tmp/uv-install.sh https://astral.sh/uv/install.sh") {
+		t.Errorf("script does not install the trusted uv:\n%s", script)
+	}
+}
+
+func TestGenerateScriptFailsForUnplannedSteps(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fastRetries(t)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cfg := config.Config{Tools: []config.Tool{
+		{Name: "cli", Source: "github", Repo: "tools/cli", Version: "1.0.0", APIBase: srv.URL},
+		{Name: "jq", Source: "brew", Version: "1.7"},
+	}}
+	script, err := GenerateScript(cfg, &state.State{Tools: map[string]state.ToolState{}})
+	if err == nil || !strings.Contains(err.Error(), "1 step(s) could not be planned") {
+		t.Errorf("GenerateScript error = %v, want the unplanned cli reported", err)
+	}
+	if !strings.HasPrefix(script, "#!/usr/bin/env bash\n") || !strings.Contains(script, "# ERROR: GitHub release v1.0.0 not found") || !strings.Contains(script, "brew install jq") {
+		t.Errorf("script should still hold the plannable steps and an ERROR comment for cli:\n%s", script)
+	}
+
+	cfg.Tools = cfg.Tools[1:]
+	if _, err := GenerateScript(cfg, &state.State{Tools: map[string]state.ToolState{}}); err != nil {
+		t.Errorf("GenerateScript error = %v, want none when every step is planned", err)
+	}
+}
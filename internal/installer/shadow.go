@@ -0,0 +1,45 @@
+package installer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"setup-machine/internal/logger"
+)
+
+// warnIfShadowed checks that the binary just installed at installPath is the one the shell
+// will actually run. When another file with the same name comes first on PATH (e.g. an old
+// copy in /usr/local/bin shadowing a fresh install in ~/bin), it warns and names the shadow.
+// Non-binary installs such as .pkg apps are not checked.
+func warnIfShadowed(installPath string, log *logger.Logger) {
+	info, err := os.Stat(installPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	name := filepath.Base(installPath)
+	found, err := exec.LookPath(name)
+	if err != nil {
+		log.Warn("[WARN] %s was installed to %s, which is not on PATH\n", name, filepath.Dir(installPath))
+		return
+	}
+	if sameFile(found, installPath) {
+		log.Debug("[DEBUG] %s resolves to %s on PATH\n", name, installPath)
+		return
+	}
+	log.Warn("[WARN] %s at %s is shadowed by %s earlier on PATH; remove %s or reorder PATH to use the new version\n",
+		name, installPath, found, found)
+}
+
+// sameFile reports whether two paths refer to the same file, following symlinks.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
@@ -1,66 +1,130 @@
 package installer
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
-	"os/user"
 	"path/filepath"
 	"setup-machine/internal/config"
 	"setup-machine/internal/logger"
 	"setup-machine/internal/state"
 	"strings"
+	"sync"
+	"time"
 )
 
+// DryRun makes the sync functions log what they would do ("would install X@Y") instead of
+// installing, writing defaults, appending to rc files, or running commands.
+var DryRun bool
+
+// KeepUnlisted skips uninstalling tools that are recorded in state but missing from the tools
+// passed to SyncTools. It's set when the tool list was narrowed (`--only`/`--skip`), since
+// filtered-out tools haven't been removed from the config.
+var KeepUnlisted bool
+
+// Checkpoint, when set, is called whenever a tool's state entry changes so progress can be
+// persisted mid-run. It is called with the state lock held.
+var Checkpoint func(st *state.State)
+
+// checkpoint invokes Checkpoint if one is configured.
+func checkpoint(st *state.State) {
+	if Checkpoint != nil {
+		Checkpoint(st)
+	}
+}
+
 // SyncTools synchronizes the installed tools with the desired config and current state.
 // It installs new tools, upgrades outdated tools, and removes tools no longer in the config.
+//
+// Tools are processed in parallel, at most Jobs at a time and further limited per source by
+// SourceConcurrency, so that e.g. brew installs never overlap while GitHub downloads run side by side.
 func SyncTools(tools []config.Tool, st *state.State) {
 	// Log starting info: how many tools to process and current state entries
 	logger.Debug("[DEBUG] Starting SyncTools with %d tools, current state has %d entries\n", len(tools), len(st.Tools))
 
+	// Tools added with `install` are synced along with the config's
+	if !KeepUnlisted {
+		tools = append(tools[:len(tools):len(tools)], adHocTools(tools, st)...)
+	}
+
 	// Track tools that are present in the current config
 	existing := map[string]bool{}
 
+	// One semaphore per source plus one for the overall job limit; mu guards st while
+	// tools are synced concurrently
+	sems := sourceSemaphores(tools)
+	jobs := make(chan struct{}, max(Jobs, 1))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
 	// Iterate over all desired tools from the config
 	for _, tool := range tools {
 		existing[tool.Name] = true // Mark this tool as existing in config
 
-		// Get current state of this tool from the saved state file
-		curToolState, ok := st.Tools[tool.Name]
-
-		// Check if the tool is missing or the version differs from desired
-		if !ok || curToolState.Version != tool.Version {
-			logger.Debug("[DEBUG] SyncTools: Installing/upgrading %s (current: %s, target: %s)\n", tool.Name, curToolState.Version, tool.Version)
-
-			// Attempt to install or upgrade the tool
-			success, installPath := installTool(tool)
-			if success {
-				// Log success and update the state with the new version and install path
-				logger.Info("[INFO] Installed %s@%s\n", tool.Name, tool.Version)
-				st.Tools[tool.Name] = state.ToolState{
-					Version:             tool.Version,
-					InstallPath:         installPath,
-					InstalledByDevSetup: true,
-				}
-			} else {
-				// Log failure to install
-				logger.Error("[ERROR] Failed to install %s@%s\n", tool.Name, tool.Version)
-			}
-		} else {
-			// Tool is already at the desired version; no action needed
-			logger.Debug("[DEBUG] SyncTools: %s version %s is already current.\n", tool.Name, tool.Version)
-			logger.Info("[INFO] %s version %s is current. Skipping.\n", tool.Name, tool.Version)
+		// Disabled tools are inert: neither installed nor treated as orphans, state left untouched
+		if !tool.IsEnabled() {
+			logger.Info("[INFO] %s is disabled in config. Skipping.\n", tool.Name)
+			continue
 		}
+
+		wg.Add(1)
+		go func(tool config.Tool) {
+			// Take the source slot first so a tool waiting on its source doesn't hold a job slot
+			sem := sems[tool.Source]
+			sem <- struct{}{}
+			jobs <- struct{}{}
+
+			defer wg.Done()
+			syncTool(tool, st, &mu)
+			<-jobs
+			<-sem
+		}(tool)
 	}
+	wg.Wait()
 
 	// Now handle tools that exist in the state but are no longer in the config (should be removed)
+	if KeepUnlisted {
+		logger.Debug("[DEBUG] Tool list is filtered; not uninstalling tools missing from it\n")
+		logger.Debug("[DEBUG] Finished SyncTools\n")
+		return
+	}
 	for name, toolState := range st.Tools {
-		if !existing[name] {
+		// Tools added with `install` are kept as PreviewRemovals says, including those whose
+		// spec no longer parses and so were left out of the tools synced above
+		if !existing[name] && toolState.Spec == "" {
+			// Only tools setup-machine installed itself are removed; anything else was there
+			// before it and belongs to the user, so it is only dropped from state
+			if !toolState.InstalledByDevSetup {
+				if DryRun {
+					logger.Info("[DRY-RUN] Would stop tracking %s; it was not installed by setup-machine and would be left in place\n", name)
+					continue
+				}
+				logger.Warn("[WARN] %s was removed from config but was not installed by setup-machine; leaving it in place, remove it manually if it is no longer needed\n", name)
+				delete(st.Tools, name)
+				checkpoint(st)
+				continue
+			}
+
 			// Tool was removed from config; uninstall it
+			if DryRun {
+				logger.Info("[DRY-RUN] Would uninstall %s@%s (%s)\n", name, toolState.Version, strings.Join(uninstallStrategies(toolState), ", then "))
+				continue
+			}
+			// Without a terminal nobody can approve the uninstall, so it is skipped unless
+			// --yes or --yes-uninstall approved it up front
+			if !confirm(ConfirmUninstall, fmt.Sprintf("%s@%s was removed from config. Uninstall it?", name, toolState.Version), false) {
+				if isInteractive() {
+					logger.Info("[INFO] Keeping %s (uninstall not confirmed)\n", name)
+				} else {
+					logger.Warn("[WARN] Keeping %s: no terminal to confirm the uninstall; pass --yes-uninstall or --yes to approve it\n", name)
+				}
+				continue
+			}
 			logger.Warn("[WARN] %s removed from config. Uninstalling...\n", name)
 			if uninstallTool(name, toolState) {
 				delete(st.Tools, name)
+				checkpoint(st)
 			} else {
 				logger.Warn("[WARN] Failed to uninstall %s completely. Manual cleanup may be required.\n", name)
 			}
@@ -70,248 +134,674 @@ func SyncTools(tools []config.Tool, st *state.State) {
 	logger.Debug("[DEBUG] Finished SyncTools\n")
 }
 
+// syncTool installs, upgrades, or verifies a single enabled tool and records the result in st.
+// It may run concurrently with other tools, so all access to st goes through mu.
+func syncTool(tool config.Tool, st *state.State, mu *sync.Mutex) {
+	toolLog := logger.WithPrefix(tool.Name)
+
+	// Tools tracking "latest" or a version range are pinned to a concrete release for this run,
+	// so they only upgrade when the selected release actually changes
+	tool, err := ResolveVersion(tool, toolLog)
+	if err != nil {
+		logger.Error("[ERROR] Failed to resolve version %q of %s: %v\n", tool.Version, tool.Name, err)
+		return
+	}
+
+	// Get current state of this tool from the saved state file
+	mu.Lock()
+	curToolState, ok := st.Tools[tool.Name]
+	mu.Unlock()
+
+	// Critical tools are verified on every run, even when the version already matches;
+	// other tools are re-verified once their last verification is older than MaxAge
+	needsRepair := false
+	if ok && curToolState.Version == tool.Version && (tool.Critical || verificationDue(curToolState, time.Now())) {
+		if err := verifyInstalled(curToolState); err != nil {
+			logger.Warn("[WARN] %s failed verification: %v. Reinstalling...\n", tool.Name, err)
+			needsRepair = true
+		} else {
+			logger.Debug("[DEBUG] SyncTools: %s verified\n", tool.Name)
+			if !DryRun {
+				warnOnVersionMismatch(tool, curToolState)
+			}
+			curToolState.VerifiedAt = time.Now().UTC()
+			mu.Lock()
+			st.Tools[tool.Name] = curToolState
+			mu.Unlock()
+		}
+	}
+
+	// A url tool whose URL or pinned checksum changed is a different artifact, even at the same version
+	if ok && !needsRepair && curToolState.Version == tool.Version {
+		if reason := artifactChanged(tool, curToolState); reason != "" {
+			logger.Info("[INFO] %s: %s. Reinstalling...\n", tool.Name, reason)
+			needsRepair = true
+		}
+	}
+
+	// Check if the tool is missing, the version differs from desired, or it needs repair
+	if !ok || curToolState.Version != tool.Version || needsRepair {
+		logger.Debug("[DEBUG] SyncTools: Installing/upgrading %s (current: %s, target: %s)\n", tool.Name, curToolState.Version, tool.Version)
+
+		if DryRun {
+			if ok {
+				logger.Info("[DRY-RUN] Would upgrade %s %s -> %s from %s\n", tool.Name, curToolState.Version, tool.Version, tool.Source)
+			} else {
+				logger.Info("[DRY-RUN] Would install %s@%s from %s\n", tool.Name, tool.Version, tool.Source)
+			}
+			runToolHooks("pre_install", tool, "", toolLog)
+			runToolHooks("post_install", tool, "", toolLog)
+			for _, spec := range tool.Files {
+				logger.Info("[DRY-RUN] Would place managed file %s\n", expandHome(spec.Dest))
+			}
+			return
+		}
+
+		// Tools behind a license gate need the user's acknowledgment once, before the first install
+		if !approved(tool, st, mu) {
+			return
+		}
+
+		// Brew tools may need third-party taps first; record the ones we add
+		if tool.Source == "brew" {
+			added, err := ensureTaps(tool.Taps, toolLog)
+			mu.Lock()
+			for _, tap := range added {
+				st.AddTap(tap)
+			}
+			mu.Unlock()
+			if err != nil {
+				logger.Error("[ERROR] Failed to install %s@%s: %v\n", tool.Name, tool.Version, err)
+				return
+			}
+		}
+
+		// Per-tool setup that must happen before the install, e.g. stopping a running daemon
+		if err := runToolHooks("pre_install", tool, "", toolLog); err != nil {
+			if tool.HooksRequired {
+				logger.Error("[ERROR] Failed to install %s@%s: %v\n", tool.Name, tool.Version, err)
+				return
+			}
+			toolLog.Warn("[WARN] %v; continuing with the install\n", err)
+		}
+
+		// Attempt to install or upgrade the tool
+		installed, err := installWithTimeout(tool, toolLog)
+		installPath := installed.Path
+		if err != nil {
+			// Log failure to install along with the reason
+			logger.Error("[ERROR] Failed to install %s@%s: %v\n", tool.Name, tool.Version, err)
+			return
+		}
+
+		// Per-tool setup after the install, e.g. installing plugins or generating completions.
+		// A required hook that fails leaves the tool unrecorded, so the next sync retries it.
+		if err := runToolHooks("post_install", tool, installPath, toolLog); err != nil {
+			if tool.HooksRequired {
+				logger.Error("[ERROR] Installed %s@%s, but its setup failed: %v\n", tool.Name, tool.Version, err)
+				return
+			}
+			toolLog.Warn("[WARN] %v\n", err)
+		}
+
+		// Log success and update the state with the new version and install path
+		logger.Info("[INFO] Installed %s@%s\n", tool.Name, tool.Version)
+		warnIfShadowed(installPath, toolLog)
+		ts := state.ToolState{
+			Version:             tool.Version,
+			InstallPath:         installPath,
+			InstalledByDevSetup: true,
+			Checksum:            installedChecksum(installPath),
+			VerifiedAt:          time.Now().UTC(),
+			Source:              tool.Source,
+			BinDir:              binDirOf(installPath),
+			Cask:                tool.Source == "brew" && tool.Cask,
+			Download:            installed.Download,
+		}
+		if tool.Source == "url" {
+			ts.URL = tool.URL
+		}
+		ts.Spec = tool.Spec
+		if tool.Launcher != "" {
+			ts.ArtifactDir = toolDataDir(tool.Name)
+		}
+		// Carry over managed files so they're updated rather than forgotten
+		ts.Files = curToolState.Files
+		curToolState, ok = ts, true
+		mu.Lock()
+		st.Tools[tool.Name] = ts
+		checkpoint(st)
+		mu.Unlock()
+	} else {
+		// Tool is already at the desired version; no action needed
+		logger.Debug("[DEBUG] SyncTools: %s version %s is already current.\n", tool.Name, tool.Version)
+		logger.Info("[INFO] %s version %s is current. Skipping.\n", tool.Name, tool.Version)
+	}
+
+	// Place or update the tool's managed config files
+	if ok {
+		files := syncManagedFiles(tool, curToolState, toolLog)
+		mu.Lock()
+		ts := st.Tools[tool.Name]
+		ts.Files = files
+		st.Tools[tool.Name] = ts
+		mu.Unlock()
+	}
+}
+
+// artifactChanged explains why a url tool's recorded download no longer matches the config,
+// or returns "" if it still does. State from before URLs were recorded is never reported.
+func artifactChanged(tool config.Tool, ts state.ToolState) string {
+	if tool.Source != "url" {
+		return ""
+	}
+	if ts.URL != "" && ts.URL != tool.URL {
+		return fmt.Sprintf("URL changed from %s to %s", ts.URL, tool.URL)
+	}
+	if tool.Checksum != "" && strings.ToLower(tool.Checksum) != checksumSkip && ts.Download != "" && normalizeChecksum(tool.Checksum) != ts.Download {
+		return "pinned checksum changed"
+	}
+	return ""
+}
+
+// approved reports whether a tool may be installed under its license gate. Tools without
+// require_approval always may; otherwise a recorded approval is reused, or the user is asked
+// and the answer recorded. Without a terminal (and without --yes) gated tools are skipped.
+func approved(tool config.Tool, st *state.State, mu *sync.Mutex) bool {
+	if !tool.RequireApproval {
+		return true
+	}
+
+	mu.Lock()
+	_, ok := st.Approvals[tool.Name]
+	mu.Unlock()
+	if ok {
+		return true
+	}
+
+	license := tool.LicenseURL
+	if license == "" {
+		license = "its license terms"
+	}
+	if !confirm(ConfirmLicense, fmt.Sprintf("%s requires accepting %s. Accept and install?", tool.Name, license), false) {
+		logger.Warn("[WARN] Skipping %s: license approval required (run interactively to accept, or pass --yes)\n", tool.Name)
+		return false
+	}
+
+	logger.Info("[INFO] Recorded license approval for %s\n", tool.Name)
+	mu.Lock()
+	st.Approve(tool.Name, tool.LicenseURL, time.Now().UTC())
+	mu.Unlock()
+	return true
+}
+
+// Force re-applies settings marked apply_once even when state shows they were already applied.
+var Force bool
+
 // SyncSettings applies macOS user defaults settings from the config,
 // and updates the state file with applied settings to avoid redundant changes.
+//
+// Settings are applied strictly in config order, except where a setting declares
+// `after: domain:key`, in which case it is applied after that setting.
 func SyncSettings(settings []config.Setting, st *state.State) {
-	// Iterate over each desired setting from config
+	// Resolve the application order; a dependency cycle aborts the settings sync
+	settings, err := orderSettings(settings)
+	if err != nil {
+		logger.Error("[ERROR] %v. No settings were applied.\n", err)
+		return
+	}
+
+	// Apps that need a restart to pick up the changes, restarted once each at the end
+	restart := map[string]bool{}
+	defer func() { restartApps(restart) }()
+
+	// Iterate over each desired setting in application order
 	for _, s := range settings {
 		// Compose a unique key to identify each setting (domain:key)
-		key := fmt.Sprintf("%s:%s", s.Domain, s.Key)
+		key := settingKey(s)
+
+		// Disabled settings are left alone, including their recorded state
+		if !s.IsEnabled() {
+			logger.Info("[INFO] Setting %s is disabled in config. Skipping.\n", key)
+			continue
+		}
 
 		// Log the setting being considered with its value and type
 		logger.Debug("[DEBUG] Considering setting %s = %s (%s)\n", key, s.Value, s.Type)
 
-		// Check if this setting is already applied with the same value in the state file
-		if prev, ok := st.Settings[key]; ok && prev.Value == s.Value {
+		// Apply-once settings are only initialized: once recorded, they're left alone
+		// regardless of value, so later manual changes are preserved (unless forced)
+		if _, ok := st.Settings[key]; ok && s.ApplyOnce && !Force {
+			logger.Info("[INFO] Skipping apply-once setting %s (already applied; use --force to re-apply)\n", key)
+			continue
+		}
+
+		// Check if this setting is already applied with the same value in the state file;
+		// a forced apply-once setting is re-asserted even then, since the system value may have drifted
+		if prev, ok := st.Settings[key]; ok && prev.Value == s.Value && !(s.ApplyOnce && Force) {
 			// If yes, skip re-applying the setting for efficiency
 			logger.Info("[INFO] Skipping already applied setting %s = %s\n", key, s.Value)
 			continue
 		}
 
+		// Read the live value first: it is recorded as the previous value so the change can be
+		// reverted, and a write is pointless if the system already has the desired value
+		// (e.g. when state.json was lost). A missing key reads as an error and means "unset".
+		prev, ok := st.Settings[key]
+		if !ok || (prev.Previous == "" && !prev.PreviousUnset) {
+			current, err := readSetting(s)
+			if err != nil {
+				logger.Debug("[DEBUG] Setting %s is not set on the system: %v\n", key, err)
+			}
+			prev = state.SettingState{Previous: current, PreviousUnset: err != nil}
+			if err == nil && settingMatches(s, current) && !(s.ApplyOnce && Force) {
+				logger.Info("[INFO] Setting %s already has value %s on the system; recording it\n", key, current)
+				if !DryRun {
+					st.Settings[key] = state.SettingState{Domain: s.Domain, Key: s.Key, Value: s.Value, Type: s.Type, Previous: current, CurrentHost: s.CurrentHost, Sudo: s.Sudo}
+				}
+				continue
+			}
+		}
+
 		// Build the arguments for the `defaults write` command based on setting type
-		args := []string{"write", s.Domain, s.Key}
-		switch s.Type {
-		case "bool":
-			args = append(args, "-bool", s.Value)
-		case "int":
-			args = append(args, "-int", s.Value)
-		case "float":
-			args = append(args, "-float", s.Value)
-		default:
-			// Default to string type if none of the above
-			args = append(args, "-string", s.Value)
+		args := defaultsWriteArgs(s)
+
+		if DryRun {
+			logger.Info("[DRY-RUN] Would run: %s\n", defaultsCommandLine(s.Sudo, args))
+			addRestart(restart, s.Restart, s.Domain)
+			continue
+		}
+
+		if !confirm(ConfirmSettings, fmt.Sprintf("Apply setting %s = %s?", key, s.Value), true) {
+			logger.Info("[INFO] Skipping setting %s (not confirmed)\n", key)
+			continue
 		}
 
 		// Execute the defaults command with constructed arguments
-		cmd := exec.Command("defaults", args...)
-		output, err := cmd.CombinedOutput()
+		output, err := runDefaults(s.Sudo, args...)
 		if err != nil {
 			// Log error if the setting application failed along with command output
 			logger.Error("[ERROR] Failed to apply setting %s: %v\nOutput: %s\n", key, err, output)
 			continue
 		}
 
+		// Read the value back: managed (MDM) or sandboxed preferences can silently revert a write
+		actual, err := readSetting(s)
+		if err != nil {
+			logger.Warn("[WARN] Setting %s could not be read back after writing: %v. Not recording it.\n", key, err)
+			continue
+		}
+		if !settingMatches(s, actual) {
+			logger.Warn("[WARN] Setting %s did not take effect (wanted %s, read back %s). It may be managed by the system or MDM. Not recording it.\n", key, s.Value, actual)
+			continue
+		}
+
 		// Log successful setting application
 		logger.Info("[INFO] Applied setting: %s = %s\n", key, s.Value)
+		addRestart(restart, s.Restart, s.Domain)
 
 		// Update the state file with this newly applied setting
 		st.Settings[key] = state.SettingState{
-			Domain: s.Domain,
-			Key:    s.Key,
-			Value:  s.Value,
+			Domain:        s.Domain,
+			Key:           s.Key,
+			Value:         s.Value,
+			Type:          s.Type,
+			Previous:      prev.Previous,
+			PreviousUnset: prev.PreviousUnset,
+			CurrentHost:   s.CurrentHost,
+			Sudo:          s.Sudo,
 		}
 	}
 }
 
-// SyncAliases ensures shell aliases from the config are added to the user's shell rc file.
-// It avoids duplicate entries by checking existing aliases first.
-func SyncAliases(aliases config.Aliases) {
-	// Get current user info for home directory and rc file path
-	usr, err := user.Current()
+// SyncAliases ensures shell aliases from the config are present in the user's shell rc file.
+// Everything this tool writes lives in a marked block (see aliases.go), so aliases and raw
+// config lines that were removed from the config are pruned on the next sync while lines the
+// user wrote outside the block are never touched. Applied aliases are recorded in st.Aliases.
+func SyncAliases(aliases config.Aliases, st *state.State) {
+	// Resolve the rc file that aliases should be written to
+	rcPath, err := rcFilePath(aliases)
 	if err != nil {
-		logger.Error("[ERROR] Failed to get current user: %v\n", err)
+		logger.Error("[ERROR] Failed to resolve the shell rc file: %v\n", err)
 		return
 	}
 
-	// Determine which shell to use for aliasing; default to detected shell if empty
-	shell := aliases.Shell
-	if shell == "" {
-		shell = detectShell()
-	}
-	logger.Debug("[DEBUG] Using shell '%s' for aliases\n", shell)
+	shell := aliasShell(aliases)
 
-	// Map supported shells to their rc file names
-	shellrcMap := map[string]string{
-		"zsh":  ".zshrc",
-		"bash": ".bashrc",
-	}
-	shellrc, ok := shellrcMap[shell]
-	if !ok {
-		// If shell unknown, warn and default to .zshrc
-		logger.Warn("[WARN] Unknown shell '%s', defaulting to '.zshrc'\n", shell)
-		shellrc = ".zshrc"
+	// Split the rc file into the user's own lines and the block managed by this tool
+	rc, err := readRCFile(rcPath)
+	if err != nil {
+		logger.Error("[ERROR] %v\n", err)
+		return
 	}
-	// Construct full path to shell rc file
-	rcPath := filepath.Join(usr.HomeDir, shellrc)
 
-	// Read existing lines from the rc file to avoid duplicates
-	existing := make(map[string]bool)
-	if f, err := os.Open(rcPath); err == nil {
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			existing[line] = true
+	// Build the desired block contents, taking over alias lines written before blocks existed
+	block, applied, adopted := planAliasBlock(aliases, shell, &rc)
+	had := make(map[string]bool, len(rc.block))
+	for _, line := range adopted {
+		if DryRun {
+			logger.Info("[DRY-RUN] Would move into the managed block of %s: %s\n", rcPath, line)
+		} else {
+			logger.Info("[INFO] Moved into the managed block of %s: %s\n", rcPath, line)
 		}
-		_ = f.Close()
+		had[line] = true
 	}
 
-	// Open rc file for appending new aliases
-	file, err := os.OpenFile(rcPath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		logger.Error("[ERROR] Unable to open file %s for appending: %v\n", rcPath, err)
-		return
+	// Report what changes: new lines are added, lines dropped from the config are pruned
+	wanted := make(map[string]bool, len(block))
+	for _, line := range block {
+		wanted[line] = true
 	}
-	defer file.Close()
-
-	// Write raw configs if provided
-	for _, raw := range aliases.RawConfigs {
-		lines := strings.Split(raw, "\n")
-		for _, line := range lines {
-			trimmed := strings.TrimSpace(line)
-			if trimmed == "" || existing[trimmed] {
-				logger.Debug("[DEBUG] Raw config already exists or is empty: %s\n", trimmed)
-				continue
+	for _, line := range rc.block {
+		had[strings.TrimSpace(line)] = true
+	}
+	for _, line := range block {
+		if !had[line] {
+			if DryRun {
+				logger.Info("[DRY-RUN] Would add to %s: %s\n", rcPath, line)
+			} else {
+				logger.Info("[INFO] Added to %s: %s\n", rcPath, line)
 			}
-			if _, err := file.WriteString(trimmed + "\n"); err != nil {
-				logger.Error("[ERROR] Failed to write raw config line: %s: %v\n", trimmed, err)
+		}
+	}
+	for _, line := range rc.block {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !wanted[trimmed] {
+			if DryRun {
+				logger.Info("[DRY-RUN] Would remove stale line from %s: %s\n", rcPath, trimmed)
 			} else {
-				logger.Info("[INFO] Added raw shell config: %s\n", trimmed)
-				existing[trimmed] = true
+				logger.Info("[INFO] Removed stale line from %s: %s\n", rcPath, trimmed)
 			}
 		}
 	}
 
-	// Iterate over all aliases defined in config
-	for _, a := range aliases.Entries {
-		// Format alias command string e.g. alias gs="git status"
-		aliasCmd := fmt.Sprintf("alias %s=\"%s\"", a.Name, a.Value)
+	if equalLines(rc.block, block) {
+		logger.Debug("[DEBUG] Managed alias block in %s is up to date\n", rcPath)
+		if !DryRun {
+			st.Aliases = applied
+		}
+		return
+	}
+	if DryRun {
+		return
+	}
 
-		// Skip if alias already exists in rc file
-		if existing[aliasCmd] {
-			logger.Debug("[DEBUG] Alias already exists: %s\n", aliasCmd)
-			continue
+	// The rc file may live in a directory that doesn't exist yet (~/.config/fish)
+	if err := os.MkdirAll(filepath.Dir(rcPath), 0755); err != nil {
+		logger.Error("[ERROR] Unable to create directory for %s: %v\n", rcPath, err)
+		return
+	}
+	if err := os.WriteFile(rcPath, rc.render(block), 0644); err != nil {
+		logger.Error("[ERROR] Unable to write %s: %v\n", rcPath, err)
+		return
+	}
+	logger.Info("[INFO] Updated managed alias block in %s\n", rcPath)
+	st.Aliases = applied
+}
+
+// planAliasBlock works out the contents of the managed alias block: raw configs first, then
+// aliases, in config order, leaving out lines the user already has outside the block. It
+// returns the block, the aliases it applies, and the lines adopted from outside it.
+// The first sync that writes a block takes over the alias lines older versions appended to
+// the file (see rcFile.adopt), so they can be pruned later. Only lines matching a configured
+// alias are moved; raw config lines such as "fi" could just as well be the user's own.
+func planAliasBlock(aliases config.Aliases, shell string, rc *rcFile) (block []string, applied map[string]string, adopted []string) {
+	if !rc.exists {
+		configured := make(map[string]bool, len(aliases.Entries))
+		for _, a := range aliases.Entries {
+			configured[aliasLine(shell, a)] = true
 		}
+		adopted = rc.adopt(configured)
+	}
 
-		// Write new alias line to rc file
-		if _, err := file.WriteString(aliasCmd + "\n"); err != nil {
-			// Log failure to write alias
-			logger.Error("[ERROR] Failed to write alias '%s': %v\n", aliasCmd, err)
-		} else {
-			// Log successful alias addition
-			logger.Info("[INFO] Added alias: %s\n", aliasCmd)
-			existing[aliasCmd] = true
+	// Lines the user already has outside the block are not duplicated into it
+	userLines := make(map[string]bool)
+	for _, line := range append(append([]string(nil), rc.before...), rc.after...) {
+		userLines[strings.TrimSpace(line)] = true
+	}
+
+	inBlock := make(map[string]bool)
+	add := func(line string) bool {
+		if line == "" || inBlock[line] {
+			return line != ""
+		}
+		if userLines[line] {
+			logger.Debug("[DEBUG] Already defined outside the managed block: %s\n", line)
+			return false
 		}
+		inBlock[line] = true
+		block = append(block, line)
+		return true
+	}
+	for _, raw := range aliases.RawConfigs {
+		for _, line := range strings.Split(raw, "\n") {
+			add(strings.TrimSpace(line))
+		}
+	}
+	applied = make(map[string]string)
+	for _, a := range aliases.Entries {
+		// Format alias command string e.g. alias gs="git status" (alias gs 'git status' for fish)
+		if add(aliasLine(shell, a)) {
+			applied[a.Name] = a.Value
+		}
+	}
+	return block, applied, adopted
+}
+
+// aliasShell returns the shell aliases are written for: the configured one, else the detected one.
+func aliasShell(aliases config.Aliases) string {
+	if aliases.Shell != "" {
+		return aliases.Shell
+	}
+	return detectShell()
+}
+
+// aliasLine formats an alias definition in the syntax of the given shell.
+// Fish doesn't accept the POSIX name="value" form and uses `alias name 'value'` instead.
+func aliasLine(shell string, a config.Alias) string {
+	if shell == "fish" {
+		return fmt.Sprintf("alias %s '%s'", a.Name, strings.ReplaceAll(a.Value, "'", `\'`))
+	}
+	return fmt.Sprintf("alias %s=\"%s\"", a.Name, a.Value)
+}
+
+// rcFilePath returns the absolute path of the shell rc file that aliases are written to.
+// It uses the shell from config, falling back to the detected shell, and defaults to .zshrc
+// for unknown shells.
+func rcFilePath(aliases config.Aliases) (string, error) {
+	// $HOME wins over the passwd entry, as it does for the shell reading the rc file
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Determine which shell to use for aliasing; default to detected shell if empty
+	shell := aliasShell(aliases)
+	logger.Debug("[DEBUG] Using shell '%s' for aliases\n", shell)
+
+	// Map supported shells to their rc file names
+	shellrcMap := map[string]string{
+		"zsh":  ".zshrc",
+		"bash": ".bashrc",
+		"fish": filepath.Join(".config", "fish", "config.fish"),
+	}
+	shellrc, ok := shellrcMap[shell]
+	if !ok {
+		// If shell unknown, warn and default to .zshrc
+		logger.Warn("[WARN] Unknown shell '%s', defaulting to '.zshrc'\n", shell)
+		shellrc = ".zshrc"
 	}
+	// Construct full path to shell rc file
+	return filepath.Join(home, shellrc), nil
 }
 
 // detectShell attempts to identify the current user's shell by inspecting the SHELL env variable.
-// Returns "zsh" or "bash" or defaults to "zsh" if unknown.
+// Returns "zsh", "bash", or "fish", or defaults to "zsh" if unknown.
 func detectShell() string {
 	shell := os.Getenv("SHELL")
 	logger.Debug("[DEBUG] Detected shell environment: %s\n", shell)
 
-	// Match common shell strings to either zsh or bash
+	// Match common shell strings to zsh, bash, or fish
 	if strings.Contains(shell, "zsh") {
 		return "zsh"
 	} else if strings.Contains(shell, "bash") {
 		return "bash"
+	} else if strings.Contains(shell, "fish") {
+		return "fish"
 	}
 	// Default fallback
 	return "zsh"
 }
 
+// UninstallTool removes a single tool recorded in st, whether or not it is still in the config,
+// and deletes its state entry on success. It fails if setup-machine has no record of the tool.
+func UninstallTool(name string, st *state.State) error {
+	toolState, ok := st.Tools[name]
+	if !ok {
+		return fmt.Errorf("%s is not in the state file; only tools installed by setup-machine can be uninstalled", name)
+	}
+	if !toolState.InstalledByDevSetup {
+		return fmt.Errorf("%s was not installed by setup-machine; refusing to uninstall it, remove it manually", name)
+	}
+	if DryRun {
+		logger.Info("[DRY-RUN] Would uninstall %s@%s (%s)\n", name, toolState.Version, strings.Join(uninstallStrategies(toolState), ", then "))
+		return nil
+	}
+	if !uninstallTool(name, toolState) {
+		return fmt.Errorf("failed to uninstall %s completely; manual cleanup may be required", name)
+	}
+	delete(st.Tools, name)
+	return nil
+}
+
 // uninstallTool attempts to remove a tool based on the information provided in toolState.
-// It supports direct file removal, macOS pkgutil package forgetting, and glob-based matching.
+// It supports direct file removal, macOS pkgutil package forgetting, and, when enabled, sudo removal.
 func uninstallTool(name string, toolState state.ToolState) bool {
 	logger.Info("[INFO] Uninstalling %s...\n", name)
 
-	// First, attempt to remove the tool using the exact install path from state
-	if toolState.InstallPath != "" {
-		logger.Debug("[DEBUG] Attempting to remove %s\n", toolState.InstallPath)
-
-		// Try removing the file at the install path
-		if err := os.Remove(toolState.InstallPath); err == nil {
-			logger.Info("[INFO] Successfully removed binary %s\n", toolState.InstallPath)
-			return true
-		}
+	// Managed config files belong to the tool and go with it
+	for path := range toolState.Files {
+		removeManagedFile(path, logger.WithPrefix(name))
+	}
 
-		// If removal failed, try removing as a directory (useful for tools installed as folders)
-		if err := os.RemoveAll(toolState.InstallPath); err == nil {
-			logger.Info("[INFO] Successfully removed directory %s\n", toolState.InstallPath)
-			return true
+	// Tools installed behind a launcher also own an artifact directory
+	if toolState.ArtifactDir != "" {
+		if err := os.RemoveAll(toolState.ArtifactDir); err != nil {
+			logger.Warn("[WARN] Failed to remove artifact directory %s: %v\n", toolState.ArtifactDir, err)
+		} else {
+			logger.Info("[INFO] Removed artifact directory %s\n", toolState.ArtifactDir)
 		}
 	}
 
-	// Attempt to uninstall the tool via macOS pkgutil
-	logger.Info("[INFO] Trying to uninstall %s as macOS .pkg...\n", name)
-	pkgutilCmd := exec.Command("pkgutil", "--pkgs")
-	output, err := pkgutilCmd.CombinedOutput()
-	if err != nil {
-		logger.Error("[ERROR] Failed to query pkgutil: %v\nOutput: %s\n", err, output)
-	} else {
-		// Iterate through the list of installed packages
-		for _, line := range strings.Split(string(output), "\n") {
-			// If the package name contains our tool name
-			if strings.Contains(line, name) {
-				forgetCmd := exec.Command("sudo", "pkgutil", "--forget", line)
-				logger.Debug("[DEBUG] Running pkgutil forget: %s\n", strings.Join(forgetCmd.Args, " "))
+	// Try each applicable strategy in order until one succeeds
+	for _, strategy := range uninstallStrategies(toolState) {
+		switch strategy {
+		case strategyBrew:
+			// Tools installed through Homebrew are removed through Homebrew
+			if err := uninstallFromBrew(name, toolState.Cask); err == nil {
+				logger.Info("[INFO] Successfully uninstalled %s via Homebrew\n", name)
+				return true
+			} else {
+				logger.Error("[ERROR] %v\n", err)
+			}
+
+		case strategyNpm:
+			// Global npm packages are removed through npm so its links are cleaned up too
+			if err := uninstallFromNpm(name); err == nil {
+				logger.Info("[INFO] Successfully uninstalled %s via npm\n", name)
+				return true
+			} else {
+				logger.Error("[ERROR] %v\n", err)
+			}
+
+		case strategyPython:
+			// pip/pipx own the script and its environment; removing the script alone would leave them behind
+			if err := uninstallFromPython(toolState.Source, name); err == nil {
+				logger.Info("[INFO] Successfully uninstalled %s via %s\n", name, toolState.Source)
+				return true
+			} else {
+				logger.Error("[ERROR] %v\n", err)
+			}
+
+		case strategySystemPkg:
+			// apt/dnf packages are removed through the package manager that installed them
+			if err := uninstallFromSystemPackage(toolState.Source, name); err == nil {
+				logger.Info("[INFO] Successfully uninstalled %s via %s\n", name, toolState.Source)
+				return true
+			} else {
+				logger.Error("[ERROR] %v\n", err)
+			}
+
+		case strategyRemovePath:
+			// Remove the tool using the exact install path from state
+			logger.Debug("[DEBUG] Attempting to remove %s\n", toolState.InstallPath)
+
+			// Try removing the file at the install path
+			if err := os.Remove(toolState.InstallPath); err == nil {
+				logger.Info("[INFO] Successfully removed binary %s\n", toolState.InstallPath)
+				return true
+			}
+
+			// If removal failed, try removing as a directory (useful for tools installed as folders)
+			if err := os.RemoveAll(toolState.InstallPath); err == nil {
+				logger.Info("[INFO] Successfully removed directory %s\n", toolState.InstallPath)
+				return true
+			}
+
+		case strategyPkgutil:
+			// Attempt to uninstall the tool via macOS pkgutil
+			logger.Info("[INFO] Trying to uninstall %s as macOS .pkg...\n", name)
+			packages, err := matchingPackages(name)
+			if err != nil {
+				logger.Error("[ERROR] %v\n", err)
+				continue
+			}
+			for _, pkg := range packages {
+				forgetCmd := exec.Command("sudo", "pkgutil", "--forget", pkg)
+				logger.Trace("[TRACE] Running pkgutil forget: %s\n", strings.Join(forgetCmd.Args, " "))
 				out, err := forgetCmd.CombinedOutput()
 				if err == nil {
-					logger.Info("[INFO] pkgutil forget succeeded for %s\n", line)
+					logger.Info("[INFO] pkgutil forget succeeded for %s\n", pkg)
 					return true
 				} else {
 					logger.Error("[ERROR] pkgutil forget failed: %v\nOutput: %s\n", err, out)
 				}
 			}
-		}
-	}
 
-	// Fallback: use globbing to match common install paths
-	commonPaths := "/usr/local/bin/" + name + "*"
-	matches, err := filepath.Glob(commonPaths)
-	logger.Debug("[DEBUG] Globbing matches %v\n", matches)
-	if err != nil {
-		logger.Error("[ERROR] Failed to glob %s: %v\n", commonPaths, err)
-	}
+		case strategyManual:
+			// Script installs can't be undone from here; tell the user and stop tracking the tool
+			logger.Warn("[WARN] %s was installed by an install script, which setup-machine can't undo. Remove it manually (e.g. with its own uninstaller); it is no longer tracked.\n", name)
+			return true
 
-	// If any glob matches exist, try to remove them
-	if !globbingMatches(matches) {
-		logger.Debug("[DEBUG] Globbing did not yield valid matches\n")
-		logger.Error("[ERROR] Invalid or empty glob pattern %s\n", commonPaths)
-	} else {
-		return true
+		case strategySudoRemove:
+			// Last resort (only with --sudo-removal): delete the tool's exact name from
+			// /usr/local/bin with sudo, after asking, since it may not be the file we installed
+			path := sudoRemovalPath(name)
+			if _, err := os.Lstat(path); err != nil {
+				logger.Debug("[DEBUG] %s does not exist; nothing to remove with sudo\n", path)
+				continue
+			}
+			if !confirm(ConfirmUninstall, fmt.Sprintf("Remove %s with sudo rm -f?", path), false) {
+				logger.Warn("[WARN] Not removing %s (sudo removal not confirmed)\n", path)
+				continue
+			}
+			if removeWithSudo(path) {
+				return true
+			}
+		}
 	}
 
 	// If all uninstall attempts failed, return false
 	return false
 }
 
-// globbingMatches executes sudo rm on each glob match to remove the binary.
-// Returns true if any files were successfully removed.
-func globbingMatches(matches []string) bool {
-	result := false
-	for _, match := range matches {
-		logger.Info("[INFO] Removing matched binary: %s\n", match)
-
-		// Run sudo rm -f on the match
-		cmd := exec.Command("sudo", "rm", "-f", match)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			logger.Error("[ERROR] Failed to remove %s: %v\nOutput: %s\n", match, err, output)
-		} else {
-			logger.Info("[INFO] Successfully removed %s\n", match)
-			result = true
-		}
+// removeWithSudo runs sudo rm -f on path and reports whether it succeeded.
+func removeWithSudo(path string) bool {
+	logger.Info("[INFO] Removing %s with sudo\n", path)
+	output, err := runSudo(context.Background(), "rm", "-f", path)
+	if err != nil {
+		logger.Error("[ERROR] Failed to remove %s: %v\nOutput: %s\n", path, err, output)
+		return false
 	}
-	return result
+	logger.Info("[INFO] Successfully removed %s\n", path)
+	return true
 }
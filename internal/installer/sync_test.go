@@ -0,0 +1,327 @@
+package installer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/state"
+)
+
+func TestSyncToolsLeavesDisabledToolsAlone(t *testing.T) {
+	calls := fakeBrew(t, nil, "/opt/homebrew")
+	disabled := false
+	installed := state.ToolState{Version: "1.6", InstallPath: "/opt/homebrew/bin/jq", InstalledByDevSetup: true, Source: "brew"}
+	st := &state.State{Tools: map[string]state.ToolState{"jq": installed}}
+
+	SyncTools([]config.Tool{
+		{Name: "jq", Source: "brew", Version: "1.7", Enabled: &disabled},
+		{Name: "fd", Source: "brew", Version: "9.0", Enabled: &disabled},
+	}, st)
+
+	if len(*calls) != 0 {
+		t.Errorf("brew calls = %q, want none for disabled tools", *calls)
+	}
+	if got, ok := st.Tools["jq"]; !ok || got.Version != "1.6" {
+		t.Errorf("state for disabled jq = %+v (present=%v), want it untouched", got, ok)
+	}
+	if _, ok := st.Tools["fd"]; ok {
+		t.Error("disabled fd was recorded")
+	}
+}
+
+// installedBrewTool writes an executable for a brew tool under prefix and returns its state
+// entry, as a previous sync would have recorded it.
+func installedBrewTool(t *testing.T, prefix, name, version string) state.ToolState {
+	t.Helper()
+	path := filepath.Join(prefix, "bin", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return state.ToolState{Version: version, InstallPath: path, InstalledByDevSetup: true, Source: "brew", Checksum: installedChecksum(path), VerifiedAt: time.Now().UTC()}
+}
+
+func TestSyncToolsReinstallsCorruptedCriticalTools(t *testing.T) {
+	prefix := t.TempDir()
+	calls := fakeBrew(t, nil, prefix)
+	intact := installedBrewTool(t, prefix, "jq", "1.7")
+	corrupted := installedBrewTool(t, prefix, "git", "2.45")
+	if err := os.WriteFile(corrupted.InstallPath, []byte("truncated"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	st := &state.State{Tools: map[string]state.ToolState{"jq": intact, "git": corrupted}}
+
+	SyncTools([]config.Tool{
+		{Name: "jq", Source: "brew", Version: "1.7", Critical: true},
+		{Name: "git", Source: "brew", Version: "2.45", Critical: true},
+	}, st)
+
+	want := []string{"install git", "--prefix"}
+	if strings.Join(*calls, "|") != strings.Join(want, "|") {
+		t.Errorf("brew calls = %q, want %q", *calls, want)
+	}
+	if got := st.Tools["git"].Checksum; got != installedChecksum(corrupted.InstallPath) {
+		t.Errorf("git checksum = %s, want the reinstalled binary's", got)
+	}
+	if got := st.Tools["jq"].Checksum; got != intact.Checksum {
+		t.Errorf("jq checksum changed to %s", got)
+	}
+}
+
+func TestSyncToolsSkipsVerifyingNonCriticalTools(t *testing.T) {
+	prefix := t.TempDir()
+	calls := fakeBrew(t, nil, prefix)
+	ts := installedBrewTool(t, prefix, "jq", "1.7")
+	if err := os.Remove(ts.InstallPath); err != nil {
+		t.Fatal(err)
+	}
+	st := &state.State{Tools: map[string]state.ToolState{"jq": ts}}
+
+	SyncTools([]config.Tool{{Name: "jq", Source: "brew", Version: "1.7"}}, st)
+
+	if len(*calls) != 0 {
+		t.Errorf("brew calls = %q, want none without critical or --max-age", *calls)
+	}
+}
+
+// useMaxAge sets MaxAge for the duration of a test.
+func useMaxAge(t *testing.T, age time.Duration) {
+	t.Helper()
+	orig := MaxAge
+	MaxAge = age
+	t.Cleanup(func() { MaxAge = orig })
+}
+
+func TestVerificationDue(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		maxAge     time.Duration
+		verifiedAt time.Time
+		want       bool
+	}{
+		{0, time.Time{}, false},
+		{0, now.Add(-365 * 24 * time.Hour), false},
+		{24 * time.Hour, now.Add(-time.Hour), false},
+		{24 * time.Hour, now.Add(-24 * time.Hour), false},
+		{24 * time.Hour, now.Add(-25 * time.Hour), true},
+		{24 * time.Hour, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		useMaxAge(t, tt.maxAge)
+		if got := verificationDue(state.ToolState{VerifiedAt: tt.verifiedAt}, now); got != tt.want {
+			t.Errorf("verificationDue(max age %s, verified %s ago) = %v, want %v", tt.maxAge, now.Sub(tt.verifiedAt), got, tt.want)
+		}
+	}
+}
+
+func TestSyncToolsReverifiesStaleTools(t *testing.T) {
+	prefix := t.TempDir()
+	calls := fakeBrew(t, nil, prefix)
+	useMaxAge(t, 24*time.Hour)
+
+	fresh := installedBrewTool(t, prefix, "jq", "1.7")
+	stale := installedBrewTool(t, prefix, "fd", "9.0")
+	stale.VerifiedAt = time.Now().Add(-48 * time.Hour).UTC()
+	staleMissing := installedBrewTool(t, prefix, "rg", "14.1")
+	staleMissing.VerifiedAt = stale.VerifiedAt
+	if err := os.Remove(staleMissing.InstallPath); err != nil {
+		t.Fatal(err)
+	}
+	// Missing but recently verified: not looked at until it is due again
+	freshMissing := installedBrewTool(t, prefix, "bat", "0.24")
+	if err := os.Remove(freshMissing.InstallPath); err != nil {
+		t.Fatal(err)
+	}
+	st := &state.State{Tools: map[string]state.ToolState{"jq": fresh, "fd": stale, "rg": staleMissing, "bat": freshMissing}}
+
+	SyncTools([]config.Tool{
+		{Name: "jq", Source: "brew", Version: "1.7"},
+		{Name: "fd", Source: "brew", Version: "9.0"},
+		{Name: "rg", Source: "brew", Version: "14.1"},
+		{Name: "bat", Source: "brew", Version: "0.24"},
+	}, st)
+
+	want := []string{"install rg", "--prefix"}
+	if strings.Join(*calls, "|") != strings.Join(want, "|") {
+		t.Errorf("brew calls = %q, want %q", *calls, want)
+	}
+	if got := st.Tools["fd"].VerifiedAt; !got.After(stale.VerifiedAt) {
+		t.Errorf("fd VerifiedAt = %s, want it refreshed", got)
+	}
+}
+
+// fakeReleases serves v1.0.0 of any tools/<name> repository, with a .tar.gz asset holding a
+// <name> executable, and points GitHubAPIBase at it. It returns the Authorization header each
+// request was made with, by path.
+func fakeReleases(t *testing.T) func() map[string]string {
+	t.Helper()
+	var mu sync.Mutex
+	auth := map[string]string{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		if name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/repos/tools/"), "/releases/tags/v1.0.0"); ok {
+			asset := fmt.Sprintf("%s_%s_%s.tar.gz", name, runtime.GOOS, runtime.GOARCH)
+			fmt.Fprintf(w, `{"tag_name": "v1.0.0", "assets": [{"name": %q, "browser_download_url": "%s/download/%s/%s"}]}`, asset, srv.URL, name, asset)
+			return
+		}
+		name, _, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		body := "#!/bin/sh\necho " + name + "\n"
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(body)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(body))
+		tw.Close()
+		gz.Close()
+	}))
+	t.Cleanup(srv.Close)
+
+	base := GitHubAPIBase
+	GitHubAPIBase = srv.URL
+	t.Cleanup(func() { GitHubAPIBase = base })
+	return func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(auth)
+	}
+}
+
+// TestSyncToolsManyToolsConcurrently is meant to be run with -race: it installs, upgrades,
+// and keeps many tools at once across sources, with state and logging shared between them.
+func TestSyncToolsManyToolsConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	useBinDirs(t, filepath.Join(t.TempDir(), "bin"))
+	fakeReleases(t)
+	fakeInstalls(t, newInstallCounter())
+	Jobs = 16
+
+	var tools []config.Tool
+	st := &state.State{Tools: map[string]state.ToolState{}}
+	for i := range 20 {
+		name := fmt.Sprintf("gh%d", i)
+		tools = append(tools, config.Tool{Name: name, Source: "github", Repo: "tools/" + name, Version: "1.0.0"})
+		if i%4 == 0 {
+			// Outdated: upgraded in place
+			st.Tools[name] = state.ToolState{Version: "0.9.0", InstalledByDevSetup: true, Source: "github"}
+		}
+	}
+	tools = append(tools, concurrencyTools(10, 10)...)
+
+	SyncTools(tools, st)
+
+	if len(st.Tools) != len(tools) {
+		t.Errorf("state has %d tools, want %d", len(st.Tools), len(tools))
+	}
+	for _, tool := range tools {
+		if got := st.Tools[tool.Name]; got.Version != tool.Version {
+			t.Errorf("%s: version %q in state, want %s", tool.Name, got.Version, tool.Version)
+		}
+	}
+}
+
+func TestSyncToolsKeepsUnlistedToolsWhenFiltered(t *testing.T) {
+	calls := fakeBrew(t, nil, "/opt/homebrew")
+	orig := KeepUnlisted
+	KeepUnlisted = true
+	t.Cleanup(func() { KeepUnlisted = orig })
+	filteredOut := state.ToolState{Version: "9.0", InstallPath: "/opt/homebrew/bin/fd", InstalledByDevSetup: true, Source: "brew"}
+	st := &state.State{Tools: map[string]state.ToolState{"fd": filteredOut}}
+
+	SyncTools([]config.Tool{{Name: "jq", Source: "brew", Version: "1.7"}}, st)
+
+	want := []string{"install jq", "--prefix"}
+	if strings.Join(*calls, "|") != strings.Join(want, "|") {
+		t.Errorf("brew calls = %q, want %q", *calls, want)
+	}
+	if _, ok := st.Tools["fd"]; !ok {
+		t.Error("fd was dropped from state although it was only filtered out")
+	}
+}
+
+func TestSyncToolsOnlyForgetsToolsItDidNotInstall(t *testing.T) {
+	prefix := t.TempDir()
+	calls := fakeBrew(t, nil, prefix)
+	preexisting := installedBrewTool(t, prefix, "jq", "1.7")
+	preexisting.InstalledByDevSetup = false
+	st := &state.State{Tools: map[string]state.ToolState{"jq": preexisting}}
+
+	// jq was removed from the config
+	SyncTools(nil, st)
+
+	if len(*calls) != 0 {
+		t.Errorf("brew calls = %q, want no uninstall of a tool setup-machine didn't install", *calls)
+	}
+	if _, err := os.Stat(preexisting.InstallPath); err != nil {
+		t.Errorf("jq was removed from disk: %v", err)
+	}
+	if _, ok := st.Tools["jq"]; ok {
+		t.Error("jq is still tracked after being removed from the config")
+	}
+}
+
+func TestUninstallToolRefusesToolsItDidNotInstall(t *testing.T) {
+	prefix := t.TempDir()
+	preexisting := installedBrewTool(t, prefix, "jq", "1.7")
+	preexisting.InstalledByDevSetup = false
+	st := &state.State{Tools: map[string]state.ToolState{"jq": preexisting}}
+
+	err := UninstallTool("jq", st)
+	if err == nil || !strings.Contains(err.Error(), "not installed by setup-machine") {
+		t.Errorf("UninstallTool error = %v, want a refusal", err)
+	}
+	if _, err := os.Stat(preexisting.InstallPath); err != nil {
+		t.Errorf("jq was removed from disk: %v", err)
+	}
+	if _, ok := st.Tools["jq"]; !ok {
+		t.Error("jq was dropped from state although it was not uninstalled")
+	}
+}
+
+func TestSyncToolsKeepsAdHocToolsWithBadSpecs(t *testing.T) {
+	yes := AssumeYes
+	t.Cleanup(func() { AssumeYes = yes })
+	AssumeYes = true
+
+	dir := t.TempDir()
+	st := &state.State{Tools: map[string]state.ToolState{}, Settings: map[string]state.SettingState{}}
+	for name, spec := range map[string]string{"adhoc": "nosuchsource:adhoc@1.0.0", "orphan": ""} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		st.Tools[name] = state.ToolState{Version: "1.0.0", InstallPath: path, InstalledByDevSetup: true, Source: "github", Spec: spec}
+	}
+
+	SyncTools(nil, st)
+
+	// The tool added with install is kept even though its spec can't be synced; the orphan goes
+	if _, ok := st.Tools["adhoc"]; !ok {
+		t.Error("adhoc was dropped from state")
+	}
+	if _, ok := st.Tools["orphan"]; ok {
+		t.Error("orphan is still in state")
+	}
+	if got := remaining(t, dir); got != "adhoc" {
+		t.Errorf("files left = %s, want adhoc", got)
+	}
+}
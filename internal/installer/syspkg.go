@@ -0,0 +1,74 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// runSudo executes a command as root with sudo and returns its combined output.
+// Swapped out in tests so package installs can be checked without root.
+var runSudo = func(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "sudo", args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	return cmd.CombinedOutput()
+}
+
+// installFromSystemPackage installs a distribution package with apt-get or dnf (Linux only),
+// pinned to tool.Version when set, and returns the path of the executable named after the
+// tool as found on PATH. Packages that don't ship such an executable have no install path.
+func installFromSystemPackage(ctx context.Context, tool config.Tool, log *logger.Logger) (string, error) {
+	args := systemInstallArgs(tool)
+	output, err := runSudo(ctx, args...)
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+
+	// The equivalent of `command -v <name>`
+	installPath, err := exec.LookPath(filepath.Base(tool.Name))
+	if err != nil {
+		log.Warn("[WARN] Package %s installed, but no %s executable is on PATH; its install path is not tracked\n", tool.Name, filepath.Base(tool.Name))
+		return "", nil
+	}
+	return installPath, nil
+}
+
+// systemInstallArgs returns the command that installs a tool's package, pinned when a version
+// is set. apt pins with name=version and dnf with name-version.
+func systemInstallArgs(tool config.Tool) []string {
+	pkg := tool.Name
+	switch tool.Source {
+	case "apt":
+		if tool.Version != "" {
+			pkg += "=" + tool.Version
+		}
+		return []string{"apt-get", "install", "-y", pkg}
+	default:
+		if tool.Version != "" {
+			pkg += "-" + tool.Version
+		}
+		return []string{"dnf", "install", "-y", pkg}
+	}
+}
+
+// systemUninstallArgs returns the command that removes a package installed with source.
+func systemUninstallArgs(source, name string) []string {
+	if source == "apt" {
+		return []string{"apt-get", "remove", "-y", name}
+	}
+	return []string{"dnf", "remove", "-y", name}
+}
+
+// uninstallFromSystemPackage removes a package previously installed with the apt or dnf source.
+func uninstallFromSystemPackage(source, name string) error {
+	args := systemUninstallArgs(source, name)
+	output, err := runSudo(context.Background(), args...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v\nOutput: %s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+)
+
+// fakeSudo replaces runSudo with a runner that records each call's arguments.
+func fakeSudo(t *testing.T) *[]string {
+	t.Helper()
+	orig := runSudo
+	t.Cleanup(func() { runSudo = orig })
+
+	var calls []string
+	runSudo = func(ctx context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return nil, nil
+	}
+	return &calls
+}
+
+func TestInstallFromSystemPackage(t *testing.T) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "jq"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	tests := []struct {
+		tool     config.Tool
+		wantArgs string
+		wantPath string
+	}{
+		{config.Tool{Name: "jq", Source: "apt", Version: "1.6-2.1"}, "apt-get install -y jq=1.6-2.1", filepath.Join(bin, "jq")},
+		{config.Tool{Name: "jq", Source: "apt"}, "apt-get install -y jq", filepath.Join(bin, "jq")},
+		{config.Tool{Name: "jq", Source: "dnf", Version: "1.7.1"}, "dnf install -y jq-1.7.1", filepath.Join(bin, "jq")},
+		// Library packages ship no executable of their own name; they install but aren't tracked
+		{config.Tool{Name: "libyaml", Source: "dnf"}, "dnf install -y libyaml", ""},
+	}
+	for _, tt := range tests {
+		calls := fakeSudo(t)
+		path, err := installFromSystemPackage(context.Background(), tt.tool, &logger.Logger{})
+		if err != nil {
+			t.Fatalf("installFromSystemPackage(%s): %v", tt.wantArgs, err)
+		}
+		if len(*calls) != 1 || (*calls)[0] != tt.wantArgs {
+			t.Errorf("sudo calls = %q, want [%q]", *calls, tt.wantArgs)
+		}
+		if path != tt.wantPath {
+			t.Errorf("install path for %q = %q, want %q", tt.wantArgs, path, tt.wantPath)
+		}
+	}
+}
+
+func TestUninstallFromSystemPackage(t *testing.T) {
+	for source, want := range map[string]string{"apt": "apt-get remove -y jq", "dnf": "dnf remove -y jq"} {
+		calls := fakeSudo(t)
+		if err := uninstallFromSystemPackage(source, "jq"); err != nil {
+			t.Fatal(err)
+		}
+		if len(*calls) != 1 || (*calls)[0] != want {
+			t.Errorf("%s: sudo calls = %q, want [%q]", source, *calls, want)
+		}
+	}
+}
@@ -2,14 +2,8 @@ package installer
 
 import (
 	"math/rand" // Package rand implements pseudo-random number generators
-	"time"      // Package time provides functionality for measuring and displaying time
 )
 
-// rnd is a package-level variable holding a pseudo-random number generator (PRNG) instance.
-// This is initialized once with a seed based on the current time in nanoseconds,
-// which helps ensure that the generated random sequences differ between program runs.
-var rnd *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 // RandomString generates a random alphanumeric string of specified length n.
 // The generated string includes uppercase letters, lowercase letters, and digits.
 // This function can be used for generating random IDs, tokens, or temporary values.
@@ -23,8 +17,10 @@ func RandomString(n int) string {
 
 	// Loop over each index of the slice and assign a random character from 'letters'.
 	for i := range b {
-		// rnd.Intn(len(letters)) returns a random index within the letters slice.
-		b[i] = letters[rnd.Intn(len(letters))]
+		// rand.Intn(len(letters)) returns a random index within the letters slice.
+		// The package-level generator is used because it is safe for concurrent use (a
+		// shared *rand.Rand is not) and is seeded randomly at startup.
+		b[i] = letters[rand.Intn(len(letters))]
 	}
 
 	// Convert the slice of runes back to a string and return it.
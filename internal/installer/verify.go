@@ -0,0 +1,98 @@
+package installer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"setup-machine/internal/config"
+	"setup-machine/internal/logger"
+	"setup-machine/internal/state"
+	"strings"
+	"time"
+)
+
+// MaxAge is how long a verified tool is trusted before it is re-verified (existence and
+// checksum) on the next sync. Zero disables periodic re-verification.
+var MaxAge time.Duration
+
+// verificationDue reports whether a tool's last verification is older than MaxAge.
+// Tools that have never been verified are due as soon as MaxAge is enabled.
+func verificationDue(ts state.ToolState, now time.Time) bool {
+	if MaxAge <= 0 {
+		return false
+	}
+	return now.Sub(ts.VerifiedAt) > MaxAge
+}
+
+// fileSHA256 returns the hex-encoded SHA256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// installedChecksum computes the checksum to record for an install path.
+// Directories and unreadable paths (e.g. .pkg installs recorded as /Applications) yield "".
+func installedChecksum(installPath string) string {
+	info, err := os.Stat(installPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	sum, err := fileSHA256(installPath)
+	if err != nil {
+		logger.Debug("[DEBUG] Unable to checksum %s: %v\n", installPath, err)
+		return ""
+	}
+	return sum
+}
+
+// verifyInstalled checks that a recorded tool is still intact: the install path must exist
+// and, when a checksum was recorded, the file contents must still match it.
+func verifyInstalled(ts state.ToolState) error {
+	if ts.InstallPath == "" {
+		return fmt.Errorf("no install path recorded")
+	}
+	if _, err := os.Stat(ts.InstallPath); err != nil {
+		return fmt.Errorf("%s is missing: %w", ts.InstallPath, err)
+	}
+	if ts.Checksum == "" {
+		return nil
+	}
+	sum, err := fileSHA256(ts.InstallPath)
+	if err != nil {
+		return fmt.Errorf("cannot checksum %s: %w", ts.InstallPath, err)
+	}
+	if sum != ts.Checksum {
+		return fmt.Errorf("%s checksum mismatch (expected %s, got %s)", ts.InstallPath, ts.Checksum, sum)
+	}
+	return nil
+}
+
+// warnOnVersionMismatch runs `<binary> --version` for a verified tool and warns when the
+// output doesn't mention the recorded version. Version output formats vary widely between
+// tools, so this is advisory only and never triggers a reinstall.
+func warnOnVersionMismatch(tool config.Tool, ts state.ToolState) {
+	if ts.Checksum == "" || tool.Version == "" {
+		return
+	}
+	logger.Trace("[TRACE] Running command: %s --version\n", ts.InstallPath)
+	output, err := exec.Command(ts.InstallPath, "--version").CombinedOutput()
+	if err != nil {
+		logger.Debug("[DEBUG] Version check for %s skipped: %v\n", tool.Name, err)
+		return
+	}
+	if !strings.Contains(string(output), tool.Version) {
+		logger.Warn("[WARN] %s --version does not report %s: %s\n", tool.Name, tool.Version, strings.TrimSpace(string(output)))
+	}
+}
@@ -1,41 +1,295 @@
 package logger
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/fatih/color" // Import the fatih/color package for colored console output
+	"io"
+	"os"
+	"strings"
+	"sync"
 )
 
 // Define colorized printing functions for different log levels using fatih/color.
 // These are package-level variables holding functions that behave like fmt.Printf,
-// but with text colored appropriately for the log level.
+// but with text colored appropriately for the log level (or as JSON, see Format).
+// They are assigned during Init based on the log level and format.
 
 // Info logs informational messages in green color.
 // Green is typically used for success or normal info to catch user attention pleasantly.
-var Info = color.New(color.FgGreen).PrintfFunc()
+var Info func(format string, a ...any)
 
 // Warn logs warning messages in bright magenta color.
 // Magenta is bright and stands out, signaling caution without being too alarming.
-var Warn = color.New(color.FgHiMagenta).PrintfFunc()
+var Warn func(format string, a ...any)
 
 // Error logs error messages in red color.
 // Red is commonly associated with errors or critical problems to draw immediate attention.
-var Error = color.New(color.FgRed).PrintfFunc()
+var Error func(format string, a ...any)
 
 // Debug logs debug messages in cyan color if enabled, otherwise is a no-op.
-// This is a function variable that is assigned dynamically during Init based on debug flag.
 // When debug logging is disabled, Debug is assigned to an empty function that does nothing.
 var Debug func(format string, a ...any)
 
-// Init initializes the logger package, specifically enabling or disabling debug logging.
-// Parameters:
-// - enableDebug: boolean flag to turn debug messages on or off.
-// When enabled, Debug will print messages in cyan color.
-// When disabled, Debug will be a no-op function that silently ignores debug logs.
-func Init(enableDebug bool) {
-	if enableDebug {
-		// Assign Debug to print cyan-colored debug messages.
-		Debug = color.New(color.FgCyan).PrintfFunc()
-	} else {
-		// Assign Debug to a no-op function that ignores all debug logs to avoid runtime overhead.
-		Debug = func(format string, a ...any) {}
+// Trace logs the most verbose messages, such as full command lines and HTTP request details,
+// in blue color if enabled, otherwise is a no-op.
+var Trace func(format string, a ...any)
+
+// Level controls which messages are printed. Each level includes all levels before it,
+// so e.g. LevelWarn prints warnings and errors only.
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelWarn
+	LevelInfo
+	LevelDebug
+	LevelTrace
+)
+
+// levelNames maps the names accepted by ParseLevel to their levels.
+var levelNames = map[string]Level{
+	"error": LevelError,
+	"warn":  LevelWarn,
+	"info":  LevelInfo,
+	"debug": LevelDebug,
+	"trace": LevelTrace,
+}
+
+// ParseLevel converts a level name (error, warn, info, debug, trace) into a Level.
+func ParseLevel(name string) (Level, error) {
+	level, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return LevelInfo, fmt.Errorf("unknown log level %q (want error, warn, info, debug, or trace)", name)
+	}
+	return level, nil
+}
+
+// Format selects how messages are written: colored text for people, or one JSON object per
+// line for log aggregators.
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+// formatNames maps the names accepted by ParseFormat to their formats.
+var formatNames = map[string]Format{
+	"text": FormatText,
+	"json": FormatJSON,
+}
+
+// ParseFormat converts a format name (text, json) into a Format.
+func ParseFormat(name string) (Format, error) {
+	format, ok := formatNames[strings.ToLower(name)]
+	if !ok {
+		return FormatText, fmt.Errorf("unknown log format %q (want text or json)", name)
+	}
+	return format, nil
+}
+
+// ColorMode selects whether the text format is colored: only when stdout is a terminal (auto,
+// the default), always, or never.
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+// colorModeNames maps the names accepted by ParseColorMode to their modes.
+var colorModeNames = map[string]ColorMode{
+	"auto":   ColorAuto,
+	"always": ColorAlways,
+	"never":  ColorNever,
+}
+
+// ParseColorMode converts a color mode name (auto, always, never) into a ColorMode.
+func ParseColorMode(name string) (ColorMode, error) {
+	mode, ok := colorModeNames[strings.ToLower(name)]
+	if !ok {
+		return ColorAuto, fmt.Errorf("unknown color mode %q (want auto, always, or never)", name)
+	}
+	return mode, nil
+}
+
+// autoNoColor is fatih/color's own verdict, taken before Init can change it: no color when
+// stdout is not a terminal (pipes, files, CI logs), NO_COLOR is set, or TERM is dumb.
+var autoNoColor = color.NoColor
+
+// printFunc writes one message of a level, tagged with the scope (tool) it belongs to, if any.
+type printFunc func(scope, format string, a ...any)
+
+// scoped holds the output function of each level, as set up by Init. Disabled levels are no-ops.
+var scoped [LevelTrace + 1]printFunc
+
+// levelColors are the colors of the text format, per level.
+var levelColors = [LevelTrace + 1]*color.Color{
+	LevelError: color.New(color.FgRed),
+	LevelWarn:  color.New(color.FgHiMagenta),
+	LevelInfo:  color.New(color.FgGreen),
+	LevelDebug: color.New(color.FgCyan),
+	LevelTrace: color.New(color.FgBlue),
+}
+
+// Messages logged before Init (e.g. while flags are parsed) use the defaults.
+func init() {
+	Init(LevelInfo, FormatText, ColorAuto)
+}
+
+// Init initializes the logger package for the given level, format, and color mode.
+// Messages above the level are replaced by no-op functions that silently ignore them,
+// so disabled levels have no runtime overhead. Errors are always printed.
+func Init(level Level, format Format, colors ColorMode) {
+	switch colors {
+	case ColorAlways:
+		color.NoColor = false
+	case ColorNever:
+		color.NoColor = true
+	default:
+		color.NoColor = autoNoColor
+	}
+
+	for l := LevelError; l <= LevelTrace; l++ {
+		switch {
+		case l > level && l != LevelError:
+			scoped[l] = func(scope, format string, a ...any) {}
+		case format == FormatJSON:
+			scoped[l] = jsonPrinter(l)
+		default:
+			scoped[l] = printer(levelColors[l])
+		}
+	}
+	Error = unscoped(scoped[LevelError])
+	Warn = unscoped(scoped[LevelWarn])
+	Info = unscoped(scoped[LevelInfo])
+	Debug = unscoped(scoped[LevelDebug])
+	Trace = unscoped(scoped[LevelTrace])
+}
+
+// unscoped adapts a printFunc to the Printf-like signature of the package-level functions.
+func unscoped(print printFunc) func(format string, a ...any) {
+	return func(format string, a ...any) { print("", format, a...) }
+}
+
+// outputMu serializes log output. Tools are synced concurrently, and a message written in
+// pieces (color code, text, reset code) could otherwise interleave with another goroutine's.
+var outputMu sync.Mutex
+
+// toStderr sends log output to stderr instead of stdout. It is set by UseStderr and guarded
+// by outputMu.
+var toStderr bool
+
+// UseStderr sends all further log output to stderr. Commands whose stdout is their result
+// (a generated script, a JSON document) call it so the result can be redirected or parsed
+// without log lines mixed in.
+func UseStderr() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	toStderr = true
+}
+
+// textOutput returns where text messages are written. outputMu must be held.
+func textOutput() io.Writer {
+	if toStderr {
+		return color.Error
+	}
+	return color.Output
+}
+
+// Prompt writes question and reads the answer with read while holding the output lock, so
+// messages from tools being synced concurrently wait until the question is answered instead
+// of landing between the question and the user's input.
+func Prompt(question string, read func() (string, error)) (string, error) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprint(textOutput(), question)
+	return read()
+}
+
+// printer returns a printFunc that renders a message, prefixed with "[scope] ", in color c
+// and writes it to the terminal in a single call under outputMu.
+func printer(c *color.Color) printFunc {
+	return func(scope, format string, a ...any) {
+		msg := fmt.Sprintf(format, a...)
+		if scope != "" {
+			msg = "[" + scope + "] " + msg
+		}
+		msg = c.Sprint(msg)
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		fmt.Fprint(textOutput(), msg)
 	}
 }
+
+// jsonEntry is a message in the JSON format.
+type jsonEntry struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	Tool  string `json:"tool,omitempty"`
+}
+
+// levelTags are the tags messages start with; in JSON the level is a field of its own.
+var levelTags = []string{"[ERROR] ", "[WARN] ", "[INFO] ", "[DEBUG] ", "[TRACE] "}
+
+// jsonPrinter returns a printFunc that writes each message as a single-line JSON object with
+// its level and tool, without the "[INFO] "-style tag and surrounding whitespace.
+func jsonPrinter(level Level) printFunc {
+	var name string
+	for n, l := range levelNames {
+		if l == level {
+			name = n
+		}
+	}
+	return func(scope, format string, a ...any) {
+		msg := strings.TrimSpace(fmt.Sprintf(format, a...))
+		for _, tag := range levelTags {
+			if strings.HasPrefix(msg, tag) {
+				msg = strings.TrimPrefix(msg, tag)
+				break
+			}
+		}
+		data, err := json.Marshal(jsonEntry{Level: name, Msg: msg, Tool: scope})
+		if err != nil {
+			return
+		}
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		out := os.Stdout
+		if toStderr {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, string(data))
+	}
+}
+
+// Logger is a scoped logger that tags every message with a fixed prefix, e.g. "[jq]".
+// It is passed down the install path of a single tool so that, when several tools are
+// processed concurrently, their interleaved output can still be told apart.
+// It writes through the package-level functions and so honors the same settings.
+type Logger struct {
+	scope string
+}
+
+// WithPrefix returns a Logger that prepends "[scope] " to every message; in the JSON format
+// the scope is the message's "tool" field instead.
+func WithPrefix(scope string) *Logger {
+	return &Logger{scope: scope}
+}
+
+// Info logs an informational message with the logger's prefix.
+func (l *Logger) Info(format string, a ...any) { scoped[LevelInfo](l.scope, format, a...) }
+
+// Warn logs a warning message with the logger's prefix.
+func (l *Logger) Warn(format string, a ...any) { scoped[LevelWarn](l.scope, format, a...) }
+
+// Error logs an error message with the logger's prefix.
+func (l *Logger) Error(format string, a ...any) { scoped[LevelError](l.scope, format, a...) }
+
+// Debug logs a debug message with the logger's prefix, if debug logging is enabled.
+func (l *Logger) Debug(format string, a ...any) { scoped[LevelDebug](l.scope, format, a...) }
+
+// Trace logs a trace message with the logger's prefix, if trace logging is enabled.
+func (l *Logger) Trace(format string, a ...any) { scoped[LevelTrace](l.scope, format, a...) }
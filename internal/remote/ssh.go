@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"setup-machine/internal/logger"
+	"strings"
+)
+
+// remoteDir is the working directory on the remote machine, relative to the remote user's home.
+// The binary, the config files and the state file all live here, so state persists on the remote
+// between runs just like it does locally.
+const remoteDir = ".setup-machine"
+
+// Sync copies a setup-machine binary and the given config files to host over SCP, then runs
+// setup-machine there with args, streaming its output back to the local terminal.
+// Authentication is left entirely to ssh, so the user's ssh-agent and ~/.ssh/config apply.
+//
+// binary is the executable to copy. When empty, the running binary is copied, which only works
+// if the remote has the same OS and architecture; that is checked with `uname -sm` first, and
+// a mismatch is an error naming the --remote-binary flag to pass a build for the remote.
+//
+// Config file paths must be relative to the main config's directory (as they are in
+// config.yaml); they are recreated under remoteDir with the same layout so the remote binary
+// resolves them the same way.
+func Sync(host, binary string, configFiles []string, args []string) error {
+	if binary == "" {
+		var err error
+		if binary, err = os.Executable(); err != nil {
+			return fmt.Errorf("cannot locate setup-machine binary: %w", err)
+		}
+		if err := checkPlatform(host); err != nil {
+			return err
+		}
+	}
+
+	// Collect every remote directory that needs to exist before copying
+	dirs := map[string]bool{remoteDir: true}
+	for _, f := range configFiles {
+		if filepath.IsAbs(f) || strings.HasPrefix(filepath.Clean(f), "..") {
+			return fmt.Errorf("config file %s must be inside the config directory to be copied to %s", f, host)
+		}
+		dirs[path.Join(remoteDir, filepath.ToSlash(filepath.Dir(f)))] = true
+	}
+	mkdirArgs := []string{"mkdir", "-p"}
+	for d := range dirs {
+		mkdirArgs = append(mkdirArgs, shellQuote(d))
+	}
+	logger.Info("[INFO] Preparing %s:~/%s\n", host, remoteDir)
+	if err := run("ssh", host, strings.Join(mkdirArgs, " ")); err != nil {
+		return fmt.Errorf("failed to create remote directory on %s: %w", host, err)
+	}
+
+	// Copy the binary and each config file, preserving relative paths
+	logger.Info("[INFO] Copying setup-machine binary to %s\n", host)
+	if err := run("scp", "-q", binary, host+":"+remoteDir+"/setup-machine"); err != nil {
+		return fmt.Errorf("failed to copy binary to %s: %w", host, err)
+	}
+	for _, f := range configFiles {
+		dst := path.Join(remoteDir, filepath.ToSlash(f))
+		logger.Debug("[DEBUG] Copying %s to %s:%s\n", f, host, dst)
+		if err := run("scp", "-q", f, host+":"+dst); err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", f, host, err)
+		}
+	}
+
+	// Run the sync remotely; output is streamed back through the ssh session
+	quoted := make([]string, 0, len(args))
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
+	}
+	remoteCmd := fmt.Sprintf("cd %s && chmod +x ./setup-machine && ./setup-machine %s", remoteDir, strings.Join(quoted, " "))
+	logger.Info("[INFO] Running on %s: setup-machine %s\n", host, strings.Join(args, " "))
+	if err := run("ssh", host, remoteCmd); err != nil {
+		return fmt.Errorf("remote sync on %s failed: %w", host, err)
+	}
+	return nil
+}
+
+// checkPlatform fails unless host runs the OS and architecture this binary was built for.
+func checkPlatform(host string) error {
+	logger.Debug("[DEBUG] Checking the platform of %s\n", host)
+	cmd := exec.Command("ssh", host, "uname -sm")
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to detect the platform of %s: %w", host, err)
+	}
+	goos, goarch, err := parseUname(string(output))
+	if err != nil {
+		return fmt.Errorf("failed to detect the platform of %s: %w", host, err)
+	}
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return fmt.Errorf("%s runs %s/%s but this setup-machine is built for %s/%s; pass --remote-binary with a %s/%s build",
+			host, goos, goarch, runtime.GOOS, runtime.GOARCH, goos, goarch)
+	}
+	return nil
+}
+
+// unameArchs maps `uname -m` machine names to GOARCH values.
+var unameArchs = map[string]string{
+	"x86_64": "amd64", "amd64": "amd64", "arm64": "arm64", "aarch64": "arm64",
+	"i386": "386", "i686": "386", "armv7l": "arm", "armv6l": "arm",
+}
+
+// parseUname turns `uname -sm` output such as "Darwin arm64" into GOOS and GOARCH values.
+func parseUname(output string) (goos, goarch string, err error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("unexpected uname output %q", strings.TrimSpace(output))
+	}
+	goarch, ok := unameArchs[strings.ToLower(fields[1])]
+	if !ok {
+		return "", "", fmt.Errorf("unknown architecture %q", fields[1])
+	}
+	return strings.ToLower(fields[0]), goarch, nil
+}
+
+// run executes a command with stdout/stderr attached to the local terminal.
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	logger.Trace("[TRACE] Running command: %s\n", strings.Join(cmd.Args, " "))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// shellQuote wraps s in single quotes for the remote shell, escaping embedded quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
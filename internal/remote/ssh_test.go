@@ -0,0 +1,38 @@
+package remote
+
+import "testing"
+
+func TestParseUname(t *testing.T) {
+	tests := []struct {
+		output     string
+		goos, arch string
+		wantErr    bool
+	}{
+		{output: "Darwin arm64\n", goos: "darwin", arch: "arm64"},
+		{output: "Darwin x86_64\n", goos: "darwin", arch: "amd64"},
+		{output: "Linux aarch64\n", goos: "linux", arch: "arm64"},
+		{output: "Linux x86_64", goos: "linux", arch: "amd64"},
+		{output: "Linux armv7l", goos: "linux", arch: "arm"},
+		{output: "Linux riscv64", wantErr: true},
+		{output: "Linux", wantErr: true},
+		{output: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		goos, arch, err := parseUname(tt.output)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseUname(%q) = %s/%s, want an error", tt.output, goos, arch)
+			}
+			continue
+		}
+		if err != nil || goos != tt.goos || arch != tt.arch {
+			t.Errorf("parseUname(%q) = %s/%s, %v; want %s/%s", tt.output, goos, arch, err, tt.goos, tt.arch)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote("it's"), `'it'\''s'`; got != want {
+		t.Errorf("shellQuote = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"sync"
+	"time"
+)
+
+// writeAtomic writes data to a temporary file next to path and renames it into place, so a
+// crash mid-write never leaves a truncated state file behind. The data is flushed to disk
+// before the rename; otherwise a power loss could still surface an empty file after it.
+// Missing parent directories are created.
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temp file on any failure; after a successful rename this is a no-op
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Checkpointer saves the state incrementally during a run, so completed work survives a
+// crash or kill before the final save. Saves are debounced: the first change is written
+// immediately, and further changes within the interval are coalesced into one trailing write.
+type Checkpointer struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time   // When the state was last written
+	pending []byte      // Latest snapshot not yet written
+	timer   *time.Timer // Trailing write for pending, if scheduled
+	stopped bool
+}
+
+// NewCheckpointer returns a Checkpointer writing to path at most once per interval.
+func NewCheckpointer(path string, interval time.Duration) *Checkpointer {
+	return &Checkpointer{path: path, interval: interval}
+}
+
+// Save snapshots st and writes it, now or at the end of the current debounce interval.
+// The snapshot is taken synchronously, so callers must hold whatever lock guards st.
+func (c *Checkpointer) Save(st *State) {
+	data, err := Marshal(st)
+	if err != nil {
+		logger.Error("[ERROR] Failed to marshal state checkpoint: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return
+	}
+
+	if wait := c.interval - time.Since(c.last); wait > 0 {
+		c.pending = data
+		if c.timer == nil {
+			c.timer = time.AfterFunc(wait, c.flushPending)
+		}
+		return
+	}
+	c.write(data)
+}
+
+// Stop cancels any trailing write. It is called before the final SaveState so a late
+// checkpoint can't overwrite it.
+func (c *Checkpointer) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// flushPending writes the latest coalesced snapshot when the debounce interval ends.
+func (c *Checkpointer) flushPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timer = nil
+	if c.stopped || c.pending == nil {
+		return
+	}
+	c.write(c.pending)
+}
+
+// write stores data and resets the debounce window. c.mu must be held.
+func (c *Checkpointer) write(data []byte) {
+	c.pending = nil
+	c.last = time.Now()
+	if err := writeAtomic(c.path, data); err != nil {
+		logger.Warn("[WARN] Failed to checkpoint state to %s: %v\n", c.path, err)
+		return
+	}
+	logger.Debug("[DEBUG] Checkpointed state to %s\n", c.path)
+}
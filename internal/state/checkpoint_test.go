@@ -0,0 +1,135 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveStateIsNeverSeenHalfWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	SaveState(path, buildState([]string{"jq"}, nil))
+
+	// Alternate between a small and a large state while a reader keeps parsing the file
+	small := buildState([]string{"jq"}, nil)
+	var names []string
+	for i := range 200 {
+		names = append(names, fmt.Sprintf("tool-%d", i))
+	}
+	large := buildState(names, nil)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("read %d: %v", i, err)
+				return
+			}
+			var st State
+			if err := json.Unmarshal(data, &st); err != nil || (len(st.Tools) != 1 && len(st.Tools) != len(names)) {
+				t.Errorf("read %d saw a partial state file (%d bytes, %d tools): %v", i, len(data), len(st.Tools), err)
+				return
+			}
+		}
+	}()
+	for i := range 40 {
+		if i%2 == 0 {
+			SaveState(path, large)
+		} else {
+			SaveState(path, small)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("state file mode = %v, want 0644", info.Mode().Perm())
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".state.json.tmp-*")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestWriteAtomicCleansUpOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	// A non-empty directory in the way makes the rename fail after the temp file was written
+	path := filepath.Join(dir, "state.json")
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAtomic(path, []byte("{}\n")); err == nil {
+		t.Fatal("writeAtomic over a non-empty directory succeeded")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".state.json.tmp-*")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind after a failed write: %v", leftovers)
+	}
+}
+
+func TestCheckpointerDebouncesWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := NewCheckpointer(path, 100*time.Millisecond)
+	defer c.Stop()
+
+	// The first change is written right away
+	c.Save(buildState([]string{"jq"}, nil))
+	if got := LoadState(path); len(got.Tools) != 1 {
+		t.Fatalf("tools after the first checkpoint = %v, want jq", got.Tools)
+	}
+
+	// Changes within the interval are held back and coalesced into the latest one
+	c.Save(buildState([]string{"jq", "fd"}, nil))
+	c.Save(buildState([]string{"jq", "fd", "rg"}, nil))
+	if got := LoadState(path); len(got.Tools) != 1 {
+		t.Errorf("tools within the debounce interval = %v, want only the first checkpoint", got.Tools)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(LoadState(path).Tools) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("tools = %v, want the trailing write of jq, fd and rg", LoadState(path).Tools)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCheckpointerStopCancelsTrailingWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c := NewCheckpointer(path, 50*time.Millisecond)
+	c.Save(buildState([]string{"jq"}, nil))
+	c.Save(buildState([]string{"jq", "fd"}, nil))
+	c.Stop()
+
+	// The final save happens after Stop; a late checkpoint must not replace it
+	SaveState(path, buildState([]string{"bat"}, nil))
+	time.Sleep(150 * time.Millisecond)
+	if got := LoadState(path); len(got.Tools) != 1 || got.Tools["bat"].Version == "" {
+		t.Errorf("tools = %v, want the final save's bat", got.Tools)
+	}
+
+	c.Save(buildState([]string{"rg"}, nil))
+	if got := LoadState(path); got.Tools["rg"].Version != "" {
+		t.Error("a checkpoint was written after Stop")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".state.json.tmp-*")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
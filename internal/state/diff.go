@@ -0,0 +1,204 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ToolChange describes how a single tool entry differs between two states.
+// From is empty when the tool was added, To is empty when it was removed.
+type ToolChange struct {
+	Name string `json:"name"`           // Tool name as used in the state map
+	From string `json:"from,omitempty"` // Version recorded before the run
+	To   string `json:"to,omitempty"`   // Version recorded after the run
+}
+
+// SettingChange describes how a single setting entry differs between two states.
+// From is empty when the setting was added, To is empty when it was removed.
+type SettingChange struct {
+	Key  string `json:"key"`            // "domain:key" identifier of the setting
+	From string `json:"from,omitempty"` // Value recorded before the run
+	To   string `json:"to,omitempty"`   // Value recorded after the run
+}
+
+// AliasChange describes how a single shell alias differs between two states.
+// From is empty when the alias was added, To is empty when it was removed.
+type AliasChange struct {
+	Name string `json:"name"`           // Alias name
+	From string `json:"from,omitempty"` // Value recorded before the run
+	To   string `json:"to,omitempty"`   // Value recorded after the run
+}
+
+// Diff is the net difference between two snapshots of the state file.
+// It is the "git diff" of a run: only entries whose tracked data changed are listed.
+type Diff struct {
+	ToolsAdded      []ToolChange    `json:"tools_added"`
+	ToolsUpdated    []ToolChange    `json:"tools_updated"`
+	ToolsRemoved    []ToolChange    `json:"tools_removed"`
+	SettingsAdded   []SettingChange `json:"settings_added"`
+	SettingsChanged []SettingChange `json:"settings_changed"`
+	SettingsRemoved []SettingChange `json:"settings_removed"`
+	AliasesAdded    []AliasChange   `json:"aliases_added"`
+	AliasesChanged  []AliasChange   `json:"aliases_changed"`
+	AliasesRemoved  []AliasChange   `json:"aliases_removed"`
+}
+
+// Clone returns a deep copy of the state so it can be used as a snapshot
+// that is unaffected by later mutations of the original maps.
+func (st *State) Clone() *State {
+	clone := &State{
+		Tools:    make(map[string]ToolState, len(st.Tools)),
+		Settings: make(map[string]SettingState, len(st.Settings)),
+	}
+	for name, ts := range st.Tools {
+		clone.Tools[name] = ts
+	}
+	for key, ss := range st.Settings {
+		clone.Settings[key] = ss
+	}
+	clone.Taps = append([]string(nil), st.Taps...)
+	if st.Approvals != nil {
+		clone.Approvals = make(map[string]Approval, len(st.Approvals))
+		for name, a := range st.Approvals {
+			clone.Approvals[name] = a
+		}
+	}
+	if st.Aliases != nil {
+		clone.Aliases = make(map[string]string, len(st.Aliases))
+		for name, value := range st.Aliases {
+			clone.Aliases[name] = value
+		}
+	}
+	return clone
+}
+
+// Compare computes the Diff between a state snapshot taken before a run and the state after it.
+// All lists are sorted by name/key so the output is stable between runs.
+func Compare(before, after *State) Diff {
+	// Every list starts out empty rather than nil, so an unchanged category encodes as [] in JSON
+	d := Diff{
+		ToolsAdded:      []ToolChange{},
+		ToolsUpdated:    []ToolChange{},
+		ToolsRemoved:    []ToolChange{},
+		SettingsAdded:   []SettingChange{},
+		SettingsChanged: []SettingChange{},
+		SettingsRemoved: []SettingChange{},
+		AliasesAdded:    []AliasChange{},
+		AliasesChanged:  []AliasChange{},
+		AliasesRemoved:  []AliasChange{},
+	}
+
+	// Tools added or updated
+	for name, cur := range after.Tools {
+		prev, ok := before.Tools[name]
+		switch {
+		case !ok:
+			d.ToolsAdded = append(d.ToolsAdded, ToolChange{Name: name, To: cur.Version})
+		case prev.Version != cur.Version || prev.InstallPath != cur.InstallPath:
+			d.ToolsUpdated = append(d.ToolsUpdated, ToolChange{Name: name, From: prev.Version, To: cur.Version})
+		}
+	}
+	// Tools removed
+	for name, prev := range before.Tools {
+		if _, ok := after.Tools[name]; !ok {
+			d.ToolsRemoved = append(d.ToolsRemoved, ToolChange{Name: name, From: prev.Version})
+		}
+	}
+
+	// Settings added or changed
+	for key, cur := range after.Settings {
+		prev, ok := before.Settings[key]
+		switch {
+		case !ok:
+			d.SettingsAdded = append(d.SettingsAdded, SettingChange{Key: key, To: cur.Value})
+		case prev.Value != cur.Value:
+			d.SettingsChanged = append(d.SettingsChanged, SettingChange{Key: key, From: prev.Value, To: cur.Value})
+		}
+	}
+	// Settings removed
+	for key, prev := range before.Settings {
+		if _, ok := after.Settings[key]; !ok {
+			d.SettingsRemoved = append(d.SettingsRemoved, SettingChange{Key: key, From: prev.Value})
+		}
+	}
+
+	// Aliases added, changed, or removed
+	for name, cur := range after.Aliases {
+		prev, ok := before.Aliases[name]
+		switch {
+		case !ok:
+			d.AliasesAdded = append(d.AliasesAdded, AliasChange{Name: name, To: cur})
+		case prev != cur:
+			d.AliasesChanged = append(d.AliasesChanged, AliasChange{Name: name, From: prev, To: cur})
+		}
+	}
+	for name, prev := range before.Aliases {
+		if _, ok := after.Aliases[name]; !ok {
+			d.AliasesRemoved = append(d.AliasesRemoved, AliasChange{Name: name, From: prev})
+		}
+	}
+
+	sortToolChanges(d.ToolsAdded)
+	sortToolChanges(d.ToolsUpdated)
+	sortToolChanges(d.ToolsRemoved)
+	sortSettingChanges(d.SettingsAdded)
+	sortSettingChanges(d.SettingsChanged)
+	sortSettingChanges(d.SettingsRemoved)
+	sortAliasChanges(d.AliasesAdded)
+	sortAliasChanges(d.AliasesChanged)
+	sortAliasChanges(d.AliasesRemoved)
+	return d
+}
+
+// Empty reports whether the run left the tracked state unchanged.
+func (d Diff) Empty() bool {
+	return len(d.ToolsAdded) == 0 && len(d.ToolsUpdated) == 0 && len(d.ToolsRemoved) == 0 &&
+		len(d.SettingsAdded) == 0 && len(d.SettingsChanged) == 0 && len(d.SettingsRemoved) == 0 &&
+		len(d.AliasesAdded) == 0 && len(d.AliasesChanged) == 0 && len(d.AliasesRemoved) == 0
+}
+
+// Lines renders the Diff as human-readable lines using +, ~ and - markers
+// for added, changed and removed entries respectively.
+func (d Diff) Lines() []string {
+	var lines []string
+	for _, c := range d.ToolsAdded {
+		lines = append(lines, fmt.Sprintf("+ tool %s@%s", c.Name, c.To))
+	}
+	for _, c := range d.ToolsUpdated {
+		lines = append(lines, fmt.Sprintf("~ tool %s %s -> %s", c.Name, c.From, c.To))
+	}
+	for _, c := range d.ToolsRemoved {
+		lines = append(lines, fmt.Sprintf("- tool %s@%s", c.Name, c.From))
+	}
+	for _, c := range d.SettingsAdded {
+		lines = append(lines, fmt.Sprintf("+ setting %s = %s", c.Key, c.To))
+	}
+	for _, c := range d.SettingsChanged {
+		lines = append(lines, fmt.Sprintf("~ setting %s %s -> %s", c.Key, c.From, c.To))
+	}
+	for _, c := range d.SettingsRemoved {
+		lines = append(lines, fmt.Sprintf("- setting %s (was %s)", c.Key, c.From))
+	}
+	for _, c := range d.AliasesAdded {
+		lines = append(lines, fmt.Sprintf("+ alias %s = %s", c.Name, c.To))
+	}
+	for _, c := range d.AliasesChanged {
+		lines = append(lines, fmt.Sprintf("~ alias %s %s -> %s", c.Name, c.From, c.To))
+	}
+	for _, c := range d.AliasesRemoved {
+		lines = append(lines, fmt.Sprintf("- alias %s (was %s)", c.Name, c.From))
+	}
+	return lines
+}
+
+func sortToolChanges(changes []ToolChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+}
+
+func sortSettingChanges(changes []SettingChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+}
+
+func sortAliasChanges(changes []AliasChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+}
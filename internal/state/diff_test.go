@@ -0,0 +1,18 @@
+package state
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompareEncodesEmptyListsAsArrays(t *testing.T) {
+	st := buildState([]string{"jq"}, nil)
+	data, err := json.Marshal(Compare(st, st.Clone()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "null") {
+		t.Errorf("unchanged state encoded as %s, want [] for every list", data)
+	}
+}
@@ -0,0 +1,89 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+	"time"
+)
+
+// maxHistorySize is the size in bytes after which the history log is rotated.
+// The previous log is kept as a single ".1" backup, so at most twice this is used on disk.
+const maxHistorySize = 1 << 20
+
+// HistoryEntry is one compact record of a completed run, appended to the history log.
+type HistoryEntry struct {
+	Time            time.Time `json:"time"`             // When the run finished
+	Command         string    `json:"command"`          // Command that was run, e.g. "sync tools"
+	ConfigHash      string    `json:"config_hash"`      // SHA256 over all config files used for the run
+	Installed       int       `json:"installed"`        // Tools newly added to state
+	Upgraded        int       `json:"upgraded"`         // Tools whose recorded version/path changed
+	Removed         int       `json:"removed"`          // Tools removed from state
+	Failed          int       `json:"failed"`           // Configured tools still not at the desired version
+	SettingsApplied int       `json:"settings_applied"` // Settings added or changed in state
+}
+
+// HistoryPath returns the location of the history log, which lives next to the state file.
+func HistoryPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "history.jsonl")
+}
+
+// AppendHistory appends an entry as a single JSON line to the history log at path,
+// rotating the log first if it has grown beyond maxHistorySize.
+// Errors are logged but not propagated, since history is informational only.
+func AppendHistory(path string, entry HistoryEntry) {
+	if info, err := os.Stat(path); err == nil && info.Size() > maxHistorySize {
+		logger.Debug("[DEBUG] Rotating history log %s\n", path)
+		if err := os.Rename(path, path+".1"); err != nil {
+			logger.Warn("[WARN] Failed to rotate history log %s: %v\n", path, err)
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("[ERROR] Failed to marshal history entry: %v\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Error("[ERROR] Failed to create history directory for %s: %v\n", path, err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Error("[ERROR] Failed to open history log %s: %v\n", path, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.Error("[ERROR] Failed to write history log %s: %v\n", path, err)
+	}
+}
+
+// LoadHistory reads all entries from the history log at path, oldest first.
+// A missing log yields no entries and no error. Malformed lines are skipped.
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logger.Debug("[DEBUG] Skipping malformed history line: %v\n", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"setup-machine/internal/logger"
+)
+
+// LegacyPath is where the state file used to live: relative to the current directory, so
+// running from another directory silently started from an empty state.
+const LegacyPath = "state.json"
+
+// DefaultPath returns the default state file location, independent of the current directory:
+// $XDG_STATE_HOME/setup-machine/state.json, i.e. ~/.local/state/setup-machine/state.json
+// unless XDG_STATE_HOME is set.
+func DefaultPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			// No home directory to anchor to; fall back to the old behavior
+			return LegacyPath
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "setup-machine", "state.json")
+}
+
+// MigrateLegacy copies a state file left in the current directory by older versions (and the
+// history log next to it) to path, if nothing exists at path yet. The old files are left in
+// place. It is a no-op when there is nothing to migrate, when the file in the current directory
+// is not a setup-machine state file, or when another run holds the state lock.
+func MigrateLegacy(path string) error {
+	if path == LegacyPath {
+		return nil
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	data, err := os.ReadFile(LegacyPath)
+	if err != nil {
+		return nil
+	}
+	// Any project may have a state.json; only adopt one that looks like ours
+	if !isStateFile(data) {
+		logger.Debug("[DEBUG] %s is not a setup-machine state file; not migrating it\n", LegacyPath)
+		return nil
+	}
+
+	// Copy under the lock, so two runs can't migrate at once or race a run that is saving state.
+	// A run holding the lock has already gone through this, so there is nothing left to do.
+	unlock, err := Lock(path)
+	if err != nil {
+		logger.Debug("[DEBUG] Not migrating %s: %v\n", LegacyPath, err)
+		return nil
+	}
+	defer unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := writeAtomic(path, data); err != nil {
+		return err
+	}
+	if history, err := os.ReadFile(HistoryPath(LegacyPath)); err == nil {
+		if err := writeAtomic(HistoryPath(path), history); err != nil {
+			logger.Warn("[WARN] Failed to copy history log to %s: %v\n", HistoryPath(path), err)
+		}
+	}
+	logger.Info("[INFO] Copied state file %s to its new location %s; the old file can be deleted\n", LegacyPath, path)
+	return nil
+}
+
+// isStateFile reports whether data is a state file written by setup-machine: a JSON object that
+// decodes into State and has the tools and settings keys SaveState always writes.
+func isStateFile(data []byte) bool {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return false
+	}
+	if _, ok := keys["tools"]; !ok {
+		return false
+	}
+	if _, ok := keys["settings"]; !ok {
+		return false
+	}
+	var st State
+	return json.Unmarshal(data, &st) == nil
+}
@@ -0,0 +1,63 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateLegacy(t *testing.T) {
+	const legacy = `{"tools": {"jq": {"version": "1.7", "install_path": "/usr/local/bin/jq", "installed_by_dev_setup": true}}, "settings": {}}` + "\n"
+	tests := []struct {
+		name     string
+		legacy   string
+		existing string
+		locked   bool
+		want     string
+	}{
+		{name: "copies a state file", legacy: legacy, want: legacy},
+		{name: "no legacy file"},
+		{name: "keeps an existing state file", legacy: legacy, existing: "{}\n", want: "{}\n"},
+		{name: "ignores other JSON", legacy: `{"name": "some-package", "version": "1.0.0"}`},
+		{name: "ignores a file that is not a state", legacy: `{"tools": ["jq"], "settings": {}}`},
+		{name: "ignores invalid JSON", legacy: "tools: {}"},
+		{name: "waits for the run holding the lock", legacy: legacy, locked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			path := filepath.Join(t.TempDir(), "setup-machine", "state.json")
+			if tt.legacy != "" {
+				if err := os.WriteFile(LegacyPath, []byte(tt.legacy), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.existing != "" {
+				if err := writeAtomic(path, []byte(tt.existing)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.locked {
+				unlock, err := Lock(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer unlock()
+			}
+
+			if err := MigrateLegacy(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("state file = %q, %v; want none", got, err)
+				}
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("state file = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,30 +4,76 @@ import (
 	"encoding/json"                 // For JSON encoding and decoding of the state file
 	"os"                            // For file system operations like reading and writing files
 	"setup-machine/internal/logger" // Custom logger package for logging errors and debug info
+	"slices"                        // For sorting slice-valued collections before marshaling
+	"time"                          // For verification timestamps
 )
 
 // ToolState represents the saved state of an installed tool.
 // It records the installed version, the full install path of the tool executable,
 // and a boolean indicating whether this tool was installed by this setup system.
 type ToolState struct {
-	Version             string `json:"version"`                // Version string of the installed tool
-	InstallPath         string `json:"install_path"`           // Absolute file system path where the tool executable is installed
-	InstalledByDevSetup bool   `json:"installed_by_dev_setup"` // True if installed/managed by this setup tool, false if external/manual install
+	Version             string            `json:"version"`                // Version string of the installed tool
+	InstallPath         string            `json:"install_path"`           // Absolute file system path where the tool executable is installed
+	InstalledByDevSetup bool              `json:"installed_by_dev_setup"` // True if installed/managed by this setup tool, false if external/manual install
+	Checksum            string            `json:"checksum,omitempty"`     // SHA256 of the installed executable, empty if the install path is not a single file
+	ArtifactDir         string            `json:"artifact_dir,omitempty"` // Directory holding the artifact behind a generated launcher (InstallPath is then the launcher)
+	VerifiedAt          time.Time         `json:"verified_at,omitzero"`   // When the install was last installed or verified intact
+	Source              string            `json:"source,omitempty"`       // Install source (github, url, brew, ...) used to pick the uninstall strategy
+	Files               map[string]string `json:"files,omitempty"`        // Managed config files placed for the tool, path -> SHA256 of written content
+	BinDir              string            `json:"bin_dir,omitempty"`      // Bin directory (from bin_dirs) the binary was installed into
+	Cask                bool              `json:"cask,omitempty"`         // Installed as a Homebrew cask rather than a formula
+	URL                 string            `json:"url,omitempty"`          // Download URL a url tool was installed from
+	Download            string            `json:"download,omitempty"`     // SHA256 of the downloaded artifact (url tools)
+	Spec                string            `json:"spec,omitempty"`         // source:name[@version] of a tool added with `install` rather than from the config
 }
 
 // SettingState represents the saved state of a macOS system setting that was applied.
-// It stores the domain and key for the `defaults` system, plus the string value last applied.
+// It stores the domain and key for the `defaults` system, the string value last applied,
+// and the value the system had before setup-machine first changed it, so it can be restored.
 type SettingState struct {
-	Domain string `json:"domain"` // The domain string, e.g., "com.apple.finder"
-	Key    string `json:"key"`    // The key string within that domain, e.g., "AppleShowAllFiles"
-	Value  string `json:"value"`  // The value last written to that key, stored as string
+	Domain        string `json:"domain"`                   // The domain string, e.g., "com.apple.finder"
+	Key           string `json:"key"`                      // The key string within that domain, e.g., "AppleShowAllFiles"
+	Value         string `json:"value"`                    // The value last written to that key, stored as string
+	Type          string `json:"type,omitempty"`           // The `defaults write` type the value was written with
+	Previous      string `json:"previous,omitempty"`       // Value as printed by `defaults read` before the first write
+	PreviousUnset bool   `json:"previous_unset,omitempty"` // The key did not exist before the first write
+	CurrentHost   bool   `json:"current_host,omitempty"`   // Written with `defaults -currentHost`
+	Sudo          bool   `json:"sudo,omitempty"`           // Written to /Library/Preferences with sudo
+}
+
+// Approval records that the user acknowledged a tool's license before it was first installed.
+type Approval struct {
+	LicenseURL string    `json:"license_url,omitempty"` // License the user was shown when approving
+	ApprovedAt time.Time `json:"approved_at"`           // When the approval was given
 }
 
 // State holds the entire saved state for the setup tool.
 // It includes maps of installed tools and applied system settings keyed by their unique identifiers.
 type State struct {
-	Tools    map[string]ToolState    `json:"tools"`    // Map from tool name to its ToolState
-	Settings map[string]SettingState `json:"settings"` // Map from "domain:key" string to SettingState
+	Tools    map[string]ToolState    `json:"tools"`          // Map from tool name to its ToolState
+	Settings map[string]SettingState `json:"settings"`       // Map from "domain:key" string to SettingState
+	Taps     []string                `json:"taps,omitempty"` // Homebrew taps added by this tool, so they can be untapped on a full reset
+
+	Approvals map[string]Approval `json:"approvals,omitempty"` // License approvals by tool name; kept across reinstalls so users are asked only once
+	Aliases   map[string]string   `json:"aliases,omitempty"`   // Aliases in the managed rc block, name -> value, as of the last alias sync
+}
+
+// Approve records a license approval for a tool.
+func (st *State) Approve(tool, licenseURL string, at time.Time) {
+	if st.Approvals == nil {
+		st.Approvals = make(map[string]Approval)
+	}
+	st.Approvals[tool] = Approval{LicenseURL: licenseURL, ApprovedAt: at}
+}
+
+// AddTap records a Homebrew tap as added by setup-machine, ignoring duplicates.
+func (st *State) AddTap(tap string) {
+	for _, t := range st.Taps {
+		if t == tap {
+			return
+		}
+	}
+	st.Taps = append(st.Taps, tap)
 }
 
 // LoadState loads the saved state from a JSON file at the given path.
@@ -59,12 +105,28 @@ func LoadState(path string) *State {
 	return &st
 }
 
+// Marshal serializes the state deterministically: the same logical state always yields
+// byte-identical output, so state files diff cleanly when kept under version control.
+// Struct fields are emitted in declaration order and encoding/json sorts map keys;
+// any slice-valued collections added to State must be sorted here before marshaling, on a
+// copy: st may be shared with tools still being synced and is not modified.
+// The output is indented and ends with a trailing newline.
+func Marshal(st *State) ([]byte, error) {
+	sorted := *st
+	sorted.Taps = slices.Sorted(slices.Values(st.Taps))
+	data, err := json.MarshalIndent(&sorted, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
 // SaveState writes the given State struct to a JSON file at the given path.
 // It pretty-prints the JSON with indentation for readability.
 // Errors during marshalling or writing are logged but not propagated.
 func SaveState(path string, st *State) {
-	// Marshal the State struct into indented JSON bytes
-	file, err := json.MarshalIndent(st, "", "  ")
+	// Marshal the State struct into deterministic, indented JSON bytes
+	file, err := Marshal(st)
 	if err != nil {
 		// Log marshalling errors, typically should never happen unless invalid data
 		logger.Error("[ERROR] Failed to marshal state: %v\n", err)
@@ -74,8 +136,8 @@ func SaveState(path string, st *State) {
 	// Log debug info showing the full JSON state being written (can be verbose)
 	logger.Debug("[DEBUG] Writing state to %s:\n%s\n", path, string(file))
 
-	// Write the JSON bytes to the file with mode 0644 (read/write owner, read others)
-	err = os.WriteFile(path, file, 0644)
+	// Write the JSON bytes atomically with mode 0644 (read/write owner, read others)
+	err = writeAtomic(path, file)
 	if err != nil {
 		// Log write errors, e.g., permission denied or disk full
 		logger.Error("[ERROR] Failed to write state file %s: %v\n", path, err)
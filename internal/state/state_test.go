@@ -0,0 +1,67 @@
+package state
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildState fills a state in the given tool order, so tests can compare states that hold
+// the same data but were built differently.
+func buildState(names []string, taps []string) *State {
+	st := &State{Tools: map[string]ToolState{}, Settings: map[string]SettingState{}}
+	for _, name := range names {
+		st.Tools[name] = ToolState{Version: "1.0", InstallPath: "/usr/local/bin/" + name, Files: map[string]string{"/b": "2", "/a": "1"}}
+		st.Settings["com.example:"+name] = SettingState{Domain: "com.example", Key: name, Value: "1"}
+	}
+	for _, tap := range taps {
+		st.AddTap(tap)
+	}
+	st.Approve("terraform", "https://example.com/license", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	return st
+}
+
+func TestMarshalIsDeterministic(t *testing.T) {
+	st := buildState([]string{"bat", "jq", "fd", "rg"}, []string{"hashicorp/tap", "goreleaser/tap"})
+	a, err := Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The state may still be in use while a checkpoint marshals it, so it is left as it was
+	if got := strings.Join(st.Taps, ","); got != "hashicorp/tap,goreleaser/tap" {
+		t.Errorf("Marshal reordered the state's taps to %s", got)
+	}
+	b, err := Marshal(buildState([]string{"rg", "fd", "jq", "bat"}, []string{"goreleaser/tap", "hashicorp/tap"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("the same state marshaled differently:\n%s\n---\n%s", a, b)
+	}
+	if !bytes.HasSuffix(a, []byte("}\n")) {
+		t.Error("marshaled state does not end with a newline")
+	}
+}
+
+func TestSaveStateRoundTrips(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st := buildState([]string{"jq", "bat"}, []string{"hashicorp/tap"})
+	SaveState(path, st)
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Loading and saving again without changes must not touch a byte
+	SaveState(path, LoadState(path))
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("state changed on a load/save round trip:\n%s\n---\n%s", first, second)
+	}
+}
@@ -0,0 +1,159 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a version range such as ">=1.2.0 <2.0.0", "~1.4", or "^0.3 || ^1".
+// Comparators separated by spaces or commas must all hold; "||" separates alternatives.
+// Supported operators: =, !=, >, >=, <, <=, ~ (same minor, or same major if only the major
+// is given), and ^ (same major, or same minor for 0.x versions).
+type Constraint struct {
+	raw  string
+	alts [][]comparator
+}
+
+// comparator is a single `op version` term, with ~ and ^ already expanded into >= and <.
+type comparator struct {
+	op      string
+	version []int
+}
+
+// IsConstraint reports whether a configured version is a range rather than an exact version.
+func IsConstraint(s string) bool {
+	return strings.ContainsAny(s, "<>=~^!|, ")
+}
+
+// ParseConstraint parses a version range. Versions may be partial ("1.4" means 1.4.0).
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		var terms []comparator
+		pending := "" // An operator written apart from its version, as in ">= 1.2"
+		for _, term := range strings.FieldsFunc(alt, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if strings.Trim(term, "<>=!~^") == "" {
+				pending += term
+				continue
+			}
+			term, pending = pending+term, ""
+			parsed, err := parseTerm(term)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			terms = append(terms, parsed...)
+		}
+		if pending != "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %q is missing a version", s, pending)
+		}
+		if len(terms) == 0 {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: empty range", s)
+		}
+		c.alts = append(c.alts, terms)
+	}
+	return c, nil
+}
+
+// parseTerm parses one comparator, expanding ~ and ^ into a pair of bounds.
+func parseTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(term, candidate) {
+			op = candidate
+			break
+		}
+	}
+	v, err := parseStrict(strings.TrimPrefix(term, op))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "~":
+		// ~1 allows 1.x; ~1.4 and ~1.4.2 allow 1.4.x
+		upper := bump(v, min(len(v)-1, 1))
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	case "^":
+		// Allow changes that don't touch the first non-zero component
+		i := 0
+		for i < len(v)-1 && v[i] == 0 {
+			i++
+		}
+		return []comparator{{">=", v}, {"<", bump(v, i)}}, nil
+	case "":
+		op = "="
+	}
+	return []comparator{{op, v}}, nil
+}
+
+// bump returns v incremented at component i, with everything after it dropped (i.e. zero).
+func bump(v []int, i int) []int {
+	out := append([]int(nil), v[:i+1]...)
+	out[i]++
+	return out
+}
+
+// parseStrict parses a dotted numeric version, optionally prefixed with "v", rejecting
+// anything Compare would silently read as zero.
+func parseStrict(s string) ([]int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, fmt.Errorf("missing version")
+	}
+	var out []int
+	for _, p := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q is not a numeric version", s)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Check reports whether version v satisfies the constraint. Pre-releases (e.g. 1.2.0-rc1)
+// never do, so a range only ever selects stable releases.
+func (c Constraint) Check(v string) bool {
+	if strings.ContainsAny(strings.TrimPrefix(v, "v"), "-+") {
+		return false
+	}
+	pv := parts(v)
+	for _, alt := range c.alts {
+		ok := true
+		for _, t := range alt {
+			if !t.matches(pv) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t comparator) matches(v []int) bool {
+	cmp := compareParts(v, t.version)
+	switch t.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// String returns the constraint as it was written.
+func (c Constraint) String() string {
+	return c.raw
+}
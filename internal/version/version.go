@@ -0,0 +1,59 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is the setup-machine release version.
+// It is "dev" for local builds and is set at release time via:
+//
+//	go build -ldflags "-X setup-machine/internal/version.Version=1.2.3"
+var Version = "dev"
+
+// IsDev reports whether this is an unversioned development build.
+func IsDev() bool {
+	return Version == "" || Version == "dev"
+}
+
+// Compare compares two dotted versions (an optional leading "v" is ignored) numerically,
+// component by component. Missing components count as zero and pre-release/build suffixes
+// are ignored. It returns -1 if a < b, 0 if a == b, and +1 if a > b.
+func Compare(a, b string) int {
+	return compareParts(parts(a), parts(b))
+}
+
+// compareParts compares two parsed versions; see Compare.
+func compareParts(pa, pb []int) int {
+	pa = append([]int(nil), pa...)
+	pb = append([]int(nil), pb...)
+	for len(pa) < len(pb) {
+		pa = append(pa, 0)
+	}
+	for len(pb) < len(pa) {
+		pb = append(pb, 0)
+	}
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1
+		case pa[i] > pb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// parts splits a version like "v1.2.3-rc1" into its numeric components [1 2 3].
+func parts(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var out []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		out = append(out, n)
+	}
+	return out
+}